@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+
+	gen "github.com/WillRabalais04/terminalLog/api/gen"
+	"github.com/WillRabalais04/terminalLog/cmd/utils"
+	"github.com/WillRabalais04/terminalLog/internal/adapters/database"
+	gRPC "github.com/WillRabalais04/terminalLog/internal/adapters/grpc"
+	"github.com/WillRabalais04/terminalLog/internal/core/domain"
+	"github.com/WillRabalais04/terminalLog/internal/core/service"
+)
+
+const (
+	batchSize     = 50
+	batchInterval = 2 * time.Second
+	flushInterval = 30 * time.Second
+	reapInterval  = time.Minute
+)
+
+// termloggerd keeps the db (and in org mode the grpc connection) warm so the hook doesn't pay for them on every prompt
+func main() {
+	utils.LoadEnv()
+
+	socketPath := utils.GetDaemonSocketPath()
+	lock, err := lockSocket(socketPath)
+	if err != nil {
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			log.Println("termloggerd is already running")
+			return
+		}
+		log.Fatalf("daemon init failed: %v", err)
+	}
+	defer lock.Close()
+	os.Remove(socketPath) // stale socket left by a daemon that didn't shut down cleanly
+
+	localRepo, err := database.GetLocalRepo(utils.GetAppCachePath())
+	if err != nil {
+		log.Fatalf("daemon init failed: %v", err)
+	}
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	var repo *database.MultiRepo
+	flusherQuit := make(chan struct{})
+
+	if os.Getenv("APP_MODE") == "org" {
+		multiRepo, closeConn, err := utils.DialMultiRepo(localRepo)
+		if err != nil {
+			log.Fatalf("daemon init failed: %v", err)
+		}
+		defer closeConn()
+		repo = multiRepo
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			multiRepo.StartCacheFlusher(ctx, flushInterval, flusherQuit)
+		}()
+	} else {
+		repo = utils.NewMultiRepo([]database.Target{{Name: "local", Repo: localRepo, Role: database.RolePrimary}})
+	}
+
+	batcher := database.NewBatchRepo(repo, batchSize)
+	batcherQuit := make(chan struct{})
+	batcherDone := make(chan struct{})
+	go func() {
+		defer close(batcherDone)
+		batcher.Run(ctx, batchInterval, batcherQuit)
+	}()
+
+	reaperQuit := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(reapInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				reapDeadShells(ctx, batcher)
+			case <-reaperQuit:
+				return
+			}
+		}
+	}()
+
+	lis, err := net.Listen("unix", socketPath)
+	if err != nil {
+		log.Fatalf("failed to listen on %s: %v", socketPath, err)
+	}
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		log.Fatalf("failed to restrict socket permissions: %v", err)
+	}
+
+	svc := service.NewLogServiceWithRedactor(batcher, utils.GetRedactor()).SetMaxOutputBytes(utils.GetMaxOutputBytes())
+	gRPCServer := grpc.NewServer()
+	gen.RegisterLogServiceServer(gRPCServer, gRPC.NewServerAdapter(svc))
+
+	log.Println("termloggerd listening on", socketPath)
+	go func() {
+		if err := gRPCServer.Serve(lis); err != nil {
+			log.Fatalf("failed to serve termloggerd: %v", err)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Println("shutting down termloggerd...")
+	gRPCServer.GracefulStop()
+	close(reaperQuit)
+	close(batcherQuit) // write out pending entries before the final cache flush
+	<-batcherDone
+	close(flusherQuit)
+	wg.Wait()
+	repo.Wait()
+}
+
+// lockSocket holds an exclusive lock next to the socket for as long as the daemon runs, so two shells starting it at
+// once can't both remove the socket and listen (the lock goes away with the process however it exits)
+func lockSocket(socketPath string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create socket dir: %w", err)
+	}
+	lock, err := os.OpenFile(socketPath+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	return lock, nil
+}
+
+// reapDeadShells ends this host's sessions whose shell is gone without having ended them itself (killed, or nushell
+// which has no exit hook), which marks the commands they left running as abandoned
+func reapDeadShells(ctx context.Context, repo *database.BatchRepo) {
+	hostname, _ := os.Hostname()
+	sessions, err := repo.ListSessions(ctx, &domain.SessionFilter{ActiveOnly: true, Host: hostname, User: os.Getenv("USER")})
+	if err != nil {
+		log.Printf("could not list sessions to reap: %v", err)
+		return
+	}
+
+	now := time.Now().Unix()
+	var dead []*domain.Session
+	for _, session := range sessions {
+		if !session.Reapable(now, shellAlive) {
+			continue
+		}
+		dead = append(dead, &domain.Session{ID: session.ID, LastSeen: now, EndedAt: now})
+	}
+	if len(dead) == 0 {
+		return
+	}
+	if err := repo.UpsertSessions(ctx, dead); err != nil {
+		log.Printf("could not end dead sessions: %v", err)
+		return
+	}
+	log.Printf("ended %d sessions whose shells are gone", len(dead))
+}
+
+func shellAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM) // EPERM means it exists but belongs to someone else
+}
@@ -2,17 +2,39 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"log"
+	"maps"
+	"math"
 	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/WillRabalais04/terminalLog/cmd/utils"
 	"github.com/WillRabalais04/terminalLog/db"
+	"github.com/WillRabalais04/terminalLog/internal/adapters/cloud"
+	"github.com/WillRabalais04/terminalLog/internal/adapters/collectors"
 	"github.com/WillRabalais04/terminalLog/internal/adapters/database"
+	"github.com/WillRabalais04/terminalLog/internal/adapters/git"
 	grpcAdapter "github.com/WillRabalais04/terminalLog/internal/adapters/grpc"
+	"github.com/WillRabalais04/terminalLog/internal/adapters/importer"
+	"github.com/WillRabalais04/terminalLog/internal/adapters/installer"
+	"github.com/WillRabalais04/terminalLog/internal/adapters/runtimeenv"
+	"github.com/WillRabalais04/terminalLog/internal/adapters/tui"
 	"github.com/WillRabalais04/terminalLog/internal/core/domain"
+	"github.com/WillRabalais04/terminalLog/internal/core/ports"
+	"github.com/WillRabalais04/terminalLog/internal/core/service"
+	"github.com/google/uuid"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 )
@@ -21,29 +43,145 @@ func main() {
 
 	utils.LoadEnv()
 
-	cmd := flag.String("cmd", "", "Command executed")
-	exit := flag.Int("exit", 0, "Exit code of command")
-	ts := flag.Int64("ts", time.Now().Unix(), "Unix timestamp")
-	spid := flag.Int("pid", 0, "Shell PID")
-	uptime := flag.Int64("uptime", 0, "Shell uptime in seconds")
-	cwd := flag.String("cwd", "", "Current working directory")
-	oldpwd := flag.String("oldpwd", "", "Previous working directory")
-	user := flag.String("user", "", "Username")
-	euid := flag.Int("euid", 0, "Effective UID")
-	term := flag.String("term", "", "Terminal type")
-	hostname := flag.String("hostname", "", "Hostname")
-	sshClient := flag.String("ssh", "", "SSH client info")
-	tty := flag.String("tty", "", "TTY")
-	isRepo := flag.Bool("gitrepo", false, "Is inside git repo")
-	gitRoot := flag.String("gitroot", "", "Git repo root")
-	gitBranch := flag.String("gitbranch", "", "Git branch")
-	gitCommit := flag.String("gitcommit", "", "Git commit hash")
-	gitStatus := flag.String("gitstatus", "", "Git status")
-	jsonMode := flag.Bool("json", false, "Log output to a JSON file in addition to the database.")
-
-	flag.Parse()
+	if len(os.Args) > 1 && (os.Args[1] == "-h" || os.Args[1] == "--help") {
+		usage(os.Stdout)
+		return
+	}
+	if len(os.Args) < 2 || strings.HasPrefix(os.Args[1], "-") { // hooks from before the subcommands pass the flags alone
+		runRecord(os.Args[1:])
+		return
+	}
+	switch os.Args[1] {
+	case "record":
+		runRecord(os.Args[2:])
+	case "search":
+		runSearch(os.Args[2:])
+	case "show":
+		runShow(os.Args[2:])
+	case "delete":
+		runDelete(os.Args[2:])
+	case "forget":
+		runForget(os.Args[2:])
+	case "sync":
+		runSync()
+	case "stats":
+		runStats(os.Args[2:])
+	case "suggest":
+		runSuggest(os.Args[2:])
+	case "jump":
+		runJump(os.Args[2:])
+	case "import":
+		runImport(os.Args[2:])
+	case "tail":
+		runTail(os.Args[2:])
+	case "run":
+		runWrapped(os.Args[2:])
+	case "exec":
+		runExec(os.Args[2:])
+	case "session":
+		runSession(os.Args[2:])
+	case "setup":
+		runSetup(os.Args[2:])
+	case "uninstall":
+		runUninstall(os.Args[2:])
+	case "fields": // lets the hook skip collecting what won't be logged
+		fmt.Println(strings.Join(slices.Sorted(maps.Keys(utils.GetCaptureFields())), " "))
+	case "help":
+		usage(os.Stdout)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprint(w, `usage: termlogger <command> [flags]
+
+reading history:
+  search [query]     find commands (--filter key=value, --since 7d, --json)
+  search -i [query]  pick a command interactively (what ctrl-r opens)
+  show <id>          every field of an entry and its captured output
+  tail               the latest commands (-f keeps following)
+  stats              counts, failure rate and the most used commands and directories
+  jump <fragment>... the most used directory matching the fragments (the j shell function cds to it)
+
+changing history:
+  delete <id>...     delete entries (or every match of --filter)
+  forget --last N    delete your last N commands
+  sync               push the cache to the server and pull your other devices' history (org mode)
+  import [format]    import your existing bash, zsh, fish, atuin or mcfly history
+
+running commands:
+  run -- <cmd>       run and log a command with its output
+  exec -- <cmd>      run and log a command with its resource usage
+
+install:
+  setup, uninstall   install the binaries and shell hooks, or remove them
+
+used by the shell hooks:
+  record             log a command (the flags the hook passes)
+  suggest            complete a command line from history (zsh-autosuggestions, bash alt-s)
+  session            start, heartbeat or end a shell session
+  fields             print the fields the capture profile collects
+
+run 'termlogger <command> -h' for a command's flags.
+`)
+}
+
+// runRecord logs one command from the flags the shell hook passes
+func runRecord(args []string) {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	cmd := fs.String("cmd", "", "Command executed")
+	exit := fs.Int("exit", 0, "Exit code of command")
+	pipeStatus := fs.String("pipestatus", "", "Space separated exit codes of each pipeline stage ($PIPESTATUS / $pipestatus)")
+	ts := fs.Int64("ts", time.Now().Unix(), "Unix timestamp")
+	spid := fs.Int("pid", 0, "Shell PID")
+	uptime := fs.Int64("uptime", 0, "Shell uptime in seconds")
+	cwd := fs.String("cwd", "", "Current working directory")
+	oldpwd := fs.String("oldpwd", "", "Previous working directory")
+	user := fs.String("user", "", "Username")
+	euid := fs.Int("euid", 0, "Effective UID")
+	term := fs.String("term", "", "Terminal type")
+	hostname := fs.String("hostname", "", "Hostname")
+	sshClient := fs.String("ssh", "", "SSH client info")
+	tty := fs.String("tty", "", "TTY")
+	isRepo := fs.Bool("gitrepo", false, "Is inside git repo")
+	gitRoot := fs.String("gitroot", "", "Git repo root")
+	gitBranch := fs.String("gitbranch", "", "Git branch")
+	gitCommit := fs.String("gitcommit", "", "Git commit hash")
+	gitStatus := fs.String("gitstatus", "", "Git status")
+	jsonMode := fs.Bool("json", false, "Log output to a JSON file in addition to the database.")
+	histControl := fs.String("histcontrol", "", "Shell HISTCONTROL (ignorespace skips commands with a leading space)")
+	histIgnore := fs.String("histignore", "", "Shell HISTIGNORE patterns")
+	session := fs.String("session", "", "ID of the shell session the command ran in")
+	seq := fs.String("seq", "", "Number of the command within its session (ties a --start to its completion)")
+	start := fs.Bool("start", false, "Log the command as running, it's completed by a later call with the same --session and --seq")
+	labels := map[string]string{}
+	fs.Func("label", "key=value label to attach to the entry (repeatable, on top of TERMLOGGER_LABELS and the collectors)", func(pair string) error {
+		parsed, err := domain.ParseLabels(pair)
+		maps.Copy(labels, parsed)
+		return err
+	})
+
+	fs.Parse(args)
+
+	if trimmed := strings.TrimSpace(*cmd); strings.HasPrefix(trimmed, "termlogger run ") || strings.HasPrefix(trimmed, "termlogger exec ") {
+		return // the wrappers log the command themselves
+	}
+	if utils.ShouldIgnore(*cmd, *cwd, *histControl, *histIgnore) {
+		return
+	}
+
+	fields := utils.GetCaptureFields()
+
+	if !*isRepo { // older hooks still pass the git flags themselves
+		info := collectGit(*cwd, fields)
+		*isRepo, *gitRoot, *gitBranch, *gitCommit, *gitStatus = info.IsRepo, info.Root, info.Branch, info.Commit, info.Status
+	}
 
 	entry := &domain.LogEntry{
+		SessionID:            *session,
 		Command:              *cmd,
 		ExitCode:             int32(*exit),
 		Timestamp:            *ts,
@@ -63,18 +201,328 @@ func main() {
 		GitCommit:            *gitCommit,
 		GitStatus:            *gitStatus,
 		LoggedSuccessfully:   true,
+		PipeStatus:           parsePipeStatus(*pipeStatus),
+		Context:              collectContext(fields),
+	}
+	applyCloud(entry, fields)
+	if *start || *seq == "" { // a completion can't change the labels its start was logged with
+		entry.Labels = collectLabels(*cwd, fields, labels)
+	}
+	if *session != "" && *seq != "" { // both events derive the same id so the completion finds the start
+		entry.EventID = inFlightEventID(*session + "/" + *seq)
+		if *start {
+			entry.Status = domain.StatusRunning
+		} else {
+			entry.Status, entry.EndTimestamp = domain.StatusCompleted, time.Now().Unix()
+		}
+	}
+	record(entry, fields)
+
+	if *jsonMode && !*start {
+		homeDir, _ := os.UserHomeDir()
+		utils.LogJSON(grpcAdapter.LogEntryToProto(entry), utils.GetProjectRoot(homeDir))
+	}
+}
+
+// inFlightEventID derives an entry's id from its "<session>/<seq>" key (exported to commands as TERMLOGGER_EVENT)
+func inFlightEventID(key string) string {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(key)).String()
+}
+
+func parsePipeStatus(codes string) []int32 {
+	var pipeStatus []int32
+	for _, field := range strings.Fields(codes) {
+		code, err := strconv.Atoi(field)
+		if err != nil {
+			log.Printf("ignoring invalid pipestatus %q", codes)
+			return nil
+		}
+		pipeStatus = append(pipeStatus, int32(code))
+	}
+	return pipeStatus
+}
+
+func record(entry *domain.LogEntry, fields domain.FieldSet) {
+	fields.Apply(entry)
+	if entry.Status != domain.StatusRunning {
+		entry.DecodeExit()
 	}
+	utils.GetRedactor().Redact(entry) // before anything is written, the server redacts again for older clients
 
 	if !entry.LoggedSuccessfully {
 		log.Fatal("unsuccessfully logged")
 	}
 
+	if entry.EventID == "" {
+		entry.EventID = uuid.New().String() // so a retry through the one-shot path can't duplicate it
+	}
+	write := func(ctx context.Context, repo ports.LogRepositoryPort) error {
+		if entry.Status == domain.StatusCompleted {
+			return repo.Complete(ctx, []*domain.LogEntry{entry})
+		}
+		return repo.Log(ctx, []*domain.LogEntry{entry})
+	}
+	if !viaDaemon(write) { // one-shot fallback when termloggerd isn't running
+		writeOneShot(write)
+	}
+}
+
+// runSession registers (start), heartbeats or ends the hook's shell session
+func runSession(args []string) {
+	if len(args) == 0 {
+		log.Fatal("usage: termlogger session <start|heartbeat|end> --id <session id>")
+	}
+	fs := flag.NewFlagSet("session", flag.ExitOnError)
+	id := fs.String("id", "", "Session ID")
+	shell := fs.String("shell", "", "Shell name")
+	tty := fs.String("tty", "", "TTY")
+	fs.Parse(args[1:])
+	if *id == "" {
+		log.Fatal("session requires --id")
+	}
+
+	now := time.Now().Unix()
+	session := &domain.Session{ID: *id, LastSeen: now}
+	switch args[0] {
+	case "start":
+		hostname, _ := os.Hostname()
+		session.Host, session.User, session.TTY, session.Shell, session.StartedAt = hostname, os.Getenv("USER"), *tty, *shell, now
+	case "heartbeat":
+	case "end":
+		session.EndedAt = now
+	default:
+		log.Fatalf("unknown session command %q (should be start, heartbeat or end)", args[0])
+	}
+
+	upsert := func(ctx context.Context, repo ports.LogRepositoryPort) error {
+		sessionRepo, ok := repo.(ports.LogSessionPort)
+		if !ok {
+			return fmt.Errorf("repo doesn't track sessions")
+		}
+		return sessionRepo.UpsertSessions(ctx, []*domain.Session{session})
+	}
+	if !viaDaemon(upsert) {
+		writeOneShot(upsert)
+	}
+}
+
+// runWrapped runs a command with its output teed to the terminal, then logs it along with the tail of that output
+func runWrapped(args []string) {
+	args = commandArgs(args, "run")
+
+	tail := utils.NewTailBuffer(utils.GetMaxOutputBytes())
+
+	entry, exitCode := runChild(args, io.MultiWriter(os.Stdout, tail), io.MultiWriter(os.Stderr, tail))
+	if entry != nil {
+		entry.Output = &domain.CommandOutput{Output: tail.String(), Truncated: tail.Truncated(), TotalBytes: tail.Total()}
+		record(entry, utils.GetCaptureFields())
+	}
+	os.Exit(exitCode)
+}
+
+// runExec runs a command and logs the resource usage the kernel reported for it. Used through an alias or shell
+// function it completes the entry the hook started for the command line instead of logging a second one.
+func runExec(args []string) {
+	args = commandArgs(args, "exec")
+
+	entry, exitCode := runChild(args, os.Stdout, os.Stderr)
+	if entry != nil {
+		if key := os.Getenv("TERMLOGGER_EVENT"); key != "" {
+			entry.EventID = inFlightEventID(key)
+			entry.SessionID, _, _ = strings.Cut(key, "/")
+			entry.Status, entry.EndTimestamp = domain.StatusCompleted, time.Now().Unix()
+		}
+		record(entry, utils.GetCaptureFields())
+	}
+	os.Exit(exitCode)
+}
+
+func commandArgs(args []string, mode string) []string {
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		log.Fatalf("usage: termlogger %s -- <command> [args...]", mode)
+	}
+	return args
+}
+
+// runChild runs the command attached to the terminal, returning its exit code (as the shell would report it) and the
+// entry to log for it (nil if it's ignored)
+func runChild(args []string, stdout, stderr io.Writer) (*domain.LogEntry, int) {
+	start := time.Now()
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "termlogger: %v\n", err)
+		os.Exit(127)
+	}
+
+	// ctrl-c, ctrl-\ and hangups go to the terminal's whole foreground group so the command gets them already, the
+	// logger only has to outlive it to record how it ended. A SIGTERM sent to the logger alone is passed on. Caught
+	// (rather than ignored) signals are reset for the command when it's exec'd.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGHUP, syscall.SIGTERM)
+	go func() {
+		for sig := range signals {
+			if sig == syscall.SIGTERM {
+				cmd.Process.Signal(sig)
+			}
+		}
+	}()
+	err := cmd.Wait()
+	signal.Stop(signals)
+	close(signals)
+
+	exitCode := 0
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			fmt.Fprintf(os.Stderr, "termlogger: %v\n", err)
+			os.Exit(127)
+		}
+		exitCode = exitErr.ExitCode()
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			exitCode = 128 + int(ws.Signal()) // what the shell would report in $?
+		}
+	}
+
+	command := utils.ShellJoin(args)
+	cwd, _ := os.Getwd()
+	if utils.ShouldIgnore(command, cwd, "", "") {
+		return nil, exitCode
+	}
+	hostname, _ := os.Hostname()
+	info := collectGit(cwd, utils.GetCaptureFields())
+	entry := &domain.LogEntry{
+		Command:              command,
+		ExitCode:             int32(exitCode),
+		Timestamp:            start.Unix(),
+		Shell_PID:            int32(os.Getppid()),
+		WorkingDirectory:     cwd,
+		PrevWorkingDirectory: os.Getenv("OLDPWD"),
+		User:                 os.Getenv("USER"),
+		EUID:                 int32(os.Geteuid()),
+		Term:                 os.Getenv("TERM"),
+		Hostname:             hostname,
+		SSHClient:            os.Getenv("SSH_CLIENT"),
+		TTY:                  os.Getenv("TTY"),
+		GitRepo:              info.IsRepo,
+		GitRepoRoot:          info.Root,
+		GitBranch:            info.Branch,
+		GitCommit:            info.Commit,
+		GitStatus:            info.Status,
+		LoggedSuccessfully:   true,
+		PipeStatus:           []int32{int32(exitCode)},
+		Usage:                utils.ResourceUsage(cmd.ProcessState),
+		Context:              collectContext(utils.GetCaptureFields()),
+	}
+	applyCloud(entry, utils.GetCaptureFields())
+	entry.Labels = collectLabels(cwd, utils.GetCaptureFields(), nil)
+	return entry, exitCode
+}
+
+func collectGit(cwd string, fields domain.FieldSet) git.Info {
+	mode, err := git.ParseMode(os.Getenv("GIT_MODE"))
+	if err != nil {
+		log.Printf("%v, defaulting to full", err)
+	}
+	if mode == git.ModeFull && !fields.Enabled("git_status") {
+		mode = git.ModeLight
+	}
+	if !fields.Enabled("git_repo") && !fields.Enabled("git_repo_root") && !fields.Enabled("git_branch") && !fields.Enabled("git_commit") && !fields.Enabled("git_status") {
+		mode = git.ModeOff
+	}
+	budget, err := time.ParseDuration(os.Getenv("GIT_STATUS_TIMEOUT"))
+	if err != nil {
+		budget = 200 * time.Millisecond
+	}
+	return git.Collect(cwd, mode, budget)
+}
+
+// collectContext detects the container, virtualenv, nix shell and toolchains the logger (so the command) ran under
+func collectContext(fields domain.FieldSet) *domain.RuntimeContext {
+	if !fields.Enabled("context") {
+		return nil
+	}
+	budget, err := time.ParseDuration(os.Getenv("TOOLCHAIN_TIMEOUT"))
+	if err != nil {
+		budget = 500 * time.Millisecond
+	}
+	return runtimeenv.Detect("/", filepath.Join(filepath.Dir(utils.GetAppCachePath()), "toolchains.json"), budget)
+}
+
+// applyCloud sets the kube context, aws profile, gcloud config and terraform workspace the command would have targeted
+func applyCloud(entry *domain.LogEntry, fields domain.FieldSet) {
+	if !slices.ContainsFunc([]string{"kube_context", "kube_namespace", "aws_profile", "aws_region", "gcloud_config", "terraform_workspace"}, fields.Enabled) {
+		return
+	}
+	info := cloud.Collect(entry.WorkingDirectory)
+	entry.KubeContext, entry.KubeNamespace = info.KubeContext, info.KubeNamespace
+	entry.AWSProfile, entry.AWSRegion = info.AWSProfile, info.AWSRegion
+	entry.GCloudConfig, entry.TerraformWorkspace = info.GCloudConfig, info.TerraformWorkspace
+}
+
+// collectLabels merges the collectors' labels with TERMLOGGER_LABELS and then explicit ones, the most specific winning
+func collectLabels(cwd string, fields domain.FieldSet, explicit map[string]string) map[string]string {
+	if !fields.Enabled("labels") {
+		return nil
+	}
+	labels := collectors.Run(context.Background(), utils.GetCollectors(), cwd)
+	if list := os.Getenv("TERMLOGGER_LABELS"); list != "" {
+		env, err := domain.ParseLabels(list)
+		if err != nil {
+			log.Printf("ignoring TERMLOGGER_LABELS: %v", err)
+		} else {
+			labels = mergeLabels(labels, env)
+		}
+	}
+	labels = mergeLabels(labels, explicit)
+	if err := domain.ValidateLabels(labels); err != nil { // the server would reject the whole entry
+		log.Printf("dropping labels: %v", err)
+		return nil
+	}
+	return labels
+}
+
+func mergeLabels(labels, more map[string]string) map[string]string {
+	if len(more) == 0 {
+		return labels
+	}
+	if labels == nil {
+		labels = make(map[string]string, len(more))
+	}
+	maps.Copy(labels, more)
+	return labels
+}
+
+// viaDaemon hands the write to termloggerd which batches it, reporting whether the daemon accepted it
+func viaDaemon(write func(ctx context.Context, repo ports.LogRepositoryPort) error) bool {
+	socketPath := utils.GetDaemonSocketPath()
+	if _, err := os.Stat(socketPath); err != nil {
+		return false
+	}
+	conn, err := grpc.Dial("unix://"+socketPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	return write(ctx, grpcAdapter.NewClientAdapter(conn)) == nil
+}
+
+// writeOneShot writes straight to the local db, or in org mode to the cache which is then flushed to remote
+func writeOneShot(write func(ctx context.Context, repo ports.LogRepositoryPort) error) {
 	// setting up local repo (main db in app mode, temporary cache in org mode)
 	cachePath := utils.GetAppCachePath()
 	localRepo, err := database.NewRepo(&database.Config{
-		Driver:       "sqlite",
-		DataSource:   cachePath,
-		SchemaString: db.SqliteSchema,
+		Driver:     "sqlite",
+		DataSource: cachePath,
+		Migrations: db.SqliteMigrations,
 	})
 	if err != nil {
 		log.Printf("could not init cache repo (sqlite): %v", err)
@@ -82,8 +530,16 @@ func main() {
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	if err := localRepo.Log(ctx, []*domain.LogEntry{entry}); err != nil {
-		log.Printf("error: could not write to local cache: %v", err)
+	if os.Getenv("APP_MODE") == "org" { // org mode always writes to the cache first and flushes it below
+		if err := write(ctx, localRepo); err != nil {
+			log.Printf("error: could not write to local cache: %v", err)
+		}
+	} else {
+		repo := utils.NewMultiRepo([]database.Target{{Name: "local", Repo: localRepo, Role: database.RolePrimary}})
+		if err := write(ctx, repo); err != nil {
+			log.Printf("error: could not write to local db: %v", err)
+		}
+		repo.Wait()
 	}
 
 	if os.Getenv("APP_MODE") == "org" {
@@ -95,25 +551,846 @@ func main() {
 			bgCtx, bgCancel := context.WithTimeout(context.Background(), 15*time.Second)
 			defer bgCancel()
 
-			serverAddr := utils.GetEnvOrDefault("API_HOST_PORT", "localhost:9090")
-			conn, err := grpc.Dial(serverAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+			multiRepo, closeConn, err := utils.DialMultiRepo(localRepo)
 			if err != nil {
 				return // silently fail if server is offline leaving logs in cache
 			}
-			defer conn.Close()
-
-			remoteRepo := grpcAdapter.NewClientAdapter(conn)
-			multiRepo := database.NewMultiRepo(localRepo, remoteRepo)
+			defer closeConn()
 
 			if _, err := multiRepo.FlushCache(bgCtx); err != nil {
 				log.Printf("background flush failed: %v", err)
 			}
+			multiRepo.Wait()
 		}()
 		wg.Wait()
 	}
 
-	if *jsonMode {
-		homeDir, _ := os.UserHomeDir()
-		utils.LogJSON(grpcAdapter.LogEntryToProto(entry), utils.GetProjectRoot(homeDir))
+}
+
+// runSync flushes the cache to remote and pulls down entries logged from the user's other devices
+func runSync() {
+	if os.Getenv("APP_MODE") != "org" {
+		log.Fatal("sync is only available in org mode")
+	}
+
+	localRepo, err := database.GetLocalRepo(utils.GetAppCachePath())
+	if err != nil {
+		log.Fatal(err)
+	}
+	multiRepo, closeConn, err := utils.DialMultiRepo(localRepo)
+	if err != nil {
+		log.Fatalf("could not connect to server: %v", err)
+	}
+	defer closeConn()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	flushed, err := multiRepo.FlushCache(ctx)
+	if err != nil {
+		log.Fatalf("sync failed: %v", err)
+	}
+	pulled, err := multiRepo.Pull(ctx)
+	if err != nil {
+		log.Fatalf("sync failed: %v", err)
+	}
+	log.Printf("sync complete: pushed %d entries, pulled %d entries.", len(flushed), pulled)
+}
+
+// runForget deletes the user's last n commands everywhere, leaving tombstones so other copies can't bring them back
+func runForget(args []string) {
+	fs := flag.NewFlagSet("forget", flag.ExitOnError)
+	last := fs.Uint64("last", 1, "Number of most recent commands to forget")
+	fs.Parse(args)
+
+	localRepo, err := database.GetLocalRepo(utils.GetAppCachePath())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	hostname, _ := os.Hostname()
+	ctx = domain.WithActor(ctx, fmt.Sprintf("%s@%s", os.Getenv("USER"), hostname))
+
+	var repo ports.LogRepositoryPort = localRepo
+	var multiRepo *database.MultiRepo
+	if os.Getenv("APP_MODE") == "org" {
+		var closeConn func() error
+		multiRepo, closeConn, err = utils.DialMultiRepo(localRepo)
+		if err != nil {
+			log.Fatalf("could not connect to server, nothing was forgotten: %v", err)
+		}
+		defer closeConn()
+		// flushed entries may only be left on remote, so that's where the last commands are found (pending ones first
+		// have to get there too)
+		if _, err := multiRepo.FlushCache(ctx); err != nil {
+			log.Fatalf("could not flush to server to find your last %d commands, nothing was forgotten: %v", *last, err)
+		}
+		repo = multiRepo.GetRemote()
+	}
+
+	recent, err := repo.List(ctx, domain.NewFilterBuilder().
+		AddFilterTerm("user_name", os.Getenv("USER")).
+		SetOrderBy("ts").
+		SetLimit(*last).
+		Build())
+	if err != nil {
+		log.Fatalf("could not find recent commands, nothing was forgotten: %v", err)
+	}
+	if len(recent) == 0 {
+		fmt.Println("nothing to forget.")
+		return
+	}
+
+	// tombstoned whether or not the cache still has a copy, the next flush then deletes them on remote
+	actor, deletedAt := domain.ActorFromContext(ctx), time.Now().Unix()
+	tombstones := make([]*domain.Tombstone, len(recent))
+	for i, entry := range recent {
+		tombstones[i] = &domain.Tombstone{EventID: entry.EventID, DeletedAt: deletedAt, Actor: actor, User: entry.User}
+	}
+	if err := localRepo.PushTombstones(ctx, tombstones); err != nil {
+		log.Fatalf("could not forget commands: %v", err)
+	}
+	if multiRepo != nil {
+		if _, err := multiRepo.FlushCache(ctx); err != nil {
+			log.Printf("could not reach server, they'll be deleted there on the next flush: %v", err)
+		}
+	}
+
+	for _, entry := range recent {
+		fmt.Printf("forgot: %s\n", entry.Command)
+	}
+}
+
+// openService reads and deletes history through the same LogService the server uses: over the api (ClientAdapter) in
+// org mode and straight from the sqlite db in local mode
+func openService() (*service.LogService, func()) {
+	if os.Getenv("APP_MODE") == "org" {
+		conn, err := grpc.Dial(utils.GetEnvOrDefault("API_HOST_PORT", "localhost:9090"), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			log.Fatalf("could not connect to server: %v", err)
+		}
+		return service.NewLogService(grpcAdapter.NewClientAdapter(conn)).SetMaxOutputBytes(utils.GetMaxOutputBytes()), func() { conn.Close() }
+	}
+	localRepo, err := database.GetLocalRepo(utils.GetAppCachePath())
+	if err != nil {
+		log.Fatal(err)
+	}
+	return service.NewLogService(localRepo).SetMaxOutputBytes(utils.GetMaxOutputBytes()), func() {}
+}
+
+// historyFlags are the filters shared by the commands that read history
+type historyFlags struct {
+	filters, searches []string
+	since, until      string
+	here, allUsers    bool
+}
+
+func addHistoryFlags(fs *flag.FlagSet) *historyFlags {
+	h := &historyFlags{}
+	fs.Func("filter", "Exact `key=value` match (repeatable), eg. exit_code=1, git_branch=main or labels.team=payments", func(term string) error {
+		h.filters = append(h.filters, term)
+		return nil
+	})
+	fs.Func("search", "Substring `key=value` match (repeatable), eg. cwd=api", func(term string) error {
+		h.searches = append(h.searches, term)
+		return nil
+	})
+	fs.StringVar(&h.since, "since", "", "Only commands run after this, eg. 2h, 7d, 2w or 2006-01-02")
+	fs.StringVar(&h.until, "until", "", "Only commands run before this (same format as --since)")
+	fs.BoolVar(&h.here, "here", false, "Only commands run in the current directory")
+	fs.BoolVar(&h.allUsers, "all-users", false, "Include other users' commands (by default only yours)")
+	return h
+}
+
+// build turns the flags (and a query matched against the command) into a filter, every term has to match
+func (h *historyFlags) build(query string) *domain.LogFilter {
+	filters, err := utils.ParseTerms(h.filters)
+	if err != nil {
+		log.Fatal(err)
+	}
+	searches, err := utils.ParseTerms(h.searches)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	builder := domain.NewFilterBuilder().SetFilterMode(domain.AND).SetSearchMode(domain.AND).SetOrderBy("ts")
+	for key, values := range filters {
+		builder.AddFilterTerm(key, values...)
+	}
+	for key, values := range searches {
+		builder.AddSearchTerm(key, values...)
+	}
+	if query = strings.TrimSpace(query); query != "" {
+		builder.AddSearchTerm("command", query)
+	}
+	if h.here {
+		cwd, _ := os.Getwd()
+		builder.AddFilterTerm("cwd", cwd)
+	}
+	if !h.allUsers {
+		builder.AddFilterTerm("user_name", os.Getenv("USER"))
+	}
+
+	filter := builder.Build()
+	now := time.Now()
+	for value, bound := range map[string]**int64{h.since: &filter.StartTime, h.until: &filter.EndTime} {
+		if value == "" {
+			continue
+		}
+		at, err := utils.ParseSince(value, now)
+		if err != nil {
+			log.Fatal(err)
+		}
+		unix := at.Unix()
+		*bound = &unix
+	}
+	if err := database.ValidateFilter(filter); err != nil { // a term that can't be applied would widen the match
+		log.Fatal(err)
+	}
+	return filter
+}
+
+// parseArgs lets flags come after positional args (eg. search git push --since 7d), anything after -- is positional
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for len(args) > 0 {
+		fs.Parse(args)
+		rest := fs.Args()
+		if len(rest) == 0 {
+			break
+		}
+		if len(rest) < len(args) && args[len(args)-len(rest)-1] == "--" {
+			positional = append(positional, rest...)
+			break
+		}
+		positional, args = append(positional, rest[0]), rest[1:]
+	}
+	return positional
+}
+
+// runSearch lists the commands matching a query and the filter flags, newest last
+func runSearch(args []string) {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	history := addHistoryFlags(fs)
+	limit := fs.Uint64("limit", 50, "Maximum number of commands to show (0 for every match)")
+	jsonOut := fs.Bool("json", false, "Print the entries as JSON")
+	interactive := fs.Bool("interactive", false, "Pick a command in a full screen picker and print it (used by the ctrl-r widgets)")
+	fs.BoolVar(interactive, "i", false, "Shorthand for --interactive")
+	session := fs.String("session", "", "The shell's session id, for the picker's session mode")
+	query := parseArgs(fs, args)
+
+	if *interactive {
+		runPicker(strings.Join(query, " "), *session)
+		return
+	}
+
+	filter := history.build(strings.Join(query, " "))
+	filter.Limit = *limit
+
+	svc, closeSvc := openService()
+	defer closeSvc()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	entries, err := svc.List(ctx, filter)
+	if err != nil {
+		log.Fatalf("search failed: %v", err)
+	}
+	slices.Reverse(entries)
+	if *jsonOut {
+		err = utils.PrintEntriesJSON(os.Stdout, entries)
+	} else {
+		err = utils.PrintEntries(os.Stdout, entries)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runPicker opens the ctrl-r picker and prints the chosen command for the widget to put on the prompt, exiting 1 if
+// nothing was chosen so the widget leaves the line alone
+func runPicker(query, session string) {
+	localRepo, err := database.GetLocalRepo(utils.GetAppCachePath())
+	if err != nil {
+		log.Fatal(err)
+	}
+	var lister tui.Lister = localRepo
+	if os.Getenv("APP_MODE") == "org" { // the server has the other hosts' history, the cache covers it being down
+		multiRepo, closeConn, err := utils.DialMultiRepo(localRepo)
+		if err != nil {
+			log.Fatalf("could not connect to server: %v", err)
+		}
+		defer closeConn()
+		lister = multiRepo
+	}
+
+	cwd, _ := os.Getwd()
+	scope := tui.Scope{User: os.Getenv("USER"), SessionID: session, Cwd: cwd, RepoRoot: git.Collect(cwd, git.ModeLight, 0).Root}
+	command, ok, err := tui.Run(context.Background(), lister, scope, query)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+	fmt.Println(command)
+}
+
+// runSuggest prints the commands starting with --prefix that best fit the prompt, best first. It only reads the local db
+// and gives up silently past --timeout since it runs on every keystroke.
+func runSuggest(args []string) {
+	fs := flag.NewFlagSet("suggest", flag.ExitOnError)
+	prefix := fs.String("prefix", "", "What's been typed so far")
+	cwd := fs.String("cwd", "", "The prompt's working directory (defaults to the current one)")
+	repoRoot := fs.String("repo", "", "The git repo root (worked out from --cwd when not given)")
+	previous := fs.String("prev", "", "The last command run in the shell")
+	limit := fs.Int("limit", 1, "Number of suggestions to print")
+	timeout := fs.Duration("timeout", 100*time.Millisecond, "Latency budget, nothing is printed past it")
+	fs.Parse(args)
+
+	if *prefix == "" {
+		os.Exit(1)
+	}
+	if *cwd == "" {
+		*cwd, _ = os.Getwd()
+	}
+	if *repoRoot == "" {
+		*repoRoot = git.Collect(*cwd, git.ModeLight, 0).Root
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	localRepo, err := database.OpenLocalRepo(utils.GetAppCachePath(), *timeout) // migrating is left to the logger
+	if err != nil || ctx.Err() != nil {
+		os.Exit(1)
+	}
+	svc := service.NewLogService(localRepo)
+	user := os.Getenv("USER")
+	// the latest history for knowing what followed what, plus older runs matching the prefix
+	recent, err := svc.List(ctx, domain.NewFilterBuilder().AddFilterTerm("user_name", user).SetOrderBy("ts").SetLimit(2000).Build())
+	if err != nil {
+		os.Exit(1)
+	}
+	matches, err := svc.List(ctx, domain.NewFilterBuilder().AddFilterTerm("user_name", user).SetCommandPrefix(*prefix).
+		SetOrderBy("ts").SetLimit(1000).Build())
+	if err != nil || ctx.Err() != nil {
+		os.Exit(1)
+	}
+
+	suggestions := domain.Suggest(recent, matches, domain.SuggestContext{Prefix: *prefix, Cwd: *cwd, RepoRoot: *repoRoot, Previous: *previous}, *limit)
+	if len(suggestions) == 0 {
+		os.Exit(1)
+	}
+	for _, suggestion := range suggestions {
+		fmt.Println(suggestion)
+	}
+}
+
+// runJump prints the highest ranked directory that matches the fragments and still exists, or with --list every match
+// with its score
+func runJump(args []string) {
+	fs := flag.NewFlagSet("jump", flag.ExitOnError)
+	host := fs.String("host", "", "Only rank directories used on this host (defaults to the current one)")
+	allHosts := fs.Bool("all-hosts", false, "Rank directories used on any host")
+	list := fs.Bool("list", false, "List the matching directories by score instead of printing the best one")
+	limit := fs.Uint64("limit", 20, "Number of directories to --list")
+	fragments := parseArgs(fs, args)
+
+	filter := &domain.DirectoryFilter{User: os.Getenv("USER"), Host: *host, Fragments: fragments}
+	if filter.Host == "" && !*allHosts {
+		filter.Host, _ = os.Hostname()
+	}
+
+	svc, closeSvc := openService()
+	defer closeSvc()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ranked, err := svc.RankDirectories(ctx, filter)
+	if err != nil {
+		log.Fatalf("failed to rank directories: %v", err)
+	}
+	cwd, _ := os.Getwd()
+	var directories []*domain.DirectoryRank
+	for _, directory := range ranked {
+		if info, err := os.Stat(directory.Path); err != nil || !info.IsDir() {
+			continue // moved or deleted since
+		}
+		directories = append(directories, directory)
+	}
+
+	if *list {
+		if *limit > 0 && uint64(len(directories)) > *limit {
+			directories = directories[:*limit]
+		}
+		utils.PrintDirectories(os.Stdout, directories)
+		return
+	}
+	if len(directories) == 0 {
+		fmt.Fprintf(os.Stderr, "no directory matching '%s'\n", strings.Join(fragments, " "))
+		os.Exit(1)
+	}
+	best := directories[0]
+	if best.Path == cwd && len(directories) > 1 { // jumping to where you already are is no use
+		best = directories[1]
+	}
+	fmt.Println(best.Path)
+}
+
+// runImport logs the commands in shell history files and other tools' databases, every format whose file exists when
+// none is given. Ids are derived from the commands (or kept from the source) so importing again only adds what's new.
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	file := fs.String("file", "", "History file to read (defaults to where the shell or tool keeps it)")
+	batchSize := fs.Int("batch", 1000, "Number of commands written at a time")
+	dryRun := fs.Bool("dry-run", false, "Parse the history and print what would be imported without writing it")
+	names := parseArgs(fs, args)
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		log.Fatal(err)
+	}
+	formats := importer.Formats
+	if len(names) > 0 {
+		formats = nil
+		for _, name := range names {
+			format, err := importer.GetFormat(name)
+			if err != nil {
+				log.Fatal(err)
+			}
+			formats = append(formats, format)
+		}
+	}
+	if *file != "" && len(formats) != 1 {
+		log.Fatal("--file needs the history's format, eg. termlogger import zsh --file ~/old_zsh_history")
+	}
+
+	hostname, _ := os.Hostname()
+	opts := importer.Options{User: os.Getenv("USER"), Hostname: hostname}
+	svc, closeSvc := openService() // read from on dry runs too, to leave out what was already logged live
+	defer closeSvc()
+	live := &liveHistory{svc: svc, firsts: map[[2]string]int64{}}
+	rules, redactor := utils.GetIgnoreRules(), utils.GetRedactor()
+
+	imported := 0
+	for _, format := range formats {
+		path := *file
+		if path == "" {
+			path = format.Path(home)
+		}
+		entries, err := importer.ReadFile(format, path, opts)
+		if errors.Is(err, os.ErrNotExist) && len(names) == 0 {
+			continue // not a shell they use
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		entries = slices.DeleteFunc(entries, func(entry *domain.LogEntry) bool { return rules.Ignores(entry.Command, "") })
+		if entries, err = live.drop(entries); err != nil {
+			log.Fatalf("failed to check %s against the commands logged live: %v", path, err)
+		}
+		for _, entry := range entries {
+			redactor.Redact(entry)
+		}
+
+		if *dryRun {
+			utils.PrintEntries(os.Stdout, entries)
+		} else {
+			for batch := range slices.Chunk(entries, max(*batchSize, 1)) {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+				err := svc.Log(ctx, batch)
+				cancel()
+				if err != nil {
+					log.Fatalf("failed to import %s: %v", path, err)
+				}
+			}
+		}
+		fmt.Printf("📥 read %d commands from %s\n", len(entries), path)
+		imported += len(entries)
+	}
+	if imported == 0 {
+		fmt.Println("📥 no history to import")
+	}
+}
+
+// liveHistory finds the imported commands termlogger already logged live. Dated ones are left out from the first live
+// entry on their user and host onwards, undated ones (whose times are only estimates) if they were logged live at all.
+type liveHistory struct {
+	svc    *service.LogService
+	firsts map[[2]string]int64 // by user and host
+}
+
+func (h *liveHistory) drop(entries []*domain.LogEntry) ([]*domain.LogEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	kept := entries[:0]
+	for _, entry := range entries {
+		var logged bool
+		var err error
+		if entry.Labels["ts"] == "estimated" {
+			logged, err = h.loggedLive(ctx, entry)
+		} else {
+			var first int64
+			first, err = h.first(ctx, entry)
+			logged = entry.Timestamp >= first
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !logged {
+			kept = append(kept, entry)
+		}
+	}
+	return kept, nil
+}
+
+// first is when the entry's user and host were first logged live (math.MaxInt64 if never)
+func (h *liveHistory) first(ctx context.Context, entry *domain.LogEntry) (int64, error) {
+	key := [2]string{entry.User, entry.Hostname}
+	if first, ok := h.firsts[key]; ok {
+		return first, nil
+	}
+	const pageSize = 1000
+	first := int64(math.MaxInt64)
+	for offset := uint64(0); ; offset += pageSize { // past whatever was imported before
+		page, err := h.svc.List(ctx, domain.NewFilterBuilder().AddFilterTerm("user_name", entry.User).AddFilterTerm("hostname", entry.Hostname).
+			SetOrderBy("-ts").SetLimit(pageSize).SetOffset(offset).Build())
+		if err != nil {
+			return 0, err
+		}
+		if i := slices.IndexFunc(page, isLive); i >= 0 {
+			first = page[i].Timestamp
+			break
+		}
+		if len(page) < pageSize {
+			break
+		}
+	}
+	h.firsts[key] = first
+	return first, nil
+}
+
+func (h *liveHistory) loggedLive(ctx context.Context, entry *domain.LogEntry) (bool, error) {
+	found, err := h.svc.List(ctx, domain.NewFilterBuilder().AddFilterTerm("command", entry.Command).
+		AddFilterTerm("user_name", entry.User).AddFilterTerm("hostname", entry.Hostname).SetLimit(100).Build()) // newest first, live runs come after imported ones
+	if err != nil {
+		return false, err
+	}
+	return slices.ContainsFunc(found, isLive), nil
+}
+
+func isLive(entry *domain.LogEntry) bool {
+	_, imported := entry.Labels["imported"]
+	return !imported
+}
+
+// runShow prints every field of one entry along with its captured output
+func runShow(args []string) {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	jsonOut := fs.Bool("json", false, "Print the entry as JSON")
+	ids := parseArgs(fs, args)
+	if len(ids) != 1 {
+		log.Fatal("usage: termlogger show [--json] <id>")
+	}
+
+	svc, closeSvc := openService()
+	defer closeSvc()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	entry, err := svc.Get(ctx, ids[0])
+	if err != nil || entry == nil {
+		log.Fatalf("no entry with id %s: %v", ids[0], err)
+	}
+	output, err := svc.GetOutput(ctx, entry.EventID)
+	if err != nil {
+		output = nil // the entry is still worth showing without it
+	}
+	if *jsonOut {
+		err = utils.PrintEntryJSON(os.Stdout, entry, output)
+	} else {
+		err = utils.PrintEntry(os.Stdout, entry, output)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runDelete deletes entries by id, or everything matching the filter flags once it's confirmed
+func runDelete(args []string) {
+	fs := flag.NewFlagSet("delete", flag.ExitOnError)
+	history := addHistoryFlags(fs)
+	yes := fs.Bool("yes", false, "Don't ask before deleting every match of the filters")
+	ids := parseArgs(fs, args)
+
+	svc, closeSvc := openService()
+	defer closeSvc()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	hostname, _ := os.Hostname()
+	ctx = domain.WithActor(ctx, fmt.Sprintf("%s@%s", os.Getenv("USER"), hostname))
+
+	var filter *domain.LogFilter
+	if len(ids) > 0 {
+		builder := domain.NewFilterBuilder().SetFilterMode(domain.OR)
+		for _, id := range ids {
+			builder.AddFilterTerm("event_id", id)
+		}
+		filter = builder.Build()
+	} else {
+		if len(history.filters) == 0 && len(history.searches) == 0 && history.since == "" && history.until == "" && !history.here {
+			log.Fatal("usage: termlogger delete <id>... or termlogger delete --filter key=value (refusing to delete everything)")
+		}
+		filter = history.build("")
+		matches, err := svc.List(ctx, filter)
+		if err != nil {
+			log.Fatalf("could not find the commands to delete: %v", err)
+		}
+		if len(matches) == 0 {
+			fmt.Println("nothing to delete.")
+			return
+		}
+		if !*yes && !confirm(fmt.Sprintf("delete %d commands?", len(matches))) {
+			fmt.Println("nothing deleted.")
+			return
+		}
+	}
+
+	deleted, err := svc.DeleteMultiple(ctx, filter)
+	if err != nil {
+		log.Fatalf("could not delete commands: %v", err)
+	}
+	if len(deleted) == 0 {
+		fmt.Println("nothing to delete.")
+	}
+	for _, entry := range deleted {
+		fmt.Printf("deleted: %s\n", entry.Command)
+	}
+}
+
+func confirm(question string) bool {
+	fmt.Printf("%s [y/n] ", question)
+	var reply string
+	fmt.Scanln(&reply)
+	return strings.EqualFold(strings.TrimSpace(reply), "y")
+}
+
+// runStats summarises the history matching the filter flags
+func runStats(args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	history := addHistoryFlags(fs)
+	top := fs.Int("top", 10, "How many of the most used programs, commands and directories to list")
+	jsonOut := fs.Bool("json", false, "Print the stats as JSON")
+	parseArgs(fs, args)
+
+	svc, closeSvc := openService()
+	defer closeSvc()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	entries, err := svc.List(ctx, history.build(""))
+	if err != nil {
+		log.Fatalf("could not read history: %v", err)
+	}
+	stats := domain.ComputeStats(entries, *top)
+	if *jsonOut {
+		err = utils.PrintStatsJSON(os.Stdout, stats)
+	} else {
+		err = utils.PrintStats(os.Stdout, stats)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runTail prints the latest commands and with -f keeps printing new ones as they're logged
+func runTail(args []string) {
+	fs := flag.NewFlagSet("tail", flag.ExitOnError)
+	history := addHistoryFlags(fs)
+	count := fs.Uint64("n", 10, "Number of commands to start with")
+	follow := fs.Bool("f", false, "Keep printing commands as they're logged")
+	interval := fs.Duration("interval", time.Second, "How often to check for new commands with -f")
+	parseArgs(fs, args)
+
+	svc, closeSvc := openService()
+	defer closeSvc()
+	list := func(filter *domain.LogFilter) []*domain.LogEntry {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		entries, err := svc.List(ctx, filter)
+		if err != nil {
+			log.Fatalf("could not read history: %v", err)
+		}
+		return entries
+	}
+
+	if *follow && history.allUsers {
+		log.Fatal("-f follows your own commands, it can't be used with --all-users")
+	}
+	user := os.Getenv("USER")
+	var watermark int64 // entries written after it are followed
+	if *follow {
+		watermark = latestWatermark(svc, user)
+	}
+	filter := history.build("")
+	filter.Limit = *count
+	latest := list(filter)
+	slices.Reverse(latest)
+
+	seen := map[string]bool{} // each entry is printed once, even when it's completed after being printed as running
+	printNew := func(entries []*domain.LogEntry) {
+		for _, entry := range entries {
+			if !seen[entry.EventID] {
+				seen[entry.EventID] = true
+				utils.PrintEntryLine(os.Stdout, entry)
+			}
+		}
+	}
+	printNew(latest)
+
+	// following goes by when entries were written rather than when they ran, since the daemon's batches and one shot
+	// writes can land behind commands that ran later
+	for *follow {
+		time.Sleep(*interval)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		written, next, err := svc.Pull(ctx, user, watermark, 1000)
+		cancel()
+		if err != nil {
+			log.Fatalf("could not read history: %v", err)
+		}
+		watermark = next
+		if len(written) == 0 {
+			continue
+		}
+		filter := history.build("") // narrowed down to what was just written, the user's filters still apply
+		for _, entry := range written {
+			filter.FilterTerms["event_id"] = domain.FilterValues{Values: append(filter.FilterTerms["event_id"].Values, entry.EventID)}
+		}
+		filter.OrderBy = ptr("-ts") // oldest first
+		printNew(list(filter))
+	}
+}
+
+// latestWatermark finds the newest updated_at the repo has for the user, which its own clock stamped (the server's in
+// org mode) so ours can't stand in for it. it looks back from now, further each time nothing turns up, and pages forward
+// to the end
+func latestWatermark(svc *service.LogService, user string) int64 {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	const pageSize = 1000
+	window, found := time.Hour, false
+	since := max(time.Now().UnixNano()-int64(window), 0)
+	for {
+		entries, next, err := svc.Pull(ctx, user, since, pageSize)
+		if err != nil {
+			log.Fatalf("could not read history: %v", err)
+		}
+		if len(entries) > 0 {
+			since, found = next, true
+			if len(entries) == pageSize {
+				continue
+			}
+		}
+		if found || since == 0 {
+			return since
+		}
+		window *= 8
+		since = max(time.Now().UnixNano()-int64(window), 0)
+	}
+}
+
+func ptr[T any](value T) *T {
+	return &value
+}
+
+// runSetup installs the binaries under a per user prefix, hooks the user's shells and writes the config dir
+func runSetup(args []string) {
+	fs := flag.NewFlagSet("setup", flag.ExitOnError)
+	prefix := fs.String("prefix", defaultPrefix(), "Install the binaries into <prefix>/bin")
+	shells := fs.String("shell", "", "Comma separated shells to hook (bash, zsh, fish, nu), defaults to your login shells and any already hooked")
+	envFile := fs.String("env", "", "Env file to install as ~/.termlogger/.env")
+	projectRoot := fs.String("project-root", "", "Checkout that json mode writes its logs into")
+	dryRun := fs.Bool("dry-run", false, "Show what would change as a diff without changing anything")
+	fs.Parse(args)
+
+	executable, err := os.Executable()
+	if err != nil {
+		log.Fatalf("could not find the termlogger binary: %v", err)
+	}
+	opts := installerOptions(*prefix, *shells)
+	opts.Executable, opts.EnvFile, opts.ProjectRoot = executable, *envFile, *projectRoot
+	if opts.ProjectRoot != "" {
+		if opts.ProjectRoot, err = filepath.Abs(opts.ProjectRoot); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	plan, err := installer.PlanSetup(opts)
+	if err != nil {
+		log.Fatalf("setup failed: %v", err)
+	}
+	applyPlan(plan, *dryRun)
+}
+
+// runUninstall removes the hooks from every shell and the installed binaries, the config dir only with --purge
+func runUninstall(args []string) {
+	fs := flag.NewFlagSet("uninstall", flag.ExitOnError)
+	prefix := fs.String("prefix", defaultPrefix(), "Prefix the binaries were installed under")
+	purge := fs.Bool("purge", false, "Also delete ~/.termlogger (your config and local logs)")
+	dryRun := fs.Bool("dry-run", false, "Show what would change as a diff without changing anything")
+	fs.Parse(args)
+
+	opts := installerOptions(*prefix, "")
+	opts.Purge = *purge
+	plan, err := installer.PlanUninstall(opts)
+	if err != nil {
+		log.Fatalf("uninstall failed: %v", err)
+	}
+	applyPlan(plan, *dryRun)
+}
+
+func defaultPrefix() string {
+	homeDir, _ := os.UserHomeDir()
+	return utils.GetEnvOrDefault("TERMLOGGER_PREFIX", filepath.Join(homeDir, ".local"))
+}
+
+func installerOptions(prefix, shells string) installer.Options {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		log.Fatalf("could not get home dir: %v", err)
+	}
+	prefix, err = filepath.Abs(prefix)
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts := installer.Options{Home: homeDir, Prefix: prefix}
+	for _, shell := range strings.Split(shells, ",") {
+		if shell = strings.TrimSpace(shell); shell != "" {
+			opts.Shells = append(opts.Shells, shell)
+		}
+	}
+	return opts
+}
+
+func applyPlan(plan *installer.Plan, dryRun bool) {
+	if len(plan.Changes) == 0 {
+		fmt.Println("nothing to change, everything is up to date.")
+	}
+	for _, change := range plan.Changes {
+		if dryRun {
+			fmt.Print(change.Diff())
+			continue
+		}
+		if err := change.Apply(); err != nil {
+			log.Fatalf("could not %s %s: %v", change.Action, change.Path, err)
+		}
+		fmt.Println(change)
+	}
+	for _, note := range plan.Notes {
+		fmt.Println("note:", note)
 	}
 }
@@ -24,7 +24,7 @@ func main() {
 		log.Fatalf("server init failed: %v", err)
 	}
 
-	svc := service.NewLogService(repo)
+	svc := service.NewLogServiceWithRedactor(repo, utils.GetRedactor()).SetMaxOutputBytes(utils.GetMaxOutputBytes())
 	grpcAdapter := gRPC.NewServerAdapter(svc)
 
 	// run server
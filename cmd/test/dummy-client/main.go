@@ -76,7 +76,7 @@ func testList(ctx context.Context, svc service.LogService) {
 	testutils.PrettyPrint("list (exit_code=0)", entries1, err)
 
 	filter2 := domain.NewFilterBuilder().
-		AddSearchTerm("user", "client").
+		AddSearchTerm("user_name", "client").
 		Build()
 	entries2, err := svc.List(ctx, filter2)
 	testutils.PrettyPrint("list (user contains 'client')", entries2, err)
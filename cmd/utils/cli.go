@@ -0,0 +1,245 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	grpcAdapter "github.com/WillRabalais04/terminalLog/internal/adapters/grpc"
+	"github.com/WillRabalais04/terminalLog/internal/core/domain"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const timeLayout = "2006-01-02 15:04:05"
+
+// ParseSince reads how far back to look, either a duration with days/weeks allowed (eg. 90m, 36h, 7d, 2w) or a date
+// (2006-01-02)
+func ParseSince(value string, now time.Time) (time.Time, error) {
+	if date, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return date, nil
+	}
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if count, ok := strings.CutSuffix(value, suffix); ok {
+			n, err := strconv.Atoi(count)
+			if err != nil || n < 0 {
+				return time.Time{}, fmt.Errorf("invalid time %q", value)
+			}
+			return now.Add(-time.Duration(n) * unit), nil
+		}
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return time.Time{}, fmt.Errorf("invalid time %q (use eg. 90m, 36h, 7d, 2w or 2006-01-02)", value)
+	}
+	return now.Add(-duration), nil
+}
+
+// ParseTerms reads repeated key=value flags into filter or search terms
+func ParseTerms(pairs []string) (map[string][]string, error) {
+	terms := map[string][]string{}
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid term %q, expected key=value", pair)
+		}
+		key = strings.TrimSpace(key)
+		terms[key] = append(terms[key], value)
+	}
+	return terms, nil
+}
+
+// PrintEntries writes entries as a table, oldest first so the newest ends up next to the prompt
+func PrintEntries(w io.Writer, entries []*domain.LogEntry) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tTIME\tEXIT\tDURATION\tDIRECTORY\tCOMMAND")
+	for _, entry := range entries {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\n", entry.EventID, time.Unix(entry.Timestamp, 0).Format(timeLayout),
+			exitColumn(entry), durationColumn(entry), entry.WorkingDirectory, strings.ReplaceAll(entry.Command, "\n", " "))
+	}
+	return table.Flush()
+}
+
+// PrintEntryLine writes an entry on a single line (termlogger tail)
+func PrintEntryLine(w io.Writer, entry *domain.LogEntry) {
+	fmt.Fprintf(w, "%s  %-9s  %s  %s\n", time.Unix(entry.Timestamp, 0).Format(timeLayout), exitColumn(entry),
+		entry.WorkingDirectory, strings.ReplaceAll(entry.Command, "\n", " "))
+}
+
+func exitColumn(entry *domain.LogEntry) string {
+	switch {
+	case entry.Status == domain.StatusRunning:
+		return "running"
+	case entry.Status == domain.StatusAbandoned:
+		return "abandoned"
+	case entry.TerminationSignal != "":
+		return entry.TerminationSignal
+	case entry.IsDisabled("exit_code"): // not captured or imported from a history file
+		return "-"
+	}
+	return strconv.Itoa(int(entry.ExitCode))
+}
+
+func durationColumn(entry *domain.LogEntry) string {
+	if entry.EndTimestamp == 0 || entry.EndTimestamp < entry.Timestamp {
+		return "-"
+	}
+	return (time.Duration(entry.EndTimestamp-entry.Timestamp) * time.Second).String()
+}
+
+// PrintEntriesJSON writes entries as a JSON array using the api's field names
+func PrintEntriesJSON(w io.Writer, entries []*domain.LogEntry) error {
+	raw := make([]json.RawMessage, 0, len(entries))
+	for _, entry := range entries {
+		entryJSON, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(grpcAdapter.LogEntryToProto(entry))
+		if err != nil {
+			return err
+		}
+		raw = append(raw, entryJSON)
+	}
+	return writeJSON(w, raw)
+}
+
+// PrintEntry writes every field that was captured for an entry, followed by its output if there is any
+func PrintEntry(w io.Writer, entry *domain.LogEntry, output *domain.CommandOutput) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	field := func(name string, value any) {
+		if text := fmt.Sprint(value); text != "" && text != "0" && text != "false" && text != "[]" {
+			fmt.Fprintf(table, "%s:\t%s\n", name, text)
+		}
+	}
+
+	field("id", entry.EventID)
+	field("command", entry.Command)
+	field("status", entry.Status)
+	fmt.Fprintf(table, "exit:\t%s\n", exitColumn(entry))
+	field("pipestatus", entry.PipeStatus)
+	field("started", time.Unix(entry.Timestamp, 0).Format(timeLayout))
+	if entry.EndTimestamp != 0 {
+		field("ended", time.Unix(entry.EndTimestamp, 0).Format(timeLayout))
+		field("duration", durationColumn(entry))
+	}
+	field("directory", entry.WorkingDirectory)
+	field("previous directory", entry.PrevWorkingDirectory)
+	field("user", entry.User)
+	field("euid", entry.EUID)
+	field("host", entry.Hostname)
+	field("ssh client", entry.SSHClient)
+	field("tty", entry.TTY)
+	field("term", entry.Term)
+	field("session", entry.SessionID)
+	field("shell pid", entry.Shell_PID)
+	field("shell uptime", entry.ShellUptime)
+	if entry.GitRepo {
+		field("git root", entry.GitRepoRoot)
+		field("git branch", entry.GitBranch)
+		field("git commit", entry.GitCommit)
+		field("git status", entry.GitStatus)
+	}
+	if usage := entry.Usage; usage != nil {
+		field("cpu", fmt.Sprintf("%s user, %s sys", time.Duration(usage.UserTimeMicros)*time.Microsecond, time.Duration(usage.SysTimeMicros)*time.Microsecond))
+		field("max rss", fmt.Sprintf("%d KB", usage.MaxRSSKB))
+	}
+	if entry.Context != nil {
+		contextJSON, _ := json.Marshal(entry.Context)
+		if string(contextJSON) != "{}" {
+			field("context", string(contextJSON))
+		}
+	}
+	field("kube context", entry.KubeContext)
+	field("kube namespace", entry.KubeNamespace)
+	field("aws profile", entry.AWSProfile)
+	field("aws region", entry.AWSRegion)
+	field("gcloud config", entry.GCloudConfig)
+	field("terraform workspace", entry.TerraformWorkspace)
+	for _, key := range slices.Sorted(maps.Keys(entry.Labels)) {
+		field("labels."+key, entry.Labels[key])
+	}
+	field("redacted", entry.Redacted)
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	if output != nil && output.Output != "" {
+		header := "output:"
+		if output.Truncated {
+			header = fmt.Sprintf("output (last %d of %d bytes):", len(output.Output), output.TotalBytes)
+		}
+		fmt.Fprintf(w, "\n%s\n%s", header, output.Output)
+		if !strings.HasSuffix(output.Output, "\n") {
+			fmt.Fprintln(w)
+		}
+	}
+	return nil
+}
+
+// PrintEntryJSON writes an entry (and its output when there is one) as indented JSON
+func PrintEntryJSON(w io.Writer, entry *domain.LogEntry, output *domain.CommandOutput) error {
+	protoEntry := grpcAdapter.LogEntryToProto(entry)
+	if output != nil {
+		protoEntry.Output = grpcAdapter.CommandOutputToProto(output)
+	}
+	entryJSON, err := protojson.MarshalOptions{UseProtoNames: true, Multiline: true, Indent: "  "}.Marshal(protoEntry)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(entryJSON))
+	return err
+}
+
+// PrintDirectories writes ranked directories with their score, visits and when they were last used
+func PrintDirectories(w io.Writer, directories []*domain.DirectoryRank) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "SCORE\tVISITS\tLAST USED\tDIRECTORY")
+	for _, directory := range directories {
+		fmt.Fprintf(table, "%.1f\t%d\t%s\t%s\n", directory.Score, directory.Visits, time.Unix(directory.LastUsed, 0).Format(timeLayout), directory.Path)
+	}
+	return table.Flush()
+}
+
+// PrintStats writes stats as a short report
+func PrintStats(w io.Writer, stats *domain.HistoryStats) error {
+	if stats.Total == 0 {
+		_, err := fmt.Fprintln(w, "no commands logged in this range.")
+		return err
+	}
+	fmt.Fprintf(w, "commands: %d (%d failed, %.1f%%", stats.Total, stats.Failed, 100*float64(stats.Failed)/float64(stats.Total))
+	if stats.Running > 0 {
+		fmt.Fprintf(w, ", %d running", stats.Running)
+	}
+	fmt.Fprintf(w, ")\nsessions: %d\n", stats.Sessions)
+	fmt.Fprintf(w, "from %s to %s\n", time.Unix(stats.First, 0).Format(timeLayout), time.Unix(stats.Last, 0).Format(timeLayout))
+
+	for _, section := range []struct {
+		title  string
+		counts []domain.Count
+	}{
+		{"top programs", stats.TopPrograms},
+		{"top commands", stats.TopCommands},
+		{"top directories", stats.TopDirectories},
+	} {
+		fmt.Fprintf(w, "\n%s:\n", section.title)
+		for _, count := range section.counts {
+			if _, err := fmt.Fprintf(w, "%7d  %s\n", count.Count, count.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func PrintStatsJSON(w io.Writer, stats *domain.HistoryStats) error {
+	return writeJSON(w, stats)
+}
+
+func writeJSON(w io.Writer, value any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false) // keeps <redacted:...> placeholders readable
+	return encoder.Encode(value)
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"strings"
+	"sync"
+)
+
+// TailBuffer keeps the last max bytes written to it (used to capture the end of a command's output)
+type TailBuffer struct {
+	mu    sync.Mutex
+	max   int
+	buf   []byte
+	total int64
+}
+
+func NewTailBuffer(max int) *TailBuffer {
+	return &TailBuffer{max: max}
+}
+
+func (t *TailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock() // stdout and stderr are copied from separate goroutines
+	defer t.mu.Unlock()
+
+	t.total += int64(len(p))
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.max {
+		t.buf = append(t.buf[:0], t.buf[len(t.buf)-t.max:]...)
+	}
+	return len(p), nil
+}
+
+func (t *TailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return strings.ToValidUTF8(string(t.buf), "") // the cut can land mid rune
+}
+
+func (t *TailBuffer) Truncated() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.total > int64(len(t.buf))
+}
+
+func (t *TailBuffer) Total() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.total
+}
+
+// ShellJoin joins args back into a command line, quoting the ones the shell would split or expand
+func ShellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\$`*?[]{}()<>|&;#~!") {
+			quoted[i] = arg
+			continue
+		}
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
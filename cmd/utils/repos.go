@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/WillRabalais04/terminalLog/internal/adapters/archive"
+	"github.com/WillRabalais04/terminalLog/internal/adapters/database"
+	grpcAdapter "github.com/WillRabalais04/terminalLog/internal/adapters/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func DialMultiRepo(localRepo *database.LogRepo) (*database.MultiRepo, func() error, error) {
+	serverAddr := GetEnvOrDefault("API_HOST_PORT", "localhost:9090")
+	conn, err := grpc.Dial(serverAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, nil, err
+	}
+	multiRepo := NewMultiRepo([]database.Target{
+		{Name: "remote", Repo: grpcAdapter.NewClientAdapter(conn), Role: database.RolePrimary},
+		{Name: "cache", Repo: localRepo, Role: database.RoleFallback},
+	})
+	multiRepo.SetSyncUser(os.Getenv("USER"))
+	return multiRepo, conn.Close, nil
+}
+
+// NewMultiRepo adds the user's NDJSON archive (if ARCHIVE_PATH is set) as a mirror and applies the configured policies
+func NewMultiRepo(targets []database.Target) *database.MultiRepo {
+	if archivePath := os.Getenv("ARCHIVE_PATH"); archivePath != "" {
+		ndjson, err := archive.NewNDJSONArchive(archivePath)
+		if err != nil {
+			log.Printf("could not init archive, skipping it: %v", err)
+		} else {
+			targets = append(targets, database.Target{Name: "archive", Repo: ndjson, Role: database.RoleMirror})
+		}
+	}
+
+	writePolicy, err := database.ParseWritePolicy(os.Getenv("WRITE_POLICY"))
+	if err != nil {
+		log.Printf("%v, using first-success", err)
+	}
+	readPolicy, err := database.ParseReadPolicy(os.Getenv("READ_POLICY"))
+	if err != nil {
+		log.Printf("%v, using first-healthy", err)
+	}
+	return database.NewMultiRepoWithPolicies(targets, writePolicy, readPolicy)
+}
+
+func GetDaemonSocketPath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		log.Printf("error: could not get home dir: %v", err)
+		return "termloggerd.sock"
+	}
+	return GetEnvOrDefault("DAEMON_SOCKET", filepath.Join(homeDir, ".termlogger", "termloggerd.sock"))
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+
+	"github.com/WillRabalais04/terminalLog/internal/core/domain"
+)
+
+// ResourceUsage converts the rusage wait4 reported for a finished child, nil if the platform doesn't provide it
+func ResourceUsage(state *os.ProcessState) *domain.ResourceUsage {
+	rusage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok || rusage == nil {
+		return nil
+	}
+	maxRSS := rusage.Maxrss
+	if runtime.GOOS == "darwin" { // reported in bytes there, kilobytes on linux
+		maxRSS /= 1024
+	}
+	return &domain.ResourceUsage{
+		UserTimeMicros:         state.UserTime().Microseconds(),
+		SysTimeMicros:          state.SystemTime().Microseconds(),
+		MaxRSSKB:               maxRSS,
+		VoluntaryCtxSwitches:   rusage.Nvcsw,
+		InvoluntaryCtxSwitches: rusage.Nivcsw,
+		BlockInputOps:          rusage.Inblock,
+		BlockOutputOps:         rusage.Oublock,
+	}
+}
@@ -6,9 +6,13 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	pb "github.com/WillRabalais04/terminalLog/api/gen"
+	"github.com/WillRabalais04/terminalLog/internal/adapters/collectors"
+	"github.com/WillRabalais04/terminalLog/internal/core/domain"
+	"github.com/WillRabalais04/terminalLog/internal/core/ports"
 	"github.com/joho/godotenv"
 	"google.golang.org/protobuf/encoding/protojson"
 )
@@ -147,3 +151,131 @@ func GetProjectRoot(homeDir string) string {
 	}
 	return strings.TrimSpace(string(projectRootBytes))
 }
+
+// GetCaptureFields reads the field capture section of the env file, falling back to every field if it's invalid
+func GetCaptureFields() domain.FieldSet {
+	fields, err := domain.ResolveFields(os.Getenv("CAPTURE_PROFILE"), splitList(os.Getenv("CAPTURE_FIELDS")), splitList(os.Getenv("CAPTURE_DISABLE")))
+	if err != nil {
+		log.Printf("%v, capturing every field", err)
+		return nil
+	}
+	return fields
+}
+
+func splitList(list string) []string {
+	if strings.TrimSpace(list) == "" {
+		return nil
+	}
+	return strings.Split(list, ",")
+}
+
+// GetMaxOutputBytes is how much of a command's output 'termlogger run' keeps (OUTPUT_MAX_BYTES)
+func GetMaxOutputBytes() int {
+	maxBytes, err := strconv.Atoi(os.Getenv("OUTPUT_MAX_BYTES"))
+	if err != nil || maxBytes <= 0 {
+		return domain.DefaultMaxOutputBytes
+	}
+	return maxBytes
+}
+
+// GetRedactor loads user-defined redaction rules (REDACT_RULES_FILE, default ~/.termlogger/redact.rules) on top of the built-in detectors
+func GetRedactor() *domain.Redactor {
+	rulesPath := os.Getenv("REDACT_RULES_FILE")
+	if rulesPath == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return domain.NewRedactor()
+		}
+		rulesPath = filepath.Join(homeDir, ".termlogger", "redact.rules")
+	}
+
+	contents, err := os.ReadFile(rulesPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("could not read redaction rules, using built-in detectors only: %v", err)
+		}
+		return domain.NewRedactor()
+	}
+	rules, err := domain.ParseRedactionRules(string(contents))
+	if err != nil {
+		log.Printf("%v, using built-in detectors only", err)
+		return domain.NewRedactor()
+	}
+	return domain.NewRedactor(rules...)
+}
+
+// GetCollectors loads the user's metadata collectors (COLLECTORS_FILE, default ~/.termlogger/collectors), none if there
+// isn't a file. A bad line disables all of them so a typo can't quietly drop some.
+func GetCollectors() []ports.MetadataCollector {
+	homeDir, _ := os.UserHomeDir()
+	contents, err := os.ReadFile(GetEnvOrDefault("COLLECTORS_FILE", filepath.Join(homeDir, ".termlogger", "collectors")))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("could not read collectors: %v", err)
+		}
+		return nil
+	}
+	configs, err := domain.ParseCollectorConfig(string(contents))
+	if err != nil {
+		log.Printf("%v, skipping collectors", err)
+		return nil
+	}
+	var enabled []ports.MetadataCollector
+	for _, config := range configs {
+		collector, err := collectors.New(config)
+		if err != nil {
+			log.Printf("%v, skipping collectors", err)
+			return nil
+		}
+		enabled = append(enabled, collector)
+	}
+	return enabled
+}
+
+// ShouldIgnore checks the user's ignore rules (IGNORE_RULES_FILE, default ~/.termlogger/ignore.rules), the shell's
+// HISTCONTROL/HISTIGNORE and any .termlogignore file in cwd or above it
+func ShouldIgnore(command, cwd, histControl, histIgnore string) bool {
+	if hasTermlogIgnore(cwd) {
+		return true
+	}
+
+	rules := GetIgnoreRules()
+	rules.IgnoreSpace = strings.Contains(histControl, "ignorespace") || strings.Contains(histControl, "ignoreboth")
+	if err := rules.AddHistIgnore(histIgnore); err != nil {
+		log.Printf("skipping HISTIGNORE patterns: %v", err)
+	}
+
+	return rules.Ignores(command, cwd)
+}
+
+// GetIgnoreRules reads the user's ignore rules (IGNORE_RULES_FILE, default ~/.termlogger/ignore.rules)
+func GetIgnoreRules() *domain.IgnoreRules {
+	homeDir, _ := os.UserHomeDir()
+	rulesPath := GetEnvOrDefault("IGNORE_RULES_FILE", filepath.Join(homeDir, ".termlogger", "ignore.rules"))
+	contents, err := os.ReadFile(rulesPath)
+	if err != nil {
+		return &domain.IgnoreRules{}
+	}
+	rules, err := domain.ParseIgnoreRules(string(contents), homeDir)
+	if err != nil {
+		log.Printf("skipping ignore rules: %v", err)
+		return &domain.IgnoreRules{}
+	}
+	return rules
+}
+
+func hasTermlogIgnore(dir string) bool {
+	if dir == "" {
+		return false
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".termlogignore")); err == nil {
+			return true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,13 @@
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS updated_at BIGINT;
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS synced BOOLEAN DEFAULT FALSE;
+
+-- existing rows are stamped (updated_at is in nanoseconds) so the first pull picks them up
+UPDATE logs SET updated_at = ts * 1000000000 WHERE updated_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS sync_state (
+  name TEXT PRIMARY KEY,
+  watermark BIGINT NOT NULL
+);
+
+-- index for incremental pulls
+CREATE INDEX IF NOT EXISTS idx_logs_user_name_updated_at ON logs (user_name, updated_at);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS tombstones (
+  event_id UUID PRIMARY KEY,
+  deleted_at BIGINT NOT NULL,
+  actor TEXT,
+  user_name TEXT,
+  updated_at BIGINT,
+  synced BOOLEAN DEFAULT FALSE
+);
+
+-- index for incremental pulls
+CREATE INDEX IF NOT EXISTS idx_tombstones_user_name_updated_at ON tombstones (user_name, updated_at);
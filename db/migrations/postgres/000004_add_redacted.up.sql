@@ -0,0 +1 @@
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS redacted BOOLEAN DEFAULT FALSE;
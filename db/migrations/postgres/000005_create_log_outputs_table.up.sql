@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS log_outputs (
+  event_id UUID PRIMARY KEY,
+  output TEXT NOT NULL,
+  truncated BOOLEAN DEFAULT FALSE,
+  total_bytes BIGINT
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_logs_running;
+ALTER TABLE logs DROP COLUMN IF EXISTS end_ts;
+ALTER TABLE logs DROP COLUMN IF EXISTS status;
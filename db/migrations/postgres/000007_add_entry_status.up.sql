@@ -0,0 +1,5 @@
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'completed';
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS end_ts BIGINT;
+
+-- only in flight commands are looked up by status
+CREATE INDEX IF NOT EXISTS idx_logs_running ON logs (session_id) WHERE status = 'running';
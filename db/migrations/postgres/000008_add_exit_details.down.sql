@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_logs_termination_signal;
+ALTER TABLE logs DROP COLUMN IF EXISTS termination_signal;
+ALTER TABLE logs DROP COLUMN IF EXISTS pipestatus;
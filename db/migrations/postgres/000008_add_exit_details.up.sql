@@ -0,0 +1,4 @@
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS pipestatus INTEGER[];
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS termination_signal TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_logs_termination_signal ON logs (termination_signal) WHERE termination_signal IS NOT NULL;
@@ -0,0 +1,7 @@
+ALTER TABLE logs DROP COLUMN IF EXISTS block_output_ops;
+ALTER TABLE logs DROP COLUMN IF EXISTS block_input_ops;
+ALTER TABLE logs DROP COLUMN IF EXISTS involuntary_ctx_switches;
+ALTER TABLE logs DROP COLUMN IF EXISTS voluntary_ctx_switches;
+ALTER TABLE logs DROP COLUMN IF EXISTS max_rss_kb;
+ALTER TABLE logs DROP COLUMN IF EXISTS sys_time_us;
+ALTER TABLE logs DROP COLUMN IF EXISTS user_time_us;
@@ -0,0 +1,8 @@
+-- filled in by termlogger exec from the rusage wait4 reports
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS user_time_us BIGINT;
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS sys_time_us BIGINT;
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS max_rss_kb BIGINT;
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS voluntary_ctx_switches BIGINT;
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS involuntary_ctx_switches BIGINT;
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS block_input_ops BIGINT;
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS block_output_ops BIGINT;
@@ -0,0 +1,2 @@
+-- runtime context (containers, virtualenvs, nix, toolchains) detected by the logger
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS context JSONB;
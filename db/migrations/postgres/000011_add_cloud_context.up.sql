@@ -0,0 +1,10 @@
+-- which cluster, account and workspace the command ran against
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS kube_context TEXT;
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS kube_namespace TEXT;
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS aws_profile TEXT;
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS aws_region TEXT;
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS gcloud_config TEXT;
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS terraform_workspace TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_logs_kube_context_ts ON logs (kube_context, ts) WHERE kube_context IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_logs_aws_profile_ts ON logs (aws_profile, ts) WHERE aws_profile IS NOT NULL;
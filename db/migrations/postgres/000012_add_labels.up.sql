@@ -0,0 +1,2 @@
+-- free form key/values written by the user's metadata collectors
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS labels JSONB;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_logs_context;
+DROP INDEX IF EXISTS idx_logs_labels;
@@ -0,0 +1,3 @@
+-- exact labels.<key> / context.<key> filters are written as @> containment, which jsonb_path_ops indexes serve
+CREATE INDEX IF NOT EXISTS idx_logs_labels ON logs USING GIN (labels jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS idx_logs_context ON logs USING GIN (context jsonb_path_ops);
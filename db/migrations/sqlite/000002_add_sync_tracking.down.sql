@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_logs_user_name_updated_at;
+DROP TABLE IF EXISTS sync_state;
+ALTER TABLE logs DROP COLUMN synced;
+ALTER TABLE logs DROP COLUMN updated_at;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS tombstones (
+  event_id TEXT PRIMARY KEY,
+  deleted_at INTEGER NOT NULL,
+  actor TEXT,
+  user_name TEXT,
+  updated_at INTEGER,
+  synced INTEGER DEFAULT 0
+);
+
+-- index for incremental pulls
+CREATE INDEX IF NOT EXISTS idx_tombstones_user_name_updated_at ON tombstones (user_name, updated_at);
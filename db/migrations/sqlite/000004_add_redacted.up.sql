@@ -0,0 +1 @@
+ALTER TABLE logs ADD COLUMN redacted INTEGER DEFAULT 0;
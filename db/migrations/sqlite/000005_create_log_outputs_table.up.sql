@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS log_outputs (
+  event_id TEXT PRIMARY KEY,
+  output TEXT NOT NULL,
+  truncated INTEGER DEFAULT 0,
+  total_bytes INTEGER
+);
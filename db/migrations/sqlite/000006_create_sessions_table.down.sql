@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_sessions_ended_at_last_seen;
+DROP INDEX IF EXISTS idx_logs_session_id_ts;
+ALTER TABLE logs DROP COLUMN session_id;
+DROP TABLE IF EXISTS sessions;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS sessions (
+  session_id TEXT PRIMARY KEY,
+  host TEXT,
+  user_name TEXT,
+  tty TEXT,
+  shell TEXT,
+  started_at INTEGER,
+  last_seen INTEGER,
+  ended_at INTEGER,
+  synced INTEGER DEFAULT 0
+);
+
+ALTER TABLE logs ADD COLUMN session_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_logs_session_id_ts ON logs (session_id, ts);
+CREATE INDEX IF NOT EXISTS idx_sessions_ended_at_last_seen ON sessions (ended_at, last_seen);
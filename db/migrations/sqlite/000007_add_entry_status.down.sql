@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_logs_running;
+ALTER TABLE logs DROP COLUMN end_ts;
+ALTER TABLE logs DROP COLUMN status;
@@ -0,0 +1,5 @@
+-- no arrays in sqlite so pipestatus holds the same {0,1,0} literal postgres uses
+ALTER TABLE logs ADD COLUMN pipestatus TEXT;
+ALTER TABLE logs ADD COLUMN termination_signal TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_logs_termination_signal ON logs (termination_signal) WHERE termination_signal IS NOT NULL;
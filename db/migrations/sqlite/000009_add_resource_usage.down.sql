@@ -0,0 +1,7 @@
+ALTER TABLE logs DROP COLUMN block_output_ops;
+ALTER TABLE logs DROP COLUMN block_input_ops;
+ALTER TABLE logs DROP COLUMN involuntary_ctx_switches;
+ALTER TABLE logs DROP COLUMN voluntary_ctx_switches;
+ALTER TABLE logs DROP COLUMN max_rss_kb;
+ALTER TABLE logs DROP COLUMN sys_time_us;
+ALTER TABLE logs DROP COLUMN user_time_us;
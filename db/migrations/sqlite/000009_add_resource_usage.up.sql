@@ -0,0 +1,8 @@
+-- filled in by termlogger exec from the rusage wait4 reports
+ALTER TABLE logs ADD COLUMN user_time_us INTEGER;
+ALTER TABLE logs ADD COLUMN sys_time_us INTEGER;
+ALTER TABLE logs ADD COLUMN max_rss_kb INTEGER;
+ALTER TABLE logs ADD COLUMN voluntary_ctx_switches INTEGER;
+ALTER TABLE logs ADD COLUMN involuntary_ctx_switches INTEGER;
+ALTER TABLE logs ADD COLUMN block_input_ops INTEGER;
+ALTER TABLE logs ADD COLUMN block_output_ops INTEGER;
@@ -0,0 +1,2 @@
+-- runtime context (containers, virtualenvs, nix, toolchains) detected by the logger, stored as JSON text
+ALTER TABLE logs ADD COLUMN context TEXT;
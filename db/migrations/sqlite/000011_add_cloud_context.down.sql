@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_logs_aws_profile_ts;
+DROP INDEX IF EXISTS idx_logs_kube_context_ts;
+ALTER TABLE logs DROP COLUMN terraform_workspace;
+ALTER TABLE logs DROP COLUMN gcloud_config;
+ALTER TABLE logs DROP COLUMN aws_region;
+ALTER TABLE logs DROP COLUMN aws_profile;
+ALTER TABLE logs DROP COLUMN kube_namespace;
+ALTER TABLE logs DROP COLUMN kube_context;
@@ -0,0 +1,2 @@
+-- free form key/values written by the user's metadata collectors, stored as JSON text
+ALTER TABLE logs ADD COLUMN labels TEXT;
@@ -0,0 +1,2 @@
+-- sqlite can't index arbitrary json keys, so label filters at least only scan labeled entries
+CREATE INDEX IF NOT EXISTS idx_logs_labeled_ts ON logs (ts) WHERE labels IS NOT NULL;
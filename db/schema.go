@@ -1,11 +1,36 @@
 package db
 
 import (
-	_ "embed"
+	"embed"
+	"io/fs"
+	"sort"
+	"strings"
 )
 
-//go:embed migrations/sqlite/000001_create_logs_table.up.sql
-var SqliteSchema string
+//go:embed migrations/sqlite/*.up.sql
+var sqliteFS embed.FS
 
-//go:embed migrations/postgres/000001_create_logs_table.up.sql
-var PostgresSchema string
+//go:embed migrations/postgres/*.up.sql
+var postgresFS embed.FS
+
+// up migrations in version order (index i is version i+1)
+var SqliteMigrations = loadMigrations(sqliteFS, "migrations/sqlite")
+var PostgresMigrations = loadMigrations(postgresFS, "migrations/postgres")
+
+func loadMigrations(fsys embed.FS, dir string) []string {
+	names, err := fs.Glob(fsys, dir+"/*.up.sql")
+	if err != nil {
+		panic(err)
+	}
+	sort.Strings(names) // zero padded version prefix keeps lexical order == version order
+
+	migrations := make([]string, 0, len(names))
+	for _, name := range names {
+		contents, err := fsys.ReadFile(name)
+		if err != nil {
+			panic(err)
+		}
+		migrations = append(migrations, strings.TrimSpace(string(contents)))
+	}
+	return migrations
+}
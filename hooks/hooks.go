@@ -0,0 +1,8 @@
+package hooks
+
+import "embed"
+
+// the shell integrations, embedded so termlogger setup can install them without a checkout
+//
+//go:embed termlogger_hook.sh termlogger_hook.fish termlogger_hook.nu
+var FS embed.FS
@@ -0,0 +1,180 @@
+
+### >>> logger start >>>
+# installed as ~/.config/fish/conf.d/termlogger.fish
+# where the termlogger binaries are (termlogger setup fills in its --prefix)
+set -g _termlogger_bin '/usr/local/bin'
+if status is-interactive
+    set -g PAUSE_FILE "$HOME/.termlogger/.paused"
+    set -g JSON_FILE "$HOME/.termlogger/.json"
+
+    function log-pause
+        if test -f "$PAUSE_FILE"
+            echo "⏸ Terminal logging is already PAUSED."
+        else
+            touch "$PAUSE_FILE"
+            echo "⏸ Terminal logging is now PAUSED."
+        end
+    end
+    function log-resume
+        if test -f "$PAUSE_FILE"
+            rm -f "$PAUSE_FILE"
+            echo "▶️ Terminal logging is now RESUMED."
+        else
+            echo "▶️ Terminal logging is already IN PROGRESS."
+        end
+    end
+    function log-json-start
+        if test -f "$JSON_FILE"
+            echo "📄 Logging terminal commands to json file is already IN PROGRESS."
+        else
+            touch "$JSON_FILE"
+            echo "📄 Logging terminal commands to json file."
+        end
+    end
+    function log-json-stop
+        if test -f "$JSON_FILE"
+            rm -f "$JSON_FILE"
+            echo "📄 Logging terminal commands to json file now STOPPED."
+        else
+            echo "📄 Logging terminal commands to json file is already STOPPED."
+        end
+    end
+
+    # j <fragment>... cds to the most used directory matching the fragments (see termlogger jump), a path is cd'd to as is
+    function j
+        if test (count $argv) -eq 1; and test -d "$argv[1]"
+            builtin cd -- $argv[1]
+            return
+        end
+        set -l dir ("$_termlogger_bin/termlogger" jump -- $argv)
+        and builtin cd -- $dir
+    end
+
+    # fields enabled by the capture profile in ~/.termlogger/.env (read once per shell)
+    set -g _termlogger_fields ("$_termlogger_bin/termlogger" fields 2>/dev/null | string split -n ' ')
+    function _termlogger_wants
+        test (count $_termlogger_fields) -eq 0; or contains -- $argv[1] $_termlogger_fields
+    end
+
+    set -g _termlogger_shell_started (date +%s)
+    set -g _termlogger_euid (id -u)
+    set -g _termlogger_tty (tty -s; and tty)
+    set -q _termlogger_seq; or set -g _termlogger_seq 0
+
+    # runs termlogger in the background (disowned so fish doesn't report the job ending)
+    function _termlogger_spawn
+        mkdir -p "$HOME/.termlogger"
+        "$_termlogger_bin/termlogger" $argv >>"$HOME/.termlogger/bin.log" 2>&1 &
+        disown 2>/dev/null
+    end
+
+    # flags shared by the start and completion of a command (sets _termlogger_args)
+    function _termlogger_collect
+        # fish never saves lines starting with a space to history, same as ignorespace
+        set -g _termlogger_args --cmd="$argv[1]" --cwd="$PWD" --user="$USER" --histcontrol=ignorespace --histignore= --session="$_termlogger_session_id"
+        _termlogger_wants shell_pid; and set -a _termlogger_args --pid="$fish_pid"
+        _termlogger_wants shell_uptime; and set -a _termlogger_args --uptime=(math (date +%s) - $_termlogger_shell_started)
+        _termlogger_wants prev_cwd; and set -a _termlogger_args --oldpwd="$dirprev[-1]"
+        _termlogger_wants euid; and set -a _termlogger_args --euid="$_termlogger_euid"
+        _termlogger_wants term; and set -a _termlogger_args --term="$TERM"
+        _termlogger_wants tty; and set -a _termlogger_args --tty="$_termlogger_tty"
+
+        if test -n "$hostname"; and _termlogger_wants hostname
+            set -a _termlogger_args --hostname "$hostname"
+        end
+        if test -n "$SSH_CLIENT"; and _termlogger_wants ssh_client
+            set -a _termlogger_args --ssh "$SSH_CLIENT"
+        end
+    end
+
+    function _termlogger_loggable
+        test -n "$argv[1]"; and not string match -qr '^(_termlogger_|log-pause|log-resume|log-json-start|log-json-stop)' -- $argv[1]
+    end
+
+    # logs the command as running before it executes so in flight commands can be seen, fish_postexec completes it
+    function _termlogger_preexec --on-event fish_preexec
+        if test -f "$PAUSE_FILE"; or not _termlogger_loggable $argv[1]
+            return
+        end
+        switch $argv[1]
+            case exit 'exit *' logout
+                return # the shell is gone before postexec could complete it
+        end
+        set -g _termlogger_seq (math $_termlogger_seq + 1)
+        set -g _termlogger_started 1
+        set -g _termlogger_started_at (date +%s)
+        set -gx TERMLOGGER_EVENT "$_termlogger_session_id/$_termlogger_seq" # lets termlogger exec complete this entry
+
+        _termlogger_collect $argv[1]
+        _termlogger_spawn record --start --seq="$_termlogger_seq" $_termlogger_args --ts="$_termlogger_started_at"
+    end
+
+    function _termlogger_postexec --on-event fish_postexec
+        # has to come first, any other command would replace the user's exit status
+        set -l exit_code $status
+        set -l pipe_status $pipestatus
+        set -l duration $CMD_DURATION
+        if not test -f "$PAUSE_FILE"; and _termlogger_loggable $argv[1]
+            _termlogger_collect $argv[1]
+            _termlogger_wants exit_code; and set -a _termlogger_args --exit="$exit_code"
+            _termlogger_wants pipestatus; and set -a _termlogger_args --pipestatus="$pipe_status"
+
+            if test "$_termlogger_started" = 1 # completes the entry logged by _termlogger_preexec
+                set -a _termlogger_args --seq="$_termlogger_seq" --ts="$_termlogger_started_at"
+            else # work the start out from how long fish says the command took
+                set -a _termlogger_args --ts=(math --scale=0 (date +%s) - $duration / 1000)
+            end
+            if test -f "$JSON_FILE"
+                set -a _termlogger_args --json
+            end
+
+            _termlogger_spawn record $_termlogger_args
+            set -g _termlogger_logged 1
+        end
+        set -e TERMLOGGER_EVENT # so the next command can't complete this one's entry
+        set -g _termlogger_started 0
+    end
+
+    # empty lines don't fire postexec so idle prompts heartbeat the session instead (at most once a minute)
+    set -q _termlogger_heartbeat_at; or set -g _termlogger_heartbeat_at 0
+    function _termlogger_prompt --on-event fish_prompt
+        set -l now (date +%s)
+        if test "$_termlogger_logged" = 1 # logged commands heartbeat the session themselves
+            set -g _termlogger_heartbeat_at $now
+        else if not test -f "$PAUSE_FILE"; and test (math $now - $_termlogger_heartbeat_at) -ge 60
+            _termlogger_spawn session heartbeat --id="$_termlogger_session_id"
+            set -g _termlogger_heartbeat_at $now
+        end
+        set -g _termlogger_logged 0
+    end
+
+    # ctrl-r opens termlogger's history picker and puts the chosen command on the prompt (set TERMLOGGER_NO_CTRL_R
+    # before this file is sourced to keep fish's own history search)
+    function _termlogger_search_widget
+        # string collect keeps a multi line command whole (and fails when nothing was picked)
+        set -l picked ("$_termlogger_bin/termlogger" search --interactive --session="$_termlogger_session_id" -- (commandline) | string collect)
+        and commandline -r -- $picked
+        commandline -f repaint
+    end
+    if not set -q TERMLOGGER_NO_CTRL_R
+        bind \cr _termlogger_search_widget
+        bind -M insert \cr _termlogger_search_widget 2>/dev/null
+    end
+
+    # start the per user daemon that batches writes (it exits straight away if one is already running)
+    if test -x "$_termlogger_bin/termloggerd"
+        mkdir -p "$HOME/.termlogger"
+        "$_termlogger_bin/termloggerd" >>"$HOME/.termlogger/termloggerd.log" 2>&1 &
+        disown 2>/dev/null
+    end
+
+    # register this shell as a session (ended when the shell exits)
+    if not set -q _termlogger_session_id
+        set -g _termlogger_session_id "$hostname-$fish_pid-"(date +%s)"-"(random)
+        _termlogger_spawn session start --id="$_termlogger_session_id" --shell=fish --tty="$_termlogger_tty"
+    end
+    function _termlogger_session_end --on-event fish_exit
+        _termlogger_spawn session end --id="$_termlogger_session_id"
+    end
+end
+### <<< logger end <<<
@@ -1,5 +1,7 @@
 
 ### >>> logger start >>>
+# where the termlogger binaries are (termlogger setup fills in its --prefix)
+_termlogger_bin='/usr/local/bin'
 PAUSE_FILE="$HOME/.termlogger/.paused"
 JSON_FILE="$HOME/.termlogger/.json"
 
@@ -36,12 +38,152 @@ log-json-stop() {
   fi
 }
 
+# j <fragment>... cds to the most used directory matching the fragments (see termlogger jump), a path is cd'd to as is
+j() {
+    local dir
+    if [[ $# -eq 1 && -d "$1" ]]; then
+        builtin cd -- "$1"
+        return
+    fi
+    dir=$("$_termlogger_bin/termlogger" jump -- "$@") && builtin cd -- "$dir"
+}
+
+# fields enabled by the capture profile in ~/.termlogger/.env (read once per shell)
+_termlogger_fields=" $("$_termlogger_bin/termlogger" fields 2>/dev/null) "
+_termlogger_wants() {
+    [[ "$_termlogger_fields" == "  " || "$_termlogger_fields" == *" $1 "* ]]
+}
+
+# flags shared by the start and completion of a command (sets the caller's termlogger_args)
+_termlogger_collect() {
+    local histcontrol="$HISTCONTROL"
+    if [ -n "$ZSH_VERSION" ]; then
+        [[ -o hist_ignore_space ]] && histcontrol="ignorespace"
+    fi
+    termlogger_args=(
+        --cmd="$1"
+        --cwd="$PWD"
+        --user="$USER"
+        --histcontrol="$histcontrol"
+        --histignore="$HISTIGNORE"
+        --session="$_termlogger_session_id"
+    )
+    _termlogger_wants shell_pid && termlogger_args+=(--pid="$$")
+    _termlogger_wants shell_uptime && termlogger_args+=(--uptime="$SECONDS")
+    _termlogger_wants prev_cwd && termlogger_args+=(--oldpwd="$OLDPWD")
+    _termlogger_wants euid && termlogger_args+=(--euid="$EUID")
+    _termlogger_wants term && termlogger_args+=(--term="$TERM")
+    _termlogger_wants tty && termlogger_args+=(--tty="$TTY")
+
+    if [[ -n "$HOSTNAME" ]] && _termlogger_wants hostname; then
+      termlogger_args+=(--hostname "$HOSTNAME")
+    fi
+    if [[ -n "$SSH_CLIENT" ]] && _termlogger_wants ssh_client; then
+      termlogger_args+=(--ssh "$SSH_CLIENT")
+    fi
+}
+
+_termlogger_loggable() {
+    [[ -n "$1" && "$1" != "_termlogger_hook"* && "$1" != "log-pause"* && "$1" != "log-resume"* && "$1" != "log-json-start"* && "$1" != "log-json-stop"* ]]
+}
+
+# logs the command as running before it executes so in flight commands can be seen, the prompt completes it
+_termlogger_start() {
+    if [ -f "$PAUSE_FILE" ] || ! _termlogger_loggable "$1"; then
+        return
+    fi
+    case "$1" in
+        exit|exit\ *|logout) return ;; # the shell is gone before the prompt could complete it
+    esac
+    _termlogger_seq=$((_termlogger_seq + 1))
+    _termlogger_started=1
+    _termlogger_started_at="$EPOCHSECONDS"
+    export TERMLOGGER_EVENT="$_termlogger_session_id/$_termlogger_seq" # lets termlogger exec complete this entry
+
+    local termlogger_args
+    _termlogger_collect "$1"
+    [[ -n "$_termlogger_started_at" ]] && termlogger_args+=(--ts "$_termlogger_started_at")
+    mkdir -p "$HOME/.termlogger"
+    ( "$_termlogger_bin/termlogger" record --start --seq="$_termlogger_seq" "${termlogger_args[@]}" &>> "$HOME/.termlogger/bin.log" & )
+}
+
+# sets the caller's hist_line and hist_num from bash's history
+_termlogger_bash_history() {
+    hist_line=$(history 1 2>/dev/null)
+    hist_num="${hist_line#"${hist_line%%[0-9]*}"}"
+    hist_num="${hist_num%%[!0-9]*}"
+    hist_line=$(printf '%s\n' "$hist_line" | sed 's/^[[:space:]]*[0-9]*[*]\{0,1\}[[:space:]]*//')
+}
+
+_termlogger_preexec() {
+    _termlogger_last_command="$1"
+    _termlogger_start "$1"
+}
+
+# bash has no preexec so a DEBUG trap notes the first command run after each prompt, _termlogger_ready arms it once the
+# prompt commands are done. it's chained in front of any DEBUG trap the user already has, so it passes on $? and (as its
+# last argument) $_
+_termlogger_debug() {
+    local exit_code=$?
+    # completions and key bindings (ctrl-r, alt-s) aren't commands
+    if [[ "$_termlogger_at_prompt" == "1" && -z "${COMP_LINE+x}${READLINE_POINT+x}" ]]; then
+        _termlogger_note_command
+    fi
+    return $exit_code
+}
+
+_termlogger_note_command() {
+    local hist_line hist_num
+    _termlogger_bash_history
+    if [[ "$hist_num" == "$_termlogger_hist_num" ]]; then
+        # nothing new in history: a prompt command added after the hook (or an empty line), a line ignoredups didn't add
+        # again or one ignorespace/HISTIGNORE hid. BASH_COMMAND (the line's first simple command) tells them apart
+        _termlogger_prompt_command "$BASH_COMMAND" && return
+        if [[ "$HISTCONTROL" == *ignorespace* || "$HISTCONTROL" == *ignoreboth* || -n "$HISTIGNORE" ]] &&
+            ! _termlogger_repeats "$hist_line" "$BASH_COMMAND"; then
+            _termlogger_at_prompt=0
+            return
+        fi
+    fi
+    _termlogger_at_prompt=0
+    _termlogger_last_command="$hist_line"
+    _termlogger_start "$hist_line"
+}
+
+_termlogger_ready() {
+    local hist_line hist_num
+    _termlogger_bash_history
+    _termlogger_hist_num="$hist_num" # after the prompt commands, history -n can load lines from other shells
+    _termlogger_at_prompt=1
+}
+
+# a sourced file can't see its caller's DEBUG trap, so the first prompt chains _termlogger_debug in front of the one the
+# user has (bash-preexec, starship and atuin all set one, often after the hook) and then drops itself from PROMPT_COMMAND
+_termlogger_debug_prompt_command='_termlogger_install_debug "$(trap -p DEBUG)";'
+_termlogger_install_debug() {
+    _termlogger_chain_trap '_termlogger_debug "$_"' DEBUG "$1"
+    PROMPT_COMMAND="${PROMPT_COMMAND/"$_termlogger_debug_prompt_command"/}"
+}
+
+# whether bash is running one of PROMPT_COMMAND's commands rather than one that was typed
+_termlogger_prompt_command() {
+    local before='(^|[[:space:];&|])' after='($|[[:space:];&|])'
+    [[ "${PROMPT_COMMAND[*]}" =~ $before"$1"$after ]]
+}
+
+# whether a line starts with the simple command, ie. it's the line being run again
+_termlogger_repeats() {
+    local rest="${1#"$2"}" operator='^[[:space:]]*[;&|]'
+    [[ -n "$2" && "$rest" != "$1" ]] && [[ -z "$rest" || "$rest" =~ $operator ]]
+}
+
 _termlogger_hook() {
+    # has to come first, any other command would replace the user's exit status
+    local exit_code=$? pipe_status="${PIPESTATUS[*]}${pipestatus[*]}"
     if [ -f "$PAUSE_FILE" ]; then
         return
     fi
 
-    local exit_code=$?
     local last_command
     local log_dir="$HOME/.termlogger"
     local log_file="$log_dir/bin.log"
@@ -53,64 +195,146 @@ _termlogger_hook() {
     fi
     _termlogger_running=1
     
-    if [ -n "$ZSH_VERSION" ]; then
-        last_command=$(fc -ln -1 2>/dev/null | sed 's/^[[:space:]]*//')
-    elif [ -n "$BASH_VERSION" ]; then
-        last_command=$(history 1 2>/dev/null | sed 's/^[[:space:]]*[0-9]*[[:space:]]*//')
-    fi
-
-    if [[ -n "$last_command" && "$last_command" != "_termlogger_hook"* && "$last_command" != "log-pause"* && "$last_command" != "log-resume"* && "$last_command" != "log-json-start"* && "$last_command" != "log-json-stop"* ]]; then        
-        local ts="${EPOCHSECONDS:-$(date +%s)}"
-        local hostname_val="${HOSTNAME:-$(hostname)}"
-        local tty_val="${TTY:-$(tty 2>/dev/null)}"
-        local termlogger_args=(
-            --cmd="$last_command"
-            --exit="$exit_code"
-            --pid="$$"
-            --uptime="$SECONDS"
-            --cwd="$PWD"
-            --oldpwd="$OLDPWD"
-            --user="$USER"
-            --euid="$EUID"
-            --term="$TERM"
-            --tty="$TTY"
-    )
+    # set by zsh's preexec or the bash DEBUG trap, neither runs for empty lines
+    last_command="$_termlogger_last_command"
+    _termlogger_last_command=""
+    if [[ -n "$BASH_VERSION" && -z "$last_command" && "$_termlogger_at_prompt" == "1" ]]; then
+        # the DEBUG trap didn't note anything, so it may have been replaced by one set after the hook: fall back to the
+        # line history gained since the last prompt (empty and ignored lines add none)
+        local hist_line hist_num
+        _termlogger_bash_history
+        [[ "$hist_num" != "$_termlogger_hist_num" ]] && last_command="$hist_line"
+    fi
+
+    [[ -n "$last_command" ]] && _termlogger_prev_command="$last_command" # what suggestions follow on from
 
-      if [[ -n "$EPOCHSECONDS" ]]; then
+    if _termlogger_loggable "$last_command"; then
+        local termlogger_args
+        _termlogger_collect "$last_command"
+        _termlogger_wants exit_code && termlogger_args+=(--exit="$exit_code")
+        _termlogger_wants pipestatus && termlogger_args+=(--pipestatus="$pipe_status")
+
+      if [[ "$_termlogger_started" == "1" ]]; then # completes the entry logged by _termlogger_start
+        termlogger_args+=(--seq="$_termlogger_seq")
+        [[ -n "$_termlogger_started_at" ]] && termlogger_args+=(--ts "$_termlogger_started_at")
+      elif [[ -n "$EPOCHSECONDS" ]]; then
         termlogger_args+=(--ts "$EPOCHSECONDS")
       fi
-      if [[ -n "$HOSTNAME" ]]; then
-        termlogger_args+=(--hostname "$HOSTNAME")
-      fi
-      if [[ -n "$SSH_CLIENT" ]]; then
-        termlogger_args+=(--ssh "$SSH_CLIENT")
-      fi
       if [ -f "$JSON_FILE" ]; then
         termlogger_args+=(--json)
       fi
 
-      local it_repo="false"
-      if git rev-parse --is-inside-work-tree >/dev/null 2>&1; then
-        git_repo="true"
-        termlogger_args+=(--gitrepo ) 
-        termlogger_args+=(--gitroot "$(git rev-parse --show-toplevel 2>/dev/null)")
-        termlogger_args+=(--gitbranch "$(git rev-parse --abbrev-ref HEAD 2>/dev/null)")
-        termlogger_args+=(--gitcommit "$(git rev-parse --short HEAD 2>/dev/null)")
-        termlogger_args+=(--gitstatus "$(git status --porcelain=v1 2>/dev/null)")
-      fi
-      ( /usr/local/bin/termlogger "${termlogger_args[@]}" &>> "$log_file" & )
+      ( "$_termlogger_bin/termlogger" record "${termlogger_args[@]}" &>> "$log_file" & )
+      _termlogger_heartbeat_at="$EPOCHSECONDS" # logged commands heartbeat the session themselves
+    elif [[ -z "$EPOCHSECONDS" ]] || (( EPOCHSECONDS - ${_termlogger_heartbeat_at:-0} >= 60 )); then # at most once a minute
+      ( "$_termlogger_bin/termlogger" session heartbeat --id="$_termlogger_session_id" &>> "$log_file" & )
+      _termlogger_heartbeat_at="$EPOCHSECONDS"
     fi
     
+    unset TERMLOGGER_EVENT # so the next command can't complete this one's entry
+    _termlogger_started=0
     _termlogger_running=0
 }
 
+# start the per user daemon that batches writes (it exits straight away if one is already running)
+if [ -x "$_termlogger_bin/termloggerd" ]; then
+    mkdir -p "$HOME/.termlogger"
+    ( "$_termlogger_bin/termloggerd" &>> "$HOME/.termlogger/termloggerd.log" & )
+fi
+
+# register this shell as a session (ended when the shell exits)
+if [[ -z "$_termlogger_session_id" ]]; then
+    _termlogger_session_id="${HOSTNAME:-$HOST}-$$-${EPOCHSECONDS:-$(date +%s)}-$RANDOM"
+    mkdir -p "$HOME/.termlogger"
+    ( "$_termlogger_bin/termlogger" session start --id="$_termlogger_session_id" --shell="${ZSH_VERSION:+zsh}${BASH_VERSION:+bash}" --tty="${TTY:-$(tty -s && tty)}" &>> "$HOME/.termlogger/bin.log" & )
+fi
+_termlogger_session_end() {
+    local exit_code=$? # kept for the exit trap it's chained in front of
+    ( "$_termlogger_bin/termlogger" session end --id="$_termlogger_session_id" &>> "$HOME/.termlogger/bin.log" & )
+    return $exit_code
+}
+
+# _termlogger_chain_trap <command> <signal> "$(trap -p <signal>)" runs the command ahead of the trap the user already
+# has for the signal (functions don't see the DEBUG trap so the caller passes it in)
+_termlogger_chain_trap() {
+    local command="$1" signal="$2" existing
+    eval "set -- $3" # trap -- '<command>' <signal>
+    existing="$3"
+    [[ "$existing" == *"${command%% *}"* ]] && return
+    trap -- "$command${existing:+; $existing}" "$signal"
+}
+
+# ctrl-r opens termlogger's history picker and puts the chosen command on the prompt (set TERMLOGGER_NO_CTRL_R
+# before this block to keep the shell's own reverse search)
+_termlogger_search_widget() {
+    local picked
+    if [ -n "$ZSH_VERSION" ]; then
+        if picked=$("$_termlogger_bin/termlogger" search --interactive --session="$_termlogger_session_id" -- "$BUFFER" </dev/tty); then
+            BUFFER="$picked"
+            CURSOR=${#BUFFER}
+        fi
+        zle reset-prompt
+    elif picked=$("$_termlogger_bin/termlogger" search --interactive --session="$_termlogger_session_id" -- "$READLINE_LINE"); then
+        READLINE_LINE="$picked"
+        READLINE_POINT=${#READLINE_LINE}
+    fi
+}
+
+# suggestions ranked by the directory, repo and previous command (set TERMLOGGER_NO_SUGGEST before this block to turn
+# them off). in zsh it's a zsh-autosuggestions strategy tried before its own history one, in bash alt-s completes the
+# line to the best suggestion
+_zsh_autosuggest_strategy_termlogger() {
+    typeset -g suggestion
+    suggestion=$("$_termlogger_bin/termlogger" suggest --prefix="$1" --cwd="$PWD" --prev="$_termlogger_prev_command" 2>/dev/null)
+}
+_termlogger_suggest_widget() {
+    local suggestion
+    [[ -n "$READLINE_LINE" ]] || return
+    if suggestion=$("$_termlogger_bin/termlogger" suggest --prefix="$READLINE_LINE" --cwd="$PWD" --prev="$_termlogger_prev_command" 2>/dev/null); then
+        READLINE_LINE="$suggestion"
+        READLINE_POINT=${#READLINE_LINE}
+    fi
+}
+
 if [ -n "$ZSH_VERSION" ]; then
+    zmodload -F zsh/datetime p:EPOCHSECONDS 2>/dev/null # bash 5 has it built in
+    if [[ -z "$TERMLOGGER_NO_SUGGEST" && -z "${ZSH_AUTOSUGGEST_STRATEGY[(r)termlogger]}" ]]; then
+        # zsh-autosuggestions only sets its default when the array is unset, so this works loaded before or after it
+        if (( ${+ZSH_AUTOSUGGEST_STRATEGY} )); then
+            ZSH_AUTOSUGGEST_STRATEGY=(termlogger "${ZSH_AUTOSUGGEST_STRATEGY[@]}")
+        else
+            ZSH_AUTOSUGGEST_STRATEGY=(termlogger history)
+        fi
+    fi
+    if [[ -o interactive && -z "$TERMLOGGER_NO_CTRL_R" ]]; then
+        zle -N _termlogger_search_widget
+        bindkey '^R' _termlogger_search_widget
+    fi
+    if [[ -z "${zshexit_functions[(r)_termlogger_session_end]}" ]]; then
+        zshexit_functions+=(_termlogger_session_end)
+    fi
     if [[ -z "${precmd_functions[(r)_termlogger_hook]}" ]]; then
         precmd_functions+=(_termlogger_hook)
     fi
+    if [[ -z "${preexec_functions[(r)_termlogger_preexec]}" ]]; then
+        preexec_functions+=(_termlogger_preexec)
+    fi
 elif [ -n "$BASH_VERSION" ]; then
+    if [[ $- == *i* && -z "$TERMLOGGER_NO_CTRL_R" ]]; then
+        bind -x '"\C-r": _termlogger_search_widget'
+    fi
+    if [[ $- == *i* && -z "$TERMLOGGER_NO_SUGGEST" ]]; then
+        bind -x '"\es": _termlogger_suggest_widget'
+    fi
+    _termlogger_chain_trap _termlogger_session_end EXIT "$(trap -p EXIT)"
+    if [[ ! "$PROMPT_COMMAND" == *"$_termlogger_debug_prompt_command"* ]]; then
+        export PROMPT_COMMAND="$_termlogger_debug_prompt_command$PROMPT_COMMAND"
+    fi
     if [[ ! "$PROMPT_COMMAND" == *"_termlogger_hook"* ]]; then
         export PROMPT_COMMAND="_termlogger_hook;$PROMPT_COMMAND"
     fi
+    if [[ ! "$PROMPT_COMMAND" == *"_termlogger_ready"* ]]; then # last, see _termlogger_debug
+        export PROMPT_COMMAND="$PROMPT_COMMAND"$'\n'"_termlogger_ready"
+    fi
 fi
 ### <<< logger end <<<
\ No newline at end of file
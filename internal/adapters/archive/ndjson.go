@@ -0,0 +1,71 @@
+package archive
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/WillRabalais04/terminalLog/internal/core/domain"
+)
+
+var ErrWriteOnly = errors.New("ndjson archive is write only")
+
+// NDJSONArchive appends every entry it's given to a newline delimited json file. It's meant to be used as a
+// mirror target of a MultiRepo so reads and deletes are left to the other targets.
+type NDJSONArchive struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewNDJSONArchive(path string) (*NDJSONArchive, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("could not create archive dir: %w", err)
+	}
+	return &NDJSONArchive{path: path}, nil
+}
+
+func (a *NDJSONArchive) Log(ctx context.Context, entries []*domain.LogEntry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	file, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("could not open archive: %w", err)
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	encoder := json.NewEncoder(w)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return fmt.Errorf("could not encode entry: %w", err)
+		}
+	}
+	return w.Flush()
+}
+
+// Complete appends the finished entry too, so the last line for an event id is its final state
+func (a *NDJSONArchive) Complete(ctx context.Context, entries []*domain.LogEntry) error {
+	return a.Log(ctx, entries)
+}
+
+func (a *NDJSONArchive) Get(ctx context.Context, id string) (*domain.LogEntry, error) {
+	return nil, ErrWriteOnly
+}
+
+func (a *NDJSONArchive) List(ctx context.Context, filters *domain.LogFilter) ([]*domain.LogEntry, error) {
+	return nil, ErrWriteOnly
+}
+
+func (a *NDJSONArchive) Delete(ctx context.Context, id string) (*domain.LogEntry, error) {
+	return nil, ErrWriteOnly
+}
+
+func (a *NDJSONArchive) DeleteMultiple(ctx context.Context, filters *domain.LogFilter) ([]*domain.LogEntry, error) {
+	return nil, ErrWriteOnly
+}
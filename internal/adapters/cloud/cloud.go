@@ -0,0 +1,231 @@
+package cloud
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type Info struct {
+	KubeContext        string
+	KubeNamespace      string
+	AWSProfile         string
+	AWSRegion          string
+	GCloudConfig       string
+	TerraformWorkspace string
+}
+
+// Collect reads which cluster, cloud account and terraform workspace commands run in cwd would target, straight from
+// the env and the tools' config files rather than running kubectl, aws, gcloud or terraform.
+func Collect(cwd string) Info {
+	home, _ := os.UserHomeDir()
+	var info Info
+	info.KubeContext, info.KubeNamespace = kubeContext(home)
+	info.AWSProfile, info.AWSRegion = awsProfile(home)
+	info.GCloudConfig = gcloudConfig(home)
+	info.TerraformWorkspace = terraformWorkspace(cwd)
+	return info
+}
+
+// kubeContext follows kubectl's merge rules: the first file in KUBECONFIG that sets current-context wins and the
+// context's namespace comes from the first file defining it
+func kubeContext(home string) (string, string) {
+	paths := filepath.SplitList(os.Getenv("KUBECONFIG"))
+	if len(paths) == 0 && home != "" {
+		paths = []string{filepath.Join(home, ".kube", "config")}
+	}
+
+	var configs []kubeConfig
+	current := ""
+	for _, path := range paths {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		config := parseKubeConfig(contents)
+		if current == "" {
+			current = config.CurrentContext
+		}
+		configs = append(configs, config)
+	}
+	if current == "" {
+		return "", ""
+	}
+	for _, config := range configs {
+		for _, context := range config.Contexts {
+			if context.Name != current {
+				continue
+			}
+			if context.Context.Namespace == "" {
+				return current, "default" // what kubectl falls back to
+			}
+			return current, context.Context.Namespace
+		}
+	}
+	return current, "default"
+}
+
+type kubeConfig struct {
+	CurrentContext string             `json:"current-context"`
+	Contexts       []kubeNamedContext `json:"contexts"`
+}
+
+type kubeNamedContext struct {
+	Name    string `json:"name"`
+	Context struct {
+		Namespace string `json:"namespace"`
+	} `json:"context"`
+}
+
+// parseKubeConfig reads the two things needed from a kubeconfig. JSON ones are decoded, yaml ones (almost always
+// written by kubectl) are read line by line since only top level keys and the contexts list matter.
+func parseKubeConfig(contents []byte) kubeConfig {
+	var config kubeConfig
+	if trimmed := strings.TrimSpace(string(contents)); strings.HasPrefix(trimmed, "{") {
+		json.Unmarshal([]byte(trimmed), &config)
+		return config
+	}
+
+	inContexts := false
+	itemIndent := -1 // indent of the current list item's keys
+	scanner := bufio.NewScanner(strings.NewReader(string(contents)))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(trimmed)
+
+		if indent == 0 && !strings.HasPrefix(trimmed, "- ") { // a top level key ends the previous section
+			key, value := yamlKeyValue(trimmed)
+			inContexts = key == "contexts"
+			if key == "current-context" {
+				config.CurrentContext = value
+			}
+			continue
+		}
+		if !inContexts {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "- ") {
+			config.Contexts = append(config.Contexts, kubeNamedContext{})
+			itemIndent = indent + 2
+			trimmed = trimmed[2:]
+			indent = itemIndent
+		}
+		if len(config.Contexts) == 0 {
+			continue
+		}
+		item := &config.Contexts[len(config.Contexts)-1]
+		key, value := yamlKeyValue(trimmed)
+		switch {
+		case indent == itemIndent && key == "name":
+			item.Name = value
+		case indent > itemIndent && key == "namespace":
+			item.Context.Namespace = value
+		}
+	}
+	return config
+}
+
+func yamlKeyValue(line string) (string, string) {
+	key, value, _ := strings.Cut(line, ":")
+	value = strings.TrimSpace(value)
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return strings.TrimSpace(key), strings.Trim(value, `"'`)
+}
+
+// awsProfile returns the profile from the env (only when one is picked, the cli's implicit default isn't recorded) and
+// the region from the env or that profile's section of the aws config
+func awsProfile(home string) (string, string) {
+	profile := firstEnv("AWS_PROFILE", "AWS_DEFAULT_PROFILE")
+	region := firstEnv("AWS_REGION", "AWS_DEFAULT_REGION")
+	if region != "" {
+		return profile, region
+	}
+
+	configPath := os.Getenv("AWS_CONFIG_FILE")
+	if configPath == "" && home != "" {
+		configPath = filepath.Join(home, ".aws", "config")
+	}
+	file, err := os.Open(configPath)
+	if err != nil {
+		return profile, ""
+	}
+	defer file.Close()
+
+	section := "default"
+	if profile != "" && profile != "default" {
+		section = "profile " + profile
+	}
+	inSection := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			inSection = strings.TrimSpace(strings.Trim(line, "[]")) == section
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); inSection && ok && strings.TrimSpace(key) == "region" {
+			return profile, strings.TrimSpace(value)
+		}
+	}
+	return profile, ""
+}
+
+func gcloudConfig(home string) string {
+	if name := os.Getenv("CLOUDSDK_ACTIVE_CONFIG_NAME"); name != "" {
+		return name
+	}
+	dir := os.Getenv("CLOUDSDK_CONFIG")
+	if dir == "" {
+		if home == "" {
+			return ""
+		}
+		dir = filepath.Join(home, ".config", "gcloud")
+	}
+	active, err := os.ReadFile(filepath.Join(dir, "active_config"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(active))
+}
+
+// terraformWorkspace is only reported inside an initialised terraform directory unless TF_WORKSPACE forces one
+func terraformWorkspace(cwd string) string {
+	if workspace := os.Getenv("TF_WORKSPACE"); workspace != "" {
+		return workspace
+	}
+	if cwd == "" {
+		return ""
+	}
+	dataDir := os.Getenv("TF_DATA_DIR")
+	if dataDir == "" {
+		dataDir = ".terraform"
+	}
+	if !filepath.IsAbs(dataDir) {
+		dataDir = filepath.Join(cwd, dataDir)
+	}
+	if _, err := os.Stat(dataDir); err != nil {
+		return ""
+	}
+	workspace, err := os.ReadFile(filepath.Join(dataDir, "environment"))
+	if err != nil {
+		return "default" // terraform only writes the file once another workspace is selected
+	}
+	return strings.TrimSpace(string(workspace))
+}
+
+func firstEnv(keys ...string) string {
+	for _, key := range keys {
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
+	}
+	return ""
+}
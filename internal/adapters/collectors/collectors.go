@@ -0,0 +1,157 @@
+package collectors
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/WillRabalais04/terminalLog/internal/adapters/git"
+	"github.com/WillRabalais04/terminalLog/internal/core/domain"
+	"github.com/WillRabalais04/terminalLog/internal/core/ports"
+)
+
+type collectFunc func(ctx context.Context, cwd string, args []string) map[string]string
+
+// builtins run in process, args come from the rest of their line in the collectors file
+var builtins = map[string]collectFunc{
+	"env":        collectEnv,
+	"git_remote": collectGitRemote,
+}
+
+type collector struct {
+	name    string
+	timeout time.Duration
+	args    []string
+	collect collectFunc
+}
+
+func (c *collector) Name() string           { return c.name }
+func (c *collector) Timeout() time.Duration { return c.timeout }
+func (c *collector) Collect(ctx context.Context, cwd string) map[string]string {
+	return c.collect(ctx, cwd, c.args)
+}
+
+func New(config domain.CollectorConfig) (ports.MetadataCollector, error) {
+	switch config.Kind {
+	case "builtin":
+		collect, ok := builtins[config.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown builtin collector %q", config.Name)
+		}
+		return &collector{name: config.Name, timeout: config.Timeout, args: config.Args, collect: collect}, nil
+	case "exec":
+		return &collector{name: config.Name, timeout: config.Timeout, args: config.Args, collect: collectExec}, nil
+	default:
+		return nil, fmt.Errorf("unknown collector kind %q", config.Kind)
+	}
+}
+
+// Run runs the collectors in parallel and merges their labels, later collectors winning on clashing keys. Each only gets
+// its own timeout; ones that run over (even if they ignore ctx) or return invalid keys don't hold up or break the rest.
+func Run(ctx context.Context, collectors []ports.MetadataCollector, cwd string) map[string]string {
+	results := make([]chan map[string]string, len(collectors))
+	for i, c := range collectors {
+		results[i] = make(chan map[string]string, 1)
+		go func() {
+			collectCtx, cancel := context.WithTimeout(ctx, c.Timeout())
+			defer cancel()
+			done := make(chan map[string]string, 1)
+			go func() { done <- c.Collect(collectCtx, cwd) }()
+			select {
+			case labels := <-done:
+				results[i] <- labels
+			case <-collectCtx.Done():
+				log.Printf("collector %s took longer than %s, skipping it", c.Name(), c.Timeout())
+				results[i] <- nil
+			}
+		}()
+	}
+
+	var merged map[string]string
+	for i, result := range results {
+		for key, value := range <-result {
+			if !domain.ValidLabelKey(key) {
+				log.Printf("collector %s returned invalid label key %q", collectors[i].Name(), key)
+				continue
+			}
+			value = truncate(strings.ToValidUTF8(value, "�"), domain.MaxLabelValueBytes) // labels have to marshal
+			if merged == nil {
+				merged = make(map[string]string)
+			}
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+// truncate cuts value to at most max bytes without splitting a rune
+func truncate(value string, max int) string {
+	if len(value) <= max {
+		return value
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+	return value[:cut]
+}
+
+// collectEnv copies the named env vars, lowercased (eg. builtin env 10ms TEAM TICKET sets labels.team and labels.ticket)
+func collectEnv(ctx context.Context, cwd string, names []string) map[string]string {
+	labels := make(map[string]string, len(names))
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			labels[strings.ToLower(name)] = value
+		}
+	}
+	return labels
+}
+
+// collectGitRemote labels entries with the origin (or the remote given as an arg) of the repo they ran in
+func collectGitRemote(ctx context.Context, cwd string, args []string) map[string]string {
+	remote := "origin"
+	if len(args) > 0 {
+		remote = args[0]
+	}
+	url := git.RemoteURL(cwd, remote)
+	if url == "" {
+		return nil
+	}
+	return map[string]string{"git_remote": url}
+}
+
+// collectExec runs an external collector in cwd and reads key=value lines from its stdout
+func collectExec(ctx context.Context, cwd string, args []string) map[string]string {
+	path := args[0]
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+	cmd := exec.CommandContext(ctx, path, args[1:]...)
+	cmd.Dir = cwd
+	cmd.WaitDelay = 100 * time.Millisecond // don't wait on grandchildren still holding stdout after a timeout kill
+	out, err := cmd.Output()
+	if err != nil {
+		log.Printf("collector %s failed: %v", path, err)
+		return nil
+	}
+
+	labels := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if key, value, found := strings.Cut(line, "="); found && !strings.HasPrefix(line, "#") {
+			labels[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return labels
+}
@@ -0,0 +1,189 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/WillRabalais04/terminalLog/internal/core/domain"
+	"github.com/WillRabalais04/terminalLog/internal/core/ports"
+	"github.com/google/uuid"
+)
+
+const maxPendingBatches = 100 // cap on entries kept in memory while the underlying repo is failing (in batches)
+
+// BatchRepo acknowledges writes immediately and logs them to the underlying repo in batches (used by the daemon).
+// Acknowledged writes only live in memory until the next flush, so they're lost if the process is killed outright or the
+// machine goes down before then, Run flushes them when told to quit.
+type BatchRepo struct {
+	repo      ports.LogRepositoryPort
+	batchSize int
+
+	mu          sync.Mutex
+	pending     []*domain.LogEntry
+	completions []*domain.LogEntry // written after pending so a start and its completion in one batch land in order
+	full        chan struct{}
+}
+
+func NewBatchRepo(repo ports.LogRepositoryPort, batchSize int) *BatchRepo {
+	return &BatchRepo{repo: repo, batchSize: batchSize, full: make(chan struct{}, 1)}
+}
+
+func (b *BatchRepo) Log(ctx context.Context, entries []*domain.LogEntry) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, entry := range entries {
+		if entry.EventID == "" {
+			entry.EventID = uuid.New().String()
+		}
+	}
+	b.pending = append(b.pending, entries...)
+	b.signalIfFull()
+	return nil
+}
+
+func (b *BatchRepo) Complete(ctx context.Context, entries []*domain.LogEntry) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.completions = append(b.completions, entries...)
+	b.signalIfFull()
+	return nil
+}
+
+// signalIfFull wakes Run once a batch worth of writes is queued (called with mu held)
+func (b *BatchRepo) signalIfFull() {
+	if len(b.pending)+len(b.completions) >= b.batchSize {
+		select {
+		case b.full <- struct{}{}:
+		default: // a flush is already signalled
+		}
+	}
+}
+
+// Flush writes out everything that's pending, keeping it queued if the underlying repo fails
+func (b *BatchRepo) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	batch, completions := b.pending, b.completions
+	b.pending, b.completions = nil, nil
+	b.mu.Unlock()
+
+	if len(batch) > 0 {
+		if err := b.repo.Log(ctx, batch); err != nil {
+			b.requeue(&b.pending, batch)
+			b.requeue(&b.completions, completions)
+			return err
+		}
+	}
+	if len(completions) > 0 {
+		if err := b.repo.Complete(ctx, completions); err != nil {
+			b.requeue(&b.completions, completions)
+			return err
+		}
+	}
+	return nil
+}
+
+// requeue puts a failed batch back in front of its queue, dropping the oldest entries past the cap
+func (b *BatchRepo) requeue(queue *[]*domain.LogEntry, batch []*domain.LogEntry) {
+	if len(batch) == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	*queue = append(batch, *queue...)
+	if overflow := len(*queue) - maxPendingBatches*b.batchSize; overflow > 0 {
+		log.Printf("CRITICAL: dropping %d oldest pending entries", overflow)
+		*queue = (*queue)[overflow:]
+	}
+}
+
+// Run flushes every interval or whenever a full batch is pending until quit is closed
+func (b *BatchRepo) Run(ctx context.Context, interval time.Duration, quit <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-b.full:
+		case <-quit:
+			if err := b.Flush(ctx); err != nil {
+				log.Printf("final batch flush failed: %v", err)
+			}
+			return
+		}
+		if err := b.Flush(ctx); err != nil {
+			log.Printf("batch flush failed: %v", err)
+		}
+	}
+}
+
+// reads and deletes flush first so they see everything that has been acknowledged
+
+func (b *BatchRepo) Get(ctx context.Context, id string) (*domain.LogEntry, error) {
+	b.Flush(ctx)
+	return b.repo.Get(ctx, id)
+}
+
+func (b *BatchRepo) List(ctx context.Context, filters *domain.LogFilter) ([]*domain.LogEntry, error) {
+	b.Flush(ctx)
+	return b.repo.List(ctx, filters)
+}
+
+func (b *BatchRepo) Delete(ctx context.Context, id string) (*domain.LogEntry, error) {
+	b.Flush(ctx)
+	return b.repo.Delete(ctx, id)
+}
+
+func (b *BatchRepo) DeleteMultiple(ctx context.Context, filters *domain.LogFilter) ([]*domain.LogEntry, error) {
+	b.Flush(ctx)
+	return b.repo.DeleteMultiple(ctx, filters)
+}
+
+func (b *BatchRepo) GetOutput(ctx context.Context, eventID string) (*domain.CommandOutput, error) {
+	b.Flush(ctx)
+	outputs, ok := b.repo.(ports.LogOutputPort)
+	if !ok {
+		return nil, fmt.Errorf("repo doesn't store command output")
+	}
+	return outputs.GetOutput(ctx, eventID)
+}
+
+// sessions aren't batched since the hook registers them once per shell
+
+func (b *BatchRepo) UpsertSessions(ctx context.Context, sessions []*domain.Session) error {
+	for _, session := range sessions {
+		if session.EndedAt != 0 { // queued starts have to land first so ending the session can reap them
+			b.Flush(ctx)
+			break
+		}
+	}
+	sessionRepo, ok := b.repo.(ports.LogSessionPort)
+	if !ok {
+		return fmt.Errorf("repo doesn't track sessions")
+	}
+	return sessionRepo.UpsertSessions(ctx, sessions)
+}
+
+func (b *BatchRepo) ListSessions(ctx context.Context, filter *domain.SessionFilter) ([]*domain.Session, error) {
+	b.Flush(ctx)
+	sessionRepo, ok := b.repo.(ports.LogSessionPort)
+	if !ok {
+		return nil, fmt.Errorf("repo doesn't track sessions")
+	}
+	return sessionRepo.ListSessions(ctx, filter)
+}
+
+func (b *BatchRepo) RankDirectories(ctx context.Context, filter *domain.DirectoryFilter) ([]*domain.DirectoryRank, error) {
+	b.Flush(ctx)
+	directories, ok := b.repo.(ports.LogDirectoryPort)
+	if !ok {
+		return nil, fmt.Errorf("repo doesn't rank directories")
+	}
+	return directories.RankDirectories(ctx, filter)
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/WillRabalais04/terminalLog/internal/core/domain"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// RankDirectories counts the commands run in each directory and ranks them by frecency (see domain.RankDirectories)
+func (r *LogRepo) RankDirectories(ctx context.Context, filter *domain.DirectoryFilter) ([]*domain.DirectoryRank, error) {
+	query := r.sb.Select("cwd", "COUNT(*)", "MAX(ts)").From("logs").
+		Where(sq.NotEq{"cwd": nil}).Where(sq.NotEq{"cwd": ""}).
+		GroupBy("cwd")
+	if filter != nil {
+		if filter.User != "" {
+			query = query.Where(sq.Eq{"user_name": filter.User})
+		}
+		if filter.Host != "" {
+			query = query.Where(sq.Or{sq.Eq{"hostname": filter.Host}, sq.Eq{"hostname": nil}, sq.Eq{"hostname": ""}})
+		}
+		if filter.Since > 0 {
+			query = query.Where(sq.GtOrEq{"ts": filter.Since})
+		}
+		for _, fragment := range filter.Fragments { // narrows it down, the order of the fragments is checked after
+			query = query.Where(sq.Expr("LOWER(cwd) LIKE LOWER(?)", "%"+fragment+"%"))
+		}
+	}
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build directory query: %w", err)
+	}
+	rows, err := r.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute directory query: %w", err)
+	}
+	defer rows.Close()
+
+	var directories []*domain.DirectoryRank
+	for rows.Next() {
+		var directory domain.DirectoryRank
+		if err := rows.Scan(&directory.Path, &directory.Visits, &directory.LastUsed); err != nil {
+			return nil, fmt.Errorf("failed to scan directory: %w", err)
+		}
+		directories = append(directories, &directory)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+	return domain.RankDirectories(directories, filter, time.Now().Unix()), nil
+}
@@ -3,7 +3,9 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -20,49 +22,77 @@ var logColumns = []string{
 	"event_id", "command", "exit_code", "ts", "shell_pid", "shell_uptime", "cwd",
 	"prev_cwd", "user_name", "euid", "term", "hostname", "ssh_client",
 	"tty", "git_repo", "git_repo_root", "git_branch", "git_commit",
-	"git_status", "logged_successfully",
+	"git_status", "logged_successfully", "redacted", "session_id", "status",
+	"end_ts", "pipestatus", "termination_signal", "user_time_us", "sys_time_us", "max_rss_kb",
+	"voluntary_ctx_switches", "involuntary_ctx_switches", "block_input_ops", "block_output_ops", "context", "kube_context", "kube_namespace", "aws_profile",
+	"aws_region", "gcloud_config", "terraform_workspace", "labels",
 }
 
-var columnMetadata = map[string]struct {
+type columnInfo struct {
 	Type        interface{}
 	IsOrderable bool
 	IsExact     bool
 	IsFuzzy     bool
-}{
-	"event_id":            {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: false},
-	"command":             {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
-	"exit_code":           {Type: int32(0), IsOrderable: true, IsExact: true, IsFuzzy: false},
-	"ts":                  {Type: int64(0), IsOrderable: true, IsExact: true, IsFuzzy: false},
-	"shell_pid":           {Type: int32(0), IsOrderable: true, IsExact: true, IsFuzzy: false},
-	"shell_uptime":        {Type: int64(0), IsOrderable: true, IsExact: true, IsFuzzy: false},
-	"cwd":                 {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
-	"prev_cwd":            {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
-	"user_name":           {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
-	"euid":                {Type: int32(0), IsOrderable: true, IsExact: true, IsFuzzy: false},
-	"term":                {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
-	"hostname":            {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
-	"ssh_client":          {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
-	"tty":                 {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
-	"git_repo":            {Type: false, IsOrderable: true, IsExact: true, IsFuzzy: false},
-	"git_repo_root":       {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
-	"git_branch":          {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
-	"git_commit":          {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
-	"git_status":          {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
-	"logged_successfully": {Type: false, IsOrderable: true, IsExact: true, IsFuzzy: false},
+}
+
+var columnMetadata = map[string]columnInfo{
+	"event_id":                 {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: false},
+	"command":                  {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
+	"exit_code":                {Type: int32(0), IsOrderable: true, IsExact: true, IsFuzzy: false},
+	"ts":                       {Type: int64(0), IsOrderable: true, IsExact: true, IsFuzzy: false},
+	"shell_pid":                {Type: int32(0), IsOrderable: true, IsExact: true, IsFuzzy: false},
+	"shell_uptime":             {Type: int64(0), IsOrderable: true, IsExact: true, IsFuzzy: false},
+	"cwd":                      {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
+	"prev_cwd":                 {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
+	"user_name":                {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
+	"euid":                     {Type: int32(0), IsOrderable: true, IsExact: true, IsFuzzy: false},
+	"term":                     {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
+	"hostname":                 {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
+	"ssh_client":               {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
+	"tty":                      {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
+	"git_repo":                 {Type: false, IsOrderable: true, IsExact: true, IsFuzzy: false},
+	"git_repo_root":            {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
+	"git_branch":               {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
+	"git_commit":               {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
+	"git_status":               {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
+	"logged_successfully":      {Type: false, IsOrderable: true, IsExact: true, IsFuzzy: false},
+	"redacted":                 {Type: false, IsOrderable: true, IsExact: true, IsFuzzy: false},
+	"session_id":               {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: false},
+	"status":                   {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: false},
+	"end_ts":                   {Type: int64(0), IsOrderable: true, IsExact: true, IsFuzzy: false},
+	"pipestatus":               {Type: "", IsOrderable: false, IsExact: false, IsFuzzy: false},
+	"termination_signal":       {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: false},
+	"user_time_us":             {Type: int64(0), IsOrderable: true, IsExact: true, IsFuzzy: false},
+	"sys_time_us":              {Type: int64(0), IsOrderable: true, IsExact: true, IsFuzzy: false},
+	"max_rss_kb":               {Type: int64(0), IsOrderable: true, IsExact: true, IsFuzzy: false},
+	"voluntary_ctx_switches":   {Type: int64(0), IsOrderable: true, IsExact: true, IsFuzzy: false},
+	"involuntary_ctx_switches": {Type: int64(0), IsOrderable: true, IsExact: true, IsFuzzy: false},
+	"block_input_ops":          {Type: int64(0), IsOrderable: true, IsExact: true, IsFuzzy: false},
+	"block_output_ops":         {Type: int64(0), IsOrderable: true, IsExact: true, IsFuzzy: false},
+	"context":                  {Type: "", IsOrderable: false, IsExact: false, IsFuzzy: false}, // filtered on its keys instead, see jsonPath
+	"kube_context":             {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
+	"kube_namespace":           {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
+	"aws_profile":              {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
+	"aws_region":               {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
+	"gcloud_config":            {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
+	"terraform_workspace":      {Type: "", IsOrderable: true, IsExact: true, IsFuzzy: true},
+	"labels":                   {Type: "", IsOrderable: false, IsExact: false, IsFuzzy: false},
+	"synced":                   {Type: false, IsOrderable: true, IsExact: true, IsFuzzy: false}, // local only, set on entries pulled from remote
 }
 
 var allowedOrderings map[string]struct{}
 var defaultOrdering = "ts"
 
 type Config struct {
-	Driver       string
-	DataSource   string
-	SchemaString string
+	Driver     string
+	DataSource string
+	Migrations []string
 }
 
 type LogRepo struct {
-	db *sql.DB
-	sb sq.StatementBuilderType
+	db     *sql.DB
+	sb     sq.StatementBuilderType
+	driver string
 }
 
 func init() {
@@ -78,7 +108,7 @@ func NewRepo(cfg *Config) (*LogRepo, error) {
 		return nil, fmt.Errorf("failed to init db: %v", err)
 	}
 
-	if _, err := db.Exec(cfg.SchemaString); err != nil {
+	if err := applyMigrations(db, cfg.Driver, cfg.Migrations); err != nil {
 		return nil, fmt.Errorf("failed to execute schema: %w", err)
 	}
 
@@ -92,10 +122,19 @@ func NewRepo(cfg *Config) (*LogRepo, error) {
 		return nil, fmt.Errorf("invalid db driver name (should pgx or sqlite3)")
 	}
 
-	return &LogRepo{db: db, sb: sq.StatementBuilder.PlaceholderFormat(placeholder)}, nil
+	return &LogRepo{db: db, sb: sq.StatementBuilder.PlaceholderFormat(placeholder), driver: cfg.Driver}, nil
 }
 
 func InitDB(driver, dataSource string) (*sql.DB, error) {
+	if driver == "sqlite" && !strings.Contains(dataSource, "busy_timeout") {
+		// the hook can start several short lived writers at once (session start, heartbeats, entries) so wait on locks
+		separator := "?"
+		if strings.Contains(dataSource, "?") {
+			separator = "&"
+		}
+		dataSource += separator + "_pragma=busy_timeout(5000)"
+	}
+
 	db, err := sql.Open(driver, dataSource)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open db: %w", err)
@@ -120,31 +159,349 @@ func InitDB(driver, dataSource string) (*sql.DB, error) {
 	return db, nil
 }
 
+func applyMigrations(db *sql.DB, driver string, migrations []string) error {
+	if driver != "sqlite" { // postgres migrations are idempotent (shared w/ golang-migrate) so just rerun them
+		for i, migration := range migrations {
+			if _, err := db.Exec(migration); err != nil {
+				return fmt.Errorf("migration %d failed: %w", i+1, err)
+			}
+		}
+		return nil
+	}
+
+	// sqlite has no ADD COLUMN IF NOT EXISTS so track the applied version in user_version
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if version >= len(migrations) {
+		return nil
+	}
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for i := version; i < len(migrations); i++ {
+		// take the write lock before rechecking the version in case another process is migrating too
+		if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+			return err
+		}
+		if err := conn.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+			conn.ExecContext(ctx, "ROLLBACK")
+			return fmt.Errorf("failed to read schema version: %w", err)
+		}
+		if version > i {
+			conn.ExecContext(ctx, "ROLLBACK")
+			continue
+		}
+		if _, err := conn.ExecContext(ctx, migrations[i]); err != nil {
+			conn.ExecContext(ctx, "ROLLBACK")
+			return fmt.Errorf("migration %d failed: %w", i+1, err)
+		}
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			conn.ExecContext(ctx, "ROLLBACK")
+			return fmt.Errorf("failed to set schema version: %w", err)
+		}
+		if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (r *LogRepo) Log(ctx context.Context, entries []*domain.LogEntry) error { // switched to batched logging for efficiency in pushing cache
+	return r.write(ctx, entries, "ON CONFLICT(event_id) DO NOTHING") // prevent duplicates (idempotent)
+}
+
+// Complete finishes entries logged when their command started. Entries that aren't there yet (the start was lost or
+// hasn't arrived) are inserted whole. The first completion's status and end time win so replays can't move an entry
+// backwards. A command run through termlogger exec is completed twice: exec's completion owns the resource usage and
+// the prompt's (the only one without usage) owns the exit details, since only the shell sees the whole pipeline.
+func (r *LogRepo) Complete(ctx context.Context, entries []*domain.LogEntry) error {
+	updates := make([]string, 0, len(completionColumns)+len(exitColumns)+len(usageColumns)+1)
+	for _, col := range completionColumns {
+		updates = append(updates, fmt.Sprintf("%s = CASE WHEN logs.status = 'completed' THEN logs.%s ELSE excluded.%s END", col, col, col))
+	}
+	for _, col := range exitColumns {
+		updates = append(updates, fmt.Sprintf("%s = CASE WHEN logs.status = 'completed' AND (excluded.status <> 'completed' OR excluded.%s IS NOT NULL) THEN logs.%s ELSE excluded.%s END", col, usageColumns[0], col, col))
+	}
+	for _, col := range usageColumns {
+		updates = append(updates, fmt.Sprintf("%s = COALESCE(excluded.%s, logs.%s)", col, col, col))
+	}
+	updates = append(updates, "updated_at = excluded.updated_at")
+	return r.write(ctx, entries, "ON CONFLICT(event_id) DO UPDATE SET "+strings.Join(updates, ", "))
+}
+
+var completionColumns = []string{"status", "end_ts"}
+
+var exitColumns = []string{"exit_code", "pipestatus", "termination_signal"}
+
+var usageColumns = []string{
+	"user_time_us", "sys_time_us", "max_rss_kb", "voluntary_ctx_switches", "involuntary_ctx_switches",
+	"block_input_ops", "block_output_ops",
+}
+
+func (r *LogRepo) write(ctx context.Context, entries []*domain.LogEntry, onConflict string) error {
 	if len(entries) == 0 {
 		return nil
 	}
 
-	query := r.sb.Insert("logs").Columns(logColumns...)
+	entries, err := r.dropTombstoned(ctx, entries)
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin log transaction: %w", err)
+	}
+	defer tx.Rollback()
 
+	for _, round := range splitDuplicates(entries) {
+		sqlStr, args, err := r.insertQuery(round, false).Suffix(onConflict).ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build bulk insert query: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+			return err
+		}
+	}
+	if err := r.insertOutputs(ctx, tx, entries); err != nil {
+		return err
+	}
+	if err := r.touchSessions(ctx, tx, entries); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Upsert inserts entries pulled from another repo, overwriting any existing copy and marking them as synced so they aren't flushed back.
+func (r *LogRepo) Upsert(ctx context.Context, entries []*domain.LogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	entries, err := r.dropTombstoned(ctx, entries)
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+
+	updates := make([]string, 0, len(logColumns)+1)
+	for _, col := range logColumns[1:] {
+		updates = append(updates, fmt.Sprintf("%s = excluded.%s", col, col))
+	}
+	updates = append(updates, "updated_at = excluded.updated_at", "synced = excluded.synced")
+
+	query := r.insertQuery(entries, true).Suffix("ON CONFLICT(event_id) DO UPDATE SET " + strings.Join(updates, ", "))
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build upsert query: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx, sqlStr, args...)
+	return err
+}
+
+// splitDuplicates breaks entries into in order rounds without repeated ids since postgres won't update the same row
+// twice in one upsert (eg. a batch holding both termlogger exec's and the prompt's completion of a command)
+func splitDuplicates(entries []*domain.LogEntry) [][]*domain.LogEntry {
+	var rounds [][]*domain.LogEntry
+	var seen []map[string]struct{}
 	for _, entry := range entries {
+		i := 0
+		for entry.EventID != "" && i < len(rounds) { // ids are only assigned when the query is built so empty ones never clash
+			if _, ok := seen[i][entry.EventID]; !ok {
+				break
+			}
+			i++
+		}
+		if i == len(rounds) {
+			rounds, seen = append(rounds, nil), append(seen, make(map[string]struct{}))
+		}
+		rounds[i] = append(rounds[i], entry)
+		seen[i][entry.EventID] = struct{}{}
+	}
+	return rounds
+}
+
+func (r *LogRepo) insertQuery(entries []*domain.LogEntry, synced bool) sq.InsertBuilder {
+	query := r.sb.Insert("logs").Columns(logColumns...).Columns("updated_at", "synced")
+
+	updatedAt := time.Now().UnixNano()
+	for i, entry := range entries {
 		if entry.EventID == "" {
 			entry.EventID = uuid.New().String()
 		}
-		query = query.Values(
-			entry.EventID, entry.Command, entry.ExitCode, entry.Timestamp,
-			entry.Shell_PID, entry.ShellUptime, entry.WorkingDirectory, entry.PrevWorkingDirectory,
-			entry.User, entry.EUID, entry.Term, entry.Hostname,
-			entry.SSHClient, entry.TTY, entry.GitRepo, entry.GitRepoRoot,
-			entry.GitBranch, entry.GitCommit, entry.GitStatus, entry.LoggedSuccessfully,
-		)
+		query = query.Values(append(entryValues(entry), updatedAt+int64(i), synced)...) // distinct updated_at per row so pull pages never split a shared watermark
 	}
+	return query
+}
 
-	query = query.Suffix("ON CONFLICT(event_id) DO NOTHING") // prevent duplicates (idempotent)
+// entryValues lines up with logColumns, storing NULL for fields the entry's capture profile turned off
+func entryValues(entry *domain.LogEntry) []interface{} {
+	values := []interface{}{
+		entry.EventID, entry.Command, entry.ExitCode, entry.Timestamp,
+		entry.Shell_PID, entry.ShellUptime, entry.WorkingDirectory, entry.PrevWorkingDirectory,
+		entry.User, entry.EUID, entry.Term, entry.Hostname,
+		entry.SSHClient, entry.TTY, entry.GitRepo, entry.GitRepoRoot,
+		entry.GitBranch, entry.GitCommit, entry.GitStatus, entry.LoggedSuccessfully,
+		entry.Redacted, nullString(entry.SessionID), entryStatus(entry), nullInt(entry.EndTimestamp),
+		formatPipeStatus(entry.PipeStatus), nullString(entry.TerminationSignal),
+	}
+	values = append(values, usageValues(entry.Usage)...)
+	values = append(values, formatContext(entry.Context), nullString(entry.KubeContext), nullString(entry.KubeNamespace),
+		nullString(entry.AWSProfile), nullString(entry.AWSRegion), nullString(entry.GCloudConfig), nullString(entry.TerraformWorkspace),
+		formatLabels(entry.Labels))
+	for i, col := range logColumns {
+		if entry.IsDisabled(col) {
+			values[i] = nil
+		}
+	}
+	return values
+}
+
+// usageValues lines up with usageColumns, all NULL when the command wasn't measured
+func usageValues(usage *domain.ResourceUsage) []interface{} {
+	if usage == nil {
+		return make([]interface{}, len(usageColumns))
+	}
+	return []interface{}{
+		usage.UserTimeMicros, usage.SysTimeMicros, usage.MaxRSSKB, usage.VoluntaryCtxSwitches,
+		usage.InvoluntaryCtxSwitches, usage.BlockInputOps, usage.BlockOutputOps,
+	}
+}
+
+func formatContext(runtime *domain.RuntimeContext) interface{} {
+	if runtime == nil {
+		return nil
+	}
+	return jsonColumn(runtime)
+}
+
+func formatLabels(labels map[string]string) interface{} {
+	if len(labels) == 0 {
+		return nil
+	}
+	return jsonColumn(labels)
+}
+
+// jsonColumn encodes a JSON column's value, leaving <redacted:type> placeholders readable rather than \u003c escaped
+func jsonColumn(value interface{}) interface{} {
+	var encoded strings.Builder
+	encoder := json.NewEncoder(&encoded)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil
+	}
+	return strings.TrimSuffix(encoded.String(), "\n")
+}
+
+func entryStatus(entry *domain.LogEntry) string {
+	if entry.Status == "" {
+		return domain.StatusCompleted
+	}
+	return entry.Status
+}
+
+// formatPipeStatus writes exit codes as a postgres array literal ({0,1,0}), which sqlite keeps as text
+func formatPipeStatus(codes []int32) interface{} {
+	if len(codes) == 0 {
+		return nil
+	}
+	parts := make([]string, len(codes))
+	for i, code := range codes {
+		parts[i] = strconv.Itoa(int(code))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func parsePipeStatus(literal string) ([]int32, error) {
+	literal = strings.Trim(literal, "{}")
+	if literal == "" {
+		return nil, nil
+	}
+	parts := strings.Split(literal, ",")
+	codes := make([]int32, len(parts))
+	for i, part := range parts {
+		code, err := strconv.ParseInt(strings.TrimSpace(part), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pipestatus %q: %w", literal, err)
+		}
+		codes[i] = int32(code)
+	}
+	return codes, nil
+}
+
+// Pull returns the user's entries written after the since watermark (oldest first) along with the new watermark.
+func (r *LogRepo) Pull(ctx context.Context, user string, since int64, limit uint64) ([]*domain.LogEntry, int64, error) {
+	query := r.sb.Select(logColumns...).Column("updated_at").From("logs").
+		Where(sq.Eq{"user_name": user}).
+		Where(sq.Gt{"updated_at": since}).
+		OrderBy("updated_at ASC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
 
 	sqlStr, args, err := query.ToSql()
 	if err != nil {
-		return fmt.Errorf("failed to build bulk insert query: %w", err)
+		return nil, since, fmt.Errorf("failed to build pull query: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, since, fmt.Errorf("failed to execute pull query: %w", err)
+	}
+	defer rows.Close()
+
+	watermark := since
+	var entries []*domain.LogEntry
+	for rows.Next() {
+		var updatedAt int64
+		entry, err := scanLogEntry(rows, &updatedAt)
+		if err != nil {
+			return nil, since, fmt.Errorf("failed to scan log entry: %w", err)
+		}
+		entries = append(entries, entry)
+		watermark = max(watermark, updatedAt)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, since, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return entries, watermark, nil
+}
+
+func (r *LogRepo) GetWatermark(ctx context.Context, name string) (int64, error) {
+	sqlStr, args, err := r.sb.Select("watermark").From("sync_state").Where(sq.Eq{"name": name}).ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build watermark query: %w", err)
+	}
+
+	var watermark int64
+	err = r.db.QueryRowContext(ctx, sqlStr, args...).Scan(&watermark)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read watermark: %w", err)
+	}
+	return watermark, nil
+}
+
+func (r *LogRepo) SetWatermark(ctx context.Context, name string, watermark int64) error {
+	sqlStr, args, err := r.sb.Insert("sync_state").
+		Columns("name", "watermark").
+		Values(name, watermark).
+		Suffix("ON CONFLICT(name) DO UPDATE SET watermark = excluded.watermark").
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build watermark query: %w", err)
 	}
 
 	_, err = r.db.ExecContext(ctx, sqlStr, args...)
@@ -168,8 +525,10 @@ func (r *LogRepo) Get(ctx context.Context, id string) (*domain.LogEntry, error)
 }
 
 func (r *LogRepo) List(ctx context.Context, filter *domain.LogFilter) ([]*domain.LogEntry, error) {
-	query := sq.StatementBuilderType(r.sb.Select(logColumns...).From("logs"))
-	query = applyFilters(query, filter)
+	query, err := applyFilters(sq.StatementBuilderType(r.sb.Select(logColumns...).From("logs")), filter, r.driver)
+	if err != nil {
+		return nil, err
+	}
 	selectQuery := sq.SelectBuilder(query)
 	orderBy, orderDir := validateOrdering(filter.OrderBy)
 	selectQuery = selectQuery.OrderBy(fmt.Sprintf("%s %s", orderBy, orderDir))
@@ -228,14 +587,22 @@ func (r *LogRepo) Delete(ctx context.Context, id string) (*domain.LogEntry, erro
 }
 
 func (r *LogRepo) DeleteMultiple(ctx context.Context, filter *domain.LogFilter) ([]*domain.LogEntry, error) {
-	query := sq.StatementBuilderType(r.sb.Delete("logs"))
-	query = applyFilters(query, filter)
-	sqlStr, args, err := (sq.DeleteBuilder(query)).Suffix("RETURNING *").ToSql()
+	query, err := applyFilters(sq.StatementBuilderType(r.sb.Delete("logs")), filter, r.driver)
+	if err != nil {
+		return nil, err
+	}
+	sqlStr, args, err := (sq.DeleteBuilder(query)).Suffix("RETURNING " + strings.Join(logColumns, ", ")).ToSql()
 	if err != nil {
 		return nil, fmt.Errorf("failed to build delete query: %w", err)
 	}
 
-	rows, err := r.db.QueryContext(ctx, sqlStr, args...)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin delete transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, sqlStr, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute delete query: %w", err)
 	}
@@ -253,13 +620,55 @@ func (r *LogRepo) DeleteMultiple(ctx context.Context, filter *domain.LogFilter)
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("error during rows iteration: %w", err)
 	}
+	rows.Close()
+
+	deletedIDs := make([]string, len(deletedEntries))
+	for i, entry := range deletedEntries {
+		deletedIDs[i] = entry.EventID
+	}
+	if err := r.deleteOutputs(ctx, tx, deletedIDs); err != nil {
+		return nil, err
+	}
+
+	// tombstone deleted entries so copies in other caches/devices can't resurrect them
+	actor := domain.ActorFromContext(ctx)
+	deletedAt := time.Now().Unix()
+	tombstones := make([]*domain.Tombstone, 0, len(deletedEntries))
+	for _, entry := range deletedEntries {
+		tombstones = append(tombstones, &domain.Tombstone{EventID: entry.EventID, DeletedAt: deletedAt, Actor: actor, User: entry.User})
+	}
+	if err := r.insertTombstones(ctx, tx, tombstones, false); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit delete: %w", err)
+	}
 
 	return deletedEntries, nil
 }
 
-func applyFilters(builder sq.StatementBuilderType, filter *domain.LogFilter) sq.StatementBuilderType {
-	builder = applyFilterTerms(builder, filter.FilterTerms, filter.FilterMode)
-	builder = applySearchTerms(builder, filter.SearchTerms, filter.SearchMode)
+// ValidateFilter checks a filter's terms the way List and DeleteMultiple would, so mistyped keys and values can be
+// reported before anything is sent
+func ValidateFilter(filter *domain.LogFilter) error {
+	_, err := applyFilters(sq.StatementBuilder, filter, "sqlite")
+	return err
+}
+
+// applyFilters errors on terms it can't apply rather than skipping them, a dropped term widens the match (and a delete
+// with it)
+func applyFilters(builder sq.StatementBuilderType, filter *domain.LogFilter, driver string) (sq.StatementBuilderType, error) {
+	builder, err := applyFilterTerms(builder, filter.FilterTerms, filter.FilterMode, driver)
+	if err != nil {
+		return builder, err
+	}
+	builder, err = applySearchTerms(builder, filter.SearchTerms, filter.SearchMode)
+	if err != nil {
+		return builder, err
+	}
+	if filter.Prefix != "" {
+		builder = builder.Where(sq.Expr(`command LIKE ? ESCAPE '\'`, escapeLike(filter.Prefix)+"%"))
+	}
 	// add user permissions filter later
 	if filter.StartTime != nil {
 		builder = builder.Where(sq.GtOrEq{"ts": *filter.StartTime})
@@ -267,27 +676,33 @@ func applyFilters(builder sq.StatementBuilderType, filter *domain.LogFilter) sq.
 	if filter.EndTime != nil {
 		builder = builder.Where(sq.LtOrEq{"ts": *filter.EndTime})
 	}
-	return builder
+	return builder, nil
 }
 
-func applyFilterTerms(builder sq.StatementBuilderType, filterTerms map[string]domain.FilterValues, mode domain.Mode) sq.StatementBuilderType {
+func applyFilterTerms(builder sq.StatementBuilderType, filterTerms map[string]domain.FilterValues, mode domain.Mode, driver string) (sq.StatementBuilderType, error) {
 	if len(filterTerms) == 0 {
-		return builder
+		return builder, nil
 	}
 	var allFieldConditions []sq.Sqlizer
 	for field, values := range filterTerms {
-		metadata, ok := columnMetadata[field]
-		if !ok || !metadata.IsExact {
-			continue
+		column, metadata, ok := filterColumn(field)
+		if !ok {
+			return builder, fmt.Errorf("unknown filter field %q", field)
+		}
+		if !metadata.IsExact {
+			return builder, fmt.Errorf("field %q can't be filtered on", field)
 		}
 		var fieldConditions []sq.Sqlizer
 		for _, val := range values.Values {
+			if condition, ok := jsonCondition(field, val, driver); ok {
+				fieldConditions = append(fieldConditions, condition)
+				continue
+			}
 			typedValue, err := convertValue(val, metadata.Type)
 			if err != nil {
-				fmt.Printf("error converting value for field '%s': %v\n", field, err)
-				continue
+				return builder, fmt.Errorf("invalid value %q for field %q: %w", val, field, err)
 			}
-			fieldConditions = append(fieldConditions, sq.Eq{field: typedValue})
+			fieldConditions = append(fieldConditions, sq.Eq{column: typedValue})
 		}
 		if len(fieldConditions) > 0 {
 			allFieldConditions = append(allFieldConditions, sq.Or(fieldConditions))
@@ -300,23 +715,26 @@ func applyFilterTerms(builder sq.StatementBuilderType, filterTerms map[string]do
 			builder = builder.Where(sq.Or(allFieldConditions))
 		}
 	}
-	return builder
+	return builder, nil
 }
 
-func applySearchTerms(builder sq.StatementBuilderType, searchTerms map[string]domain.SearchValues, mode domain.Mode) sq.StatementBuilderType {
+func applySearchTerms(builder sq.StatementBuilderType, searchTerms map[string]domain.SearchValues, mode domain.Mode) (sq.StatementBuilderType, error) {
 	if len(searchTerms) == 0 {
-		return builder
+		return builder, nil
 	}
 	var allFieldConditions []sq.Sqlizer
 	for field, values := range searchTerms {
-		metadata, ok := columnMetadata[field]
-		if !ok || !metadata.IsFuzzy {
-			continue
+		column, metadata, ok := filterColumn(field)
+		if !ok {
+			return builder, fmt.Errorf("unknown search field %q", field)
+		}
+		if !metadata.IsFuzzy {
+			return builder, fmt.Errorf("field %q can't be searched, use an exact filter", field)
 		}
 		var fieldConditions []sq.Sqlizer
 		for _, val := range values.Values {
 			likeTerm := "%" + val + "%"
-			condition := sq.Expr("LOWER("+field+") LIKE LOWER(?)", likeTerm) // lower case index for faster fuzzy search (also sqlite doesn't support ILIKE)
+			condition := sq.Expr("LOWER("+column+") LIKE LOWER(?)", likeTerm) // lower case index for faster fuzzy search (also sqlite doesn't support ILIKE)
 			fieldConditions = append(fieldConditions, condition)
 		}
 		if len(fieldConditions) > 0 {
@@ -330,7 +748,70 @@ func applySearchTerms(builder sq.StatementBuilderType, searchTerms map[string]do
 			builder = builder.Where(sq.Or(allFieldConditions))
 		}
 	}
-	return builder
+	return builder, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// escapeLike makes LIKE match value literally (with ESCAPE '\')
+func escapeLike(value string) string {
+	return likeEscaper.Replace(value)
+}
+
+// jsonColumns are filtered and searched on their keys as <column>.<key>[.<key>...], eg. context.toolchains.go
+var jsonColumns = map[string]struct{}{"context": {}, "labels": {}}
+
+var jsonKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// filterColumn resolves a filter or search field to the sql expression to compare against
+func filterColumn(field string) (string, columnInfo, bool) {
+	if metadata, ok := columnMetadata[field]; ok {
+		return field, metadata, true
+	}
+	path, ok := jsonPath(field)
+	return path, columnInfo{Type: "", IsExact: true, IsFuzzy: true}, ok
+}
+
+// jsonPath turns context.toolchains.go into context->'toolchains'->>'go' (postgres and sqlite share the operators).
+// Keys are quoted into the query so anything but plain names is rejected.
+func jsonPath(field string) (string, bool) {
+	column, rest, ok := strings.Cut(field, ".")
+	if _, isJSON := jsonColumns[column]; !ok || !isJSON {
+		return "", false
+	}
+	keys := strings.Split(rest, ".")
+	path := column
+	for i, key := range keys {
+		if !jsonKeyPattern.MatchString(key) {
+			return "", false
+		}
+		op := "->"
+		if i == len(keys)-1 {
+			op = "->>" // the last step extracts text to compare with
+		}
+		path += op + "'" + key + "'"
+	}
+	return path, true
+}
+
+// jsonCondition matches a json key exactly in a way the column's index can serve. Postgres uses containment for the GIN
+// index, eg. labels.team=payments becomes labels @> '{"team":"payments"}'. Sqlite compares the extracted value, stating
+// the column isn't NULL so the partial index over labeled entries applies.
+func jsonCondition(field, value, driver string) (sq.Sqlizer, bool) {
+	path, ok := jsonPath(field)
+	if !ok {
+		return nil, false
+	}
+	column, rest, _ := strings.Cut(field, ".")
+	if driver != "pgx" {
+		return sq.And{sq.NotEq{column: nil}, sq.Eq{path: value}}, true
+	}
+	keys := strings.Split(rest, ".")
+	var nested interface{} = value
+	for i := len(keys) - 1; i >= 0; i-- {
+		nested = map[string]interface{}{keys[i]: nested}
+	}
+	return sq.Expr(column+" @> ?::jsonb", jsonColumn(nested)), true
 }
 
 func convertValue(val string, targetType interface{}) (interface{}, error) {
@@ -370,34 +851,119 @@ func validateOrdering(ordering *string) (string, string) {
 
 func scanLogEntry(scanner interface {
 	Scan(dest ...interface{}) error
-}) (*domain.LogEntry, error) {
+}, extra ...interface{}) (*domain.LogEntry, error) { // extra receives any columns selected after logColumns
 	var entry domain.LogEntry
-	err := scanner.Scan(
+	var exitCode, pid, euid sql.NullInt32
+	var uptime sql.NullInt64
+	var cwd, prevCwd, term, hostname, sshClient, tty, gitRoot, gitBranch, gitCommit, gitStatus sql.NullString
+	var gitRepo, redacted sql.NullBool
+	var sessionID, status, pipeStatus, signal sql.NullString
+	var endTS sql.NullInt64
+	var usage [7]sql.NullInt64
+	var runtime sql.NullString
+	var kubeContext, kubeNamespace, awsProfile, awsRegion, gcloudConfig, terraformWorkspace sql.NullString
+	var labels sql.NullString
+	dest := []interface{}{
 		&entry.EventID,
 		&entry.Command,
-		&entry.ExitCode,
+		&exitCode,
 		&entry.Timestamp,
-		&entry.Shell_PID,
-		&entry.ShellUptime,
-		&entry.WorkingDirectory,
-		&entry.PrevWorkingDirectory,
+		&pid,
+		&uptime,
+		&cwd,
+		&prevCwd,
 		&entry.User,
-		&entry.EUID,
-		&entry.Term,
-		&entry.Hostname,
-		&entry.SSHClient,
-		&entry.TTY,
-		&entry.GitRepo,
-		&entry.GitRepoRoot,
-		&entry.GitBranch,
-		&entry.GitCommit,
-		&entry.GitStatus,
+		&euid,
+		&term,
+		&hostname,
+		&sshClient,
+		&tty,
+		&gitRepo,
+		&gitRoot,
+		&gitBranch,
+		&gitCommit,
+		&gitStatus,
 		&entry.LoggedSuccessfully,
-	)
+		&redacted,
+		&sessionID,
+		&status,
+		&endTS,
+		&pipeStatus,
+		&signal,
+	}
+	for i := range usage {
+		dest = append(dest, &usage[i])
+	}
+	dest = append(dest, &runtime, &kubeContext, &kubeNamespace, &awsProfile, &awsRegion, &gcloudConfig, &terraformWorkspace, &labels)
 
+	err := scanner.Scan(append(dest, extra...)...)
 	if err != nil {
 		return nil, err
 	}
 
+	// NULL columns weren't captured so they're reported back as disabled
+	nullable := func(col string, valid bool) {
+		if !valid {
+			entry.DisabledFields = append(entry.DisabledFields, col)
+		}
+	}
+	entry.ExitCode, entry.Shell_PID, entry.EUID = exitCode.Int32, pid.Int32, euid.Int32
+	nullable("exit_code", exitCode.Valid)
+	nullable("shell_pid", pid.Valid)
+	entry.ShellUptime = uptime.Int64
+	nullable("shell_uptime", uptime.Valid)
+	entry.WorkingDirectory, entry.PrevWorkingDirectory = cwd.String, prevCwd.String
+	nullable("cwd", cwd.Valid)
+	nullable("prev_cwd", prevCwd.Valid)
+	nullable("euid", euid.Valid)
+	entry.Term, entry.Hostname, entry.SSHClient, entry.TTY = term.String, hostname.String, sshClient.String, tty.String
+	nullable("term", term.Valid)
+	nullable("hostname", hostname.Valid)
+	nullable("ssh_client", sshClient.Valid)
+	nullable("tty", tty.Valid)
+	entry.GitRepo = gitRepo.Bool
+	nullable("git_repo", gitRepo.Valid)
+	entry.GitRepoRoot, entry.GitBranch, entry.GitCommit, entry.GitStatus = gitRoot.String, gitBranch.String, gitCommit.String, gitStatus.String
+	nullable("git_repo_root", gitRoot.Valid)
+	nullable("git_branch", gitBranch.Valid)
+	nullable("git_commit", gitCommit.Valid)
+	nullable("git_status", gitStatus.Valid)
+	entry.Redacted, entry.SessionID = redacted.Bool, sessionID.String
+	entry.Status, entry.EndTimestamp = status.String, endTS.Int64
+	if entry.Status == "" {
+		entry.Status = domain.StatusCompleted
+	}
+	if entry.PipeStatus, err = parsePipeStatus(pipeStatus.String); err != nil {
+		return nil, err
+	}
+	entry.TerminationSignal = signal.String
+	if usage[0].Valid {
+		entry.Usage = &domain.ResourceUsage{
+			UserTimeMicros:         usage[0].Int64,
+			SysTimeMicros:          usage[1].Int64,
+			MaxRSSKB:               usage[2].Int64,
+			VoluntaryCtxSwitches:   usage[3].Int64,
+			InvoluntaryCtxSwitches: usage[4].Int64,
+			BlockInputOps:          usage[5].Int64,
+			BlockOutputOps:         usage[6].Int64,
+		}
+	}
+	if runtime.Valid {
+		entry.Context = &domain.RuntimeContext{}
+		if err := json.Unmarshal([]byte(runtime.String), entry.Context); err != nil {
+			return nil, fmt.Errorf("failed to decode context of %s: %w", entry.EventID, err)
+		}
+	}
+	// pipestatus and context are NULL when empty too, and NULL here just means no cluster/account/workspace was in
+	// use, so none of these are reported as disabled
+	entry.KubeContext, entry.KubeNamespace = kubeContext.String, kubeNamespace.String
+	entry.AWSProfile, entry.AWSRegion = awsProfile.String, awsRegion.String
+	entry.GCloudConfig, entry.TerraformWorkspace = gcloudConfig.String, terraformWorkspace.String
+	if labels.Valid {
+		if err := json.Unmarshal([]byte(labels.String), &entry.Labels); err != nil {
+			return nil, fmt.Errorf("failed to decode labels of %s: %w", entry.EventID, err)
+		}
+	}
+
 	return &entry, nil
 }
@@ -1,56 +1,295 @@
 package database
 
 import (
+	"cmp"
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/WillRabalais04/terminalLog/internal/core/domain"
 	"github.com/WillRabalais04/terminalLog/internal/core/ports"
+	"github.com/google/uuid"
 )
 
+const pullPageSize = 500
+
+// pullOverlap is how far behind its watermark each pull starts again (in nanoseconds, like updated_at). A write that
+// stamped updated_at but committed after a pull had moved past it (or came from a server with a slower clock) would
+// otherwise never be pulled. Re-pulled rows are upserted by id so reading them twice is harmless.
+const pullOverlap = int64(time.Minute)
+
+type Role int
+
+const (
+	RolePrimary  Role = iota // main store, written and read first
+	RoleMirror               // replica written alongside the primary
+	RoleFallback             // only written when the replicas fail, flushed into them later (eg. sqlite cache)
+	RoleReadOnly             // only ever read from
+)
+
+type WritePolicy int
+
+const (
+	WriteFirstSuccess     WritePolicy = iota // stop at the first replica that accepts the write, mirrors are still written async
+	WriteAllMustSucceed                      // every primary and mirror has to accept the write
+	WritePrimaryThenAsync                    // write the primary then the mirrors in the background
+)
+
+type ReadPolicy int
+
+const (
+	ReadFirstHealthy ReadPolicy = iota // answer from the first target that doesn't error
+	ReadMerged                         // combine the results of every target
+)
+
+type Target struct {
+	Name string
+	Repo ports.LogRepositoryPort
+	Role Role
+}
+
 type MultiRepo struct {
-	cache  ports.LogRepositoryPort
-	remote ports.LogRepositoryPort
+	targets      []Target
+	writePolicy  WritePolicy
+	readPolicy   ReadPolicy
+	syncUser     string         // user whose history is pulled down from remote (pulling is off if empty)
+	mirrorWrites sync.WaitGroup // in flight async mirror writes
 }
 
+// NewMultiRepo replicates to remote and falls back to cache while it's unreachable
 func NewMultiRepo(cache, remote ports.LogRepositoryPort) *MultiRepo {
-	return &MultiRepo{cache: cache, remote: remote}
+	var targets []Target
+	if remote != nil {
+		targets = append(targets, Target{Name: "remote", Repo: remote, Role: RolePrimary})
+	}
+	targets = append(targets, Target{Name: "cache", Repo: cache, Role: RoleFallback})
+	return NewMultiRepoWithPolicies(targets, WriteFirstSuccess, ReadFirstHealthy)
+}
+
+func NewMultiRepoWithPolicies(targets []Target, writePolicy WritePolicy, readPolicy ReadPolicy) *MultiRepo {
+	ordered := make([]Target, 0, len(targets)) // keep targets sorted by role so iteration order is the priority order
+	for _, role := range []Role{RolePrimary, RoleMirror, RoleFallback, RoleReadOnly} {
+		for _, target := range targets {
+			if target.Role == role && target.Repo != nil {
+				ordered = append(ordered, target)
+			}
+		}
+	}
+	return &MultiRepo{targets: ordered, writePolicy: writePolicy, readPolicy: readPolicy}
+}
+
+func ParseWritePolicy(policy string) (WritePolicy, error) {
+	switch policy {
+	case "", "first-success":
+		return WriteFirstSuccess, nil
+	case "all-must-succeed":
+		return WriteAllMustSucceed, nil
+	case "primary-then-async":
+		return WritePrimaryThenAsync, nil
+	default:
+		return 0, fmt.Errorf("invalid write policy %q (should be first-success, all-must-succeed or primary-then-async)", policy)
+	}
+}
+
+func ParseReadPolicy(policy string) (ReadPolicy, error) {
+	switch policy {
+	case "", "first-healthy":
+		return ReadFirstHealthy, nil
+	case "merged":
+		return ReadMerged, nil
+	default:
+		return 0, fmt.Errorf("invalid read policy %q (should be first-healthy or merged)", policy)
+	}
 }
 
 func (r *MultiRepo) GetCache() ports.LogRepositoryPort {
-	return r.cache
+	if fallbacks := r.withRoles(RoleFallback); len(fallbacks) > 0 {
+		return fallbacks[0].Repo
+	}
+	return nil
 }
 func (r *MultiRepo) GetRemote() ports.LogRepositoryPort {
-	return r.remote
+	if primaries := r.withRoles(RolePrimary); len(primaries) > 0 {
+		return primaries[0].Repo
+	}
+	return nil
+}
+
+func (r *MultiRepo) SetSyncUser(user string) {
+	r.syncUser = user
 }
 
+// Wait blocks until background mirror writes are done (call before exiting)
+func (r *MultiRepo) Wait() {
+	r.mirrorWrites.Wait()
+}
+
+func (r *MultiRepo) withRoles(roles ...Role) []Target {
+	var targets []Target
+	for _, target := range r.targets {
+		if slices.Contains(roles, target.Role) {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// writeFunc is a LogRepositoryPort write method (Log or Complete)
+type writeFunc func(repo ports.LogRepositoryPort, ctx context.Context, entries []*domain.LogEntry) error
+
 func (r *MultiRepo) Log(ctx context.Context, entries []*domain.LogEntry) error {
-	if r.remote != nil {
-		err := r.remote.Log(ctx, entries)
+	return r.write(ctx, entries, ports.LogRepositoryPort.Log)
+}
+
+// Complete goes wherever Log would have, the fallback inserts the whole entry if the start it logged was already flushed
+func (r *MultiRepo) Complete(ctx context.Context, entries []*domain.LogEntry) error {
+	return r.write(ctx, entries, ports.LogRepositoryPort.Complete)
+}
+
+func (r *MultiRepo) write(ctx context.Context, entries []*domain.LogEntry, write writeFunc) error {
+	for _, entry := range entries { // ids are assigned up front so every target stores the same one
+		if entry.EventID == "" {
+			entry.EventID = uuid.New().String()
+		}
+	}
+
+	err := r.writeReplicas(ctx, entries, write)
+	if err == nil {
+		return nil
+	}
+
+	fallbacks := r.withRoles(RoleFallback)
+	if len(fallbacks) == 0 {
+		return err
+	}
+	if len(r.withRoles(RolePrimary, RoleMirror)) > 0 {
+		log.Printf("replica log failed, falling back to cache: %v", err)
+	}
+	return firstSuccess(fallbacks, func(target Target) error {
+		return write(target.Repo, ctx, entries)
+	})
+}
+
+func (r *MultiRepo) writeReplicas(ctx context.Context, entries []*domain.LogEntry, write writeFunc) error {
+	replicas := r.withRoles(RolePrimary, RoleMirror)
+
+	switch r.writePolicy {
+	case WriteAllMustSucceed:
+		if len(replicas) == 0 {
+			return fmt.Errorf("no replicas configured")
+		}
+		var errs []error
+		for _, target := range replicas {
+			if err := write(target.Repo, ctx, entries); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", target.Name, err))
+			}
+		}
+		return errors.Join(errs...)
+	case WritePrimaryThenAsync:
+		if err := firstSuccess(r.withRoles(RolePrimary), func(target Target) error {
+			return write(target.Repo, ctx, entries)
+		}); err != nil {
+			return err
+		}
+		r.writeAsync(ctx, r.withRoles(RoleMirror), entries, write)
+		return nil
+	default:
+		if len(replicas) == 0 {
+			return fmt.Errorf("no targets configured")
+		}
+		var errs []error
+		for i, target := range replicas {
+			err := write(target.Repo, ctx, entries)
+			if err == nil { // the mirrors after the one that took it still get every entry, just in the background
+				r.writeAsync(ctx, replicas[i+1:], entries, write)
+				return nil
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", target.Name, err))
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// writeAsync writes to each mirror in targets in the background (Wait blocks until they're done)
+func (r *MultiRepo) writeAsync(ctx context.Context, targets []Target, entries []*domain.LogEntry, write writeFunc) {
+	for _, target := range targets {
+		if target.Role != RoleMirror {
+			continue
+		}
+		r.mirrorWrites.Add(1)
+		go func() {
+			defer r.mirrorWrites.Done()
+			if err := write(target.Repo, context.WithoutCancel(ctx), entries); err != nil {
+				log.Printf("async mirror log to %s failed: %v", target.Name, err)
+			}
+		}()
+	}
+}
+
+func firstSuccess(targets []Target, fn func(Target) error) error {
+	if len(targets) == 0 {
+		return fmt.Errorf("no targets configured")
+	}
+	var errs []error
+	for _, target := range targets {
+		err := fn(target)
 		if err == nil {
 			return nil
 		}
-		log.Printf("remote log failed, falling back to cache: %v", err)
+		errs = append(errs, fmt.Errorf("%s: %w", target.Name, err))
 	}
-	return r.cache.Log(ctx, entries)
+	return errors.Join(errs...)
 }
 
+// FlushCache pushes entries that were written to fallbacks into the replicas
 func (r *MultiRepo) FlushCache(ctx context.Context) ([]*domain.LogEntry, error) {
-	if r.remote == nil {
+	if len(r.withRoles(RolePrimary, RoleMirror)) == 0 {
 		return nil, nil
 	}
 
-	entries, err := r.cache.List(ctx, &domain.LogFilter{})
+	var flushed []*domain.LogEntry
+	for _, fallback := range r.withRoles(RoleFallback) {
+		entries, err := r.flush(ctx, fallback.Repo)
+		if err != nil {
+			return flushed, err
+		}
+		flushed = append(flushed, entries...)
+	}
+	return flushed, nil
+}
+
+func (r *MultiRepo) flush(ctx context.Context, cache ports.LogRepositoryPort) ([]*domain.LogEntry, error) {
+	if err := r.flushTombstones(ctx, cache); err != nil { // deletes go first so remote refuses any stale copies below
+		return nil, err
+	}
+	if err := r.flushSessions(ctx, cache); err != nil { // sessions go before the entries that heartbeat them
+		return nil, err
+	}
+
+	pending := domain.NewFilterBuilder().AddFilterTerm("synced", "false").Build() // pulled entries already live on remote
+	entries, err := cache.List(ctx, pending)
 	if err != nil {
 		return nil, fmt.Errorf("reading cache failed: %w", err)
 	}
 	if len(entries) == 0 {
 		return nil, nil
 	}
+	if outputs, ok := cache.(ports.LogOutputPort); ok { // lists don't carry output so attach it before pushing
+		for _, entry := range entries {
+			if entry.Output, err = outputs.GetOutput(ctx, entry.EventID); err != nil {
+				return nil, fmt.Errorf("reading cached output failed: %w", err)
+			}
+		}
+	}
 
-	if err := r.remote.Log(ctx, entries); err != nil {
+	// completing rather than logging also finishes remote copies of entries that were still running when flushed before
+	if err := r.writeReplicas(ctx, entries, ports.LogRepositoryPort.Complete); err != nil {
 		return nil, fmt.Errorf("failed to push cache entries to remote: %w", err)
 	}
 
@@ -59,12 +298,15 @@ func (r *MultiRepo) FlushCache(ctx context.Context) ([]*domain.LogEntry, error)
 		succeededIDs[i] = entry.EventID
 	}
 
-	filter := domain.NewFilterBuilder().SetFilterMode(domain.OR)
-	for _, id := range succeededIDs {
-		filter.AddFilterTerm("event_id", id)
+	if mirror, ok := cache.(ports.LogMirrorPort); ok { // plain deletes would tombstone the flushed entries
+		err = mirror.Evict(ctx, succeededIDs)
+	} else {
+		filter := domain.NewFilterBuilder().SetFilterMode(domain.OR)
+		for _, id := range succeededIDs {
+			filter.AddFilterTerm("event_id", id)
+		}
+		_, err = cache.DeleteMultiple(ctx, filter.Build())
 	}
-
-	_, err = r.cache.DeleteMultiple(ctx, filter.Build())
 	if err != nil {
 		log.Printf("CRITICAL: failed to delete flushed entries from cache: %v", err)
 		return nil, err
@@ -74,64 +316,413 @@ func (r *MultiRepo) FlushCache(ctx context.Context) ([]*domain.LogEntry, error)
 	return entries, nil
 }
 
+func (r *MultiRepo) flushSessions(ctx context.Context, cache ports.LogRepositoryPort) error {
+	remote, ok := r.GetRemote().(ports.LogSessionPort)
+	if !ok {
+		return nil
+	}
+	mirror, ok := cache.(ports.LogMirrorPort)
+	if !ok {
+		return nil
+	}
+
+	sessions, err := mirror.PendingSessions(ctx)
+	if err != nil {
+		return fmt.Errorf("reading cache sessions failed: %w", err)
+	}
+	if len(sessions) == 0 {
+		return nil
+	}
+	if err := remote.UpsertSessions(ctx, sessions); err != nil {
+		return fmt.Errorf("failed to push sessions to remote: %w", err)
+	}
+	return mirror.MarkSessionsSynced(ctx, sessions)
+}
+
+func (r *MultiRepo) flushTombstones(ctx context.Context, cache ports.LogRepositoryPort) error {
+	syncer, ok := r.GetRemote().(ports.LogSyncPort)
+	if !ok {
+		return nil
+	}
+	mirror, ok := cache.(ports.LogMirrorPort)
+	if !ok {
+		return nil
+	}
+
+	tombstones, err := mirror.PendingTombstones(ctx)
+	if err != nil {
+		return fmt.Errorf("reading cache tombstones failed: %w", err)
+	}
+	if len(tombstones) == 0 {
+		return nil
+	}
+
+	if err := syncer.PushTombstones(ctx, tombstones); err != nil {
+		return fmt.Errorf("failed to push tombstones to remote: %w", err)
+	}
+
+	ids := make([]string, len(tombstones))
+	for i, tombstone := range tombstones {
+		ids[i] = tombstone.EventID
+	}
+	if err := mirror.MarkTombstonesSynced(ctx, ids); err != nil {
+		return fmt.Errorf("failed to mark flushed tombstones: %w", err)
+	}
+
+	log.Printf("successfully flushed %d tombstones.", len(tombstones))
+	return nil
+}
+
+// Pull downloads the sync user's entries written to remote since the last pull (including other devices') into the cache.
+func (r *MultiRepo) Pull(ctx context.Context) (int, error) {
+	if r.GetRemote() == nil || r.GetCache() == nil || r.syncUser == "" {
+		return 0, nil
+	}
+	syncer, ok := r.GetRemote().(ports.LogSyncPort)
+	if !ok {
+		return 0, fmt.Errorf("remote repo does not support pulling")
+	}
+	mirror, ok := r.GetCache().(ports.LogMirrorPort)
+	if !ok {
+		return 0, fmt.Errorf("cache repo does not support storing pulled entries")
+	}
+
+	watermarkName := "pull:" + r.syncUser
+	stored, err := mirror.GetWatermark(ctx, watermarkName)
+	if err != nil {
+		return 0, fmt.Errorf("reading pull watermark failed: %w", err)
+	}
+
+	pulled := 0
+	since := max(stored-pullOverlap, 0) // the stored watermark never moves back, only where reading starts
+	for {
+		entries, watermark, err := syncer.Pull(ctx, r.syncUser, since, pullPageSize)
+		if err != nil {
+			return pulled, fmt.Errorf("failed to pull entries from remote: %w", err)
+		}
+		if err := mirror.Upsert(ctx, entries); err != nil {
+			return pulled, fmt.Errorf("failed to store pulled entries in cache: %w", err)
+		}
+		if err := mirror.SetWatermark(ctx, watermarkName, max(watermark, stored)); err != nil {
+			return pulled, fmt.Errorf("failed to store pull watermark: %w", err)
+		}
+		pulled += len(entries)
+		since = watermark
+
+		if len(entries) < pullPageSize {
+			break
+		}
+	}
+
+	if pulled > 0 {
+		log.Printf("successfully pulled %d entries.", pulled)
+	}
+
+	if err := r.pullTombstones(ctx, syncer, mirror); err != nil {
+		return pulled, err
+	}
+	return pulled, nil
+}
+
+func (r *MultiRepo) pullTombstones(ctx context.Context, syncer ports.LogSyncPort, mirror ports.LogMirrorPort) error {
+	watermarkName := "tombstones:" + r.syncUser
+	stored, err := mirror.GetWatermark(ctx, watermarkName)
+	if err != nil {
+		return fmt.Errorf("reading tombstone watermark failed: %w", err)
+	}
+
+	since := max(stored-pullOverlap, 0)
+	for {
+		tombstones, watermark, err := syncer.PullTombstones(ctx, r.syncUser, since, pullPageSize)
+		if err != nil {
+			return fmt.Errorf("failed to pull tombstones from remote: %w", err)
+		}
+		if err := mirror.UpsertTombstones(ctx, tombstones); err != nil {
+			return fmt.Errorf("failed to apply pulled tombstones: %w", err)
+		}
+		if err := mirror.SetWatermark(ctx, watermarkName, max(watermark, stored)); err != nil {
+			return fmt.Errorf("failed to store tombstone watermark: %w", err)
+		}
+		since = watermark
+
+		if len(tombstones) < pullPageSize {
+			return nil
+		}
+	}
+}
+
+// Sync pushes pending cache entries to remote then pulls down anything new.
+func (r *MultiRepo) Sync(ctx context.Context) error {
+	if _, err := r.FlushCache(ctx); err != nil {
+		return err
+	}
+	_, err := r.Pull(ctx)
+	return err
+}
+
 func (r *MultiRepo) StartCacheFlusher(ctx context.Context, interval time.Duration, quit <-chan struct{}) {
 	log.Println("starting background cache flusher...")
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	r.FlushCache(ctx)
+	r.Sync(ctx)
 
 	for {
 		select {
 		case <-ticker.C:
 			log.Println("checking for cache entries to flush...")
-			if _, err := r.FlushCache(ctx); err != nil {
-				log.Printf("background flush failed: %v", err)
+			if err := r.Sync(ctx); err != nil {
+				log.Printf("background sync failed: %v", err)
 			}
 		case <-quit:
 			log.Println("stopping cache flusher.")
-			r.FlushCache(ctx)
+			r.Sync(ctx)
 			return
 		}
 	}
 }
 
 func (r *MultiRepo) Get(ctx context.Context, id string) (*domain.LogEntry, error) {
-	entry, err := r.remote.Get(ctx, id)
+	var entry *domain.LogEntry
+	err := firstSuccess(r.targets, func(target Target) error {
+		found, err := target.Repo.Get(ctx, id)
+		entry = found
+		return err
+	})
 	if err != nil {
-		return r.cache.Get(ctx, id)
+		return nil, err
 	}
 	return entry, nil
 }
 
-func (r *MultiRepo) List(ctx context.Context, filters *domain.LogFilter) ([]*domain.LogEntry, error) {
-	entries, err := r.remote.List(ctx, filters)
+func (r *MultiRepo) GetOutput(ctx context.Context, eventID string) (*domain.CommandOutput, error) {
+	var output *domain.CommandOutput
+	err := firstSuccess(r.targets, func(target Target) error {
+		outputs, ok := target.Repo.(ports.LogOutputPort)
+		if !ok {
+			return fmt.Errorf("doesn't store command output")
+		}
+		found, err := outputs.GetOutput(ctx, eventID)
+		if err == nil && found == nil {
+			return fmt.Errorf("no output for %s", eventID) // may still be in another target (eg. an unflushed cache)
+		}
+		output = found
+		return err
+	})
 	if err != nil {
-		return r.cache.List(ctx, filters)
+		return nil, err
 	}
-	return entries, nil
+	return output, nil
+}
+
+// UpsertSessions writes to the first replica that tracks sessions, falling back to the cache (flushed later) while it's down
+func (r *MultiRepo) UpsertSessions(ctx context.Context, sessions []*domain.Session) error {
+	upsert := func(target Target) error {
+		sessionRepo, ok := target.Repo.(ports.LogSessionPort)
+		if !ok {
+			return fmt.Errorf("doesn't track sessions")
+		}
+		return sessionRepo.UpsertSessions(ctx, sessions)
+	}
+
+	err := firstSuccess(r.withRoles(RolePrimary, RoleMirror), upsert)
+	if err == nil || len(r.withRoles(RoleFallback)) == 0 {
+		return err
+	}
+	return firstSuccess(r.withRoles(RoleFallback), upsert)
 }
 
+func (r *MultiRepo) ListSessions(ctx context.Context, filter *domain.SessionFilter) ([]*domain.Session, error) {
+	var sessions []*domain.Session
+	err := firstSuccess(r.targets, func(target Target) error {
+		sessionRepo, ok := target.Repo.(ports.LogSessionPort)
+		if !ok {
+			return fmt.Errorf("doesn't track sessions")
+		}
+		found, err := sessionRepo.ListSessions(ctx, filter)
+		sessions = found
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (r *MultiRepo) RankDirectories(ctx context.Context, filter *domain.DirectoryFilter) ([]*domain.DirectoryRank, error) {
+	var directories []*domain.DirectoryRank
+	err := firstSuccess(r.targets, func(target Target) error {
+		directoryRepo, ok := target.Repo.(ports.LogDirectoryPort)
+		if !ok {
+			return fmt.Errorf("doesn't rank directories")
+		}
+		found, err := directoryRepo.RankDirectories(ctx, filter)
+		directories = found
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return directories, nil
+}
+
+func (r *MultiRepo) List(ctx context.Context, filters *domain.LogFilter) ([]*domain.LogEntry, error) {
+	if filters == nil {
+		filters = &domain.LogFilter{}
+	}
+	if r.readPolicy != ReadMerged {
+		var entries []*domain.LogEntry
+		err := firstSuccess(r.targets, func(target Target) error {
+			found, err := target.Repo.List(ctx, filters)
+			entries = found
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return entries, nil
+	}
+
+	perTarget := *filters // every target has to return enough rows to fill the page after merging
+	perTarget.Offset = 0
+	if filters.Limit > 0 {
+		perTarget.Limit = filters.Limit + filters.Offset
+	}
+
+	var results [][]*domain.LogEntry
+	var errs []error
+	for _, target := range r.targets {
+		entries, err := target.Repo.List(ctx, &perTarget)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", target.Name, err))
+			continue
+		}
+		results = append(results, entries)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("list failed on every target: %w", errors.Join(errs...))
+	}
+	return mergeEntries(results, filters), nil
+}
+
+// mergeEntries dedupes entries by id and orders them the way each target's List did (by the filter's column and direction)
+func mergeEntries(results [][]*domain.LogEntry, filter *domain.LogFilter) []*domain.LogEntry {
+	seen := make(map[string]struct{})
+	var merged []*domain.LogEntry
+	for _, entries := range results {
+		for _, entry := range entries {
+			if _, ok := seen[entry.EventID]; ok {
+				continue
+			}
+			seen[entry.EventID] = struct{}{}
+			merged = append(merged, entry)
+		}
+	}
+
+	orderBy, orderDir := validateOrdering(filter.OrderBy)
+	column := slices.Index(logColumns, orderBy)
+	if column < 0 { // synced only exists on the local table, not on entries
+		column = slices.Index(logColumns, defaultOrdering)
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		order := compareColumn(entryValues(merged[i])[column], entryValues(merged[j])[column])
+		if orderDir == "ASC" {
+			return order < 0
+		}
+		return order > 0
+	})
+
+	if filter.Offset >= uint64(len(merged)) {
+		return nil
+	}
+	merged = merged[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < uint64(len(merged)) {
+		merged = merged[:filter.Limit]
+	}
+	return merged
+}
+
+// compareColumn compares two of entryValues' column values, NULLs sorting first like they do in sqlite
+func compareColumn(a, b interface{}) int {
+	a, b = columnValue(a), columnValue(b)
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	switch a := a.(type) {
+	case int64:
+		return cmp.Compare(a, b.(int64))
+	case string:
+		return cmp.Compare(a, b.(string))
+	case bool:
+		if a == b.(bool) {
+			return 0
+		} else if a {
+			return 1
+		}
+		return -1
+	}
+	return 0
+}
+
+// columnValue unwraps NULLs and widens integers so values of one column always share a type
+func columnValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case sql.NullString:
+		if !v.Valid {
+			return nil
+		}
+		return v.String
+	case sql.NullInt64:
+		if !v.Valid {
+			return nil
+		}
+		return v.Int64
+	case int32:
+		return int64(v)
+	}
+	return value
+}
+
+// deletes go to every writable target so no copy is left behind
 func (r *MultiRepo) Delete(ctx context.Context, id string) (*domain.LogEntry, error) {
-	deleted, err1 := r.remote.Delete(ctx, id)
-	if err1 != nil || deleted == nil {
-		deleted, err2 := r.cache.Delete(ctx, id)
-		if err2 != nil {
-			return nil, fmt.Errorf("could not access local db: %v", err2)
+	var deleted *domain.LogEntry
+	var errs []error
+	writable := r.withRoles(RolePrimary, RoleMirror, RoleFallback)
+	for _, target := range writable {
+		entry, err := target.Repo.Delete(ctx, id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", target.Name, err))
+			continue
+		}
+		if deleted == nil {
+			deleted = entry
 		}
-		return deleted, nil
+	}
+	if len(errs) == len(writable) {
+		return nil, fmt.Errorf("delete failed on every target: %w", errors.Join(errs...))
 	}
 	return deleted, nil
 }
 
 func (r *MultiRepo) DeleteMultiple(ctx context.Context, filters *domain.LogFilter) ([]*domain.LogEntry, error) {
-	deleted, err1 := r.remote.DeleteMultiple(ctx, filters)
-	if err1 != nil || len(deleted) == 0 {
-		deleted, err2 := r.cache.DeleteMultiple(ctx, filters)
-		if err2 != nil {
-			return nil, fmt.Errorf("delete multiple failed: could not access local or remote db: %v %v", err1, err2)
+	var results [][]*domain.LogEntry
+	var errs []error
+	writable := r.withRoles(RolePrimary, RoleMirror, RoleFallback)
+	for _, target := range writable {
+		deleted, err := target.Repo.DeleteMultiple(ctx, filters)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", target.Name, err))
+			continue
 		}
-		return deleted, nil
+		results = append(results, deleted)
 	}
-	return deleted, nil
+	if len(errs) == len(writable) {
+		return nil, fmt.Errorf("delete multiple failed on every target: %w", errors.Join(errs...))
+	}
+	return mergeEntries(results, &domain.LogFilter{OrderBy: filters.OrderBy}), nil
 }
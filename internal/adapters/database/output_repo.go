@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/WillRabalais04/terminalLog/internal/core/domain"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+func (r *LogRepo) GetOutput(ctx context.Context, eventID string) (*domain.CommandOutput, error) {
+	sqlStr, args, err := r.sb.Select("event_id", "output", "truncated", "total_bytes").From("log_outputs").
+		Where(sq.Eq{"event_id": eventID}).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build output query: %w", err)
+	}
+
+	var output domain.CommandOutput
+	var truncated sql.NullBool
+	var totalBytes sql.NullInt64
+	err = r.db.QueryRowContext(ctx, sqlStr, args...).Scan(&output.EventID, &output.Output, &truncated, &totalBytes)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get output: %w", err)
+	}
+	output.Truncated, output.TotalBytes = truncated.Bool, totalBytes.Int64
+	return &output, nil
+}
+
+func (r *LogRepo) insertOutputs(ctx context.Context, db execer, entries []*domain.LogEntry) error {
+	query := r.sb.Insert("log_outputs").Columns("event_id", "output", "truncated", "total_bytes")
+	count := 0
+	for _, entry := range entries {
+		if entry.Output == nil {
+			continue
+		}
+		query = query.Values(entry.EventID, entry.Output.Output, entry.Output.Truncated, entry.Output.TotalBytes)
+		count++
+	}
+	if count == 0 {
+		return nil
+	}
+
+	sqlStr, args, err := query.Suffix("ON CONFLICT(event_id) DO NOTHING").ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build output insert query: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("failed to store outputs: %w", err)
+	}
+	return nil
+}
+
+func (r *LogRepo) deleteOutputs(ctx context.Context, db execer, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	sqlStr, args, err := r.sb.Delete("log_outputs").Where(sq.Eq{"event_id": ids}).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build output delete query: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("failed to delete outputs: %w", err)
+	}
+	return nil
+}
@@ -2,15 +2,17 @@ package database
 
 import (
 	"fmt"
+	"net/url"
+	"time"
 
 	"github.com/WillRabalais04/terminalLog/db"
 )
 
 func GetLocalRepo(cachePath string) (*LogRepo, error) {
 	cache, err := NewRepo(&Config{
-		Driver:       "sqlite",
-		DataSource:   cachePath,
-		SchemaString: db.SqliteSchema,
+		Driver:     "sqlite",
+		DataSource: cachePath,
+		Migrations: db.SqliteMigrations,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("could not init cache repo (sqlite): %v", err)
@@ -18,11 +20,25 @@ func GetLocalRepo(cachePath string) (*LogRepo, error) {
 	return cache, nil
 }
 
+// OpenLocalRepo opens the cache read only without migrating it and waits at most busyTimeout for a writer's lock, for
+// reads that have a latency budget (eg. suggestions)
+func OpenLocalRepo(cachePath string, busyTimeout time.Duration) (*LogRepo, error) {
+	query := fmt.Sprintf("mode=ro&_pragma=busy_timeout(%d)", busyTimeout.Milliseconds())
+	cache, err := NewRepo(&Config{
+		Driver:     "sqlite",
+		DataSource: (&url.URL{Scheme: "file", OmitHost: true, Path: cachePath, RawQuery: query}).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not open cache repo (sqlite): %v", err)
+	}
+	return cache, nil
+}
+
 func GetRemoteRepo(dataSource string) (*LogRepo, error) {
 	remote, err := NewRepo(&Config{
-		Driver:       "pgx",
-		DataSource:   dataSource,
-		SchemaString: db.PostgresSchema,
+		Driver:     "pgx",
+		DataSource: dataSource,
+		Migrations: db.PostgresMigrations,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("could not init remote repo (postgres): %v", err)
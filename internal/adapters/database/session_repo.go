@@ -0,0 +1,216 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/WillRabalais04/terminalLog/internal/core/domain"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+var sessionColumns = []string{"session_id", "host", "user_name", "tty", "shell", "started_at", "last_seen", "ended_at"}
+
+func (r *LogRepo) UpsertSessions(ctx context.Context, sessions []*domain.Session) error {
+	return r.upsertSessions(ctx, sessions, false)
+}
+
+func (r *LogRepo) upsertSessions(ctx context.Context, sessions []*domain.Session, synced bool) error {
+	if len(sessions) == 0 {
+		return nil
+	}
+
+	query := r.sb.Insert("sessions").Columns(sessionColumns...).Columns("synced")
+	for _, session := range sessions {
+		query = query.Values(
+			session.ID, nullString(session.Host), nullString(session.User), nullString(session.TTY), nullString(session.Shell),
+			nullInt(session.StartedAt), nullInt(session.LastSeen), nullInt(session.EndedAt), synced,
+		)
+	}
+	// heartbeats and ends only carry the id and times so keep whatever was registered at start
+	sqlStr, args, err := query.Suffix(`ON CONFLICT(session_id) DO UPDATE SET
+		host = COALESCE(excluded.host, sessions.host),
+		user_name = COALESCE(excluded.user_name, sessions.user_name),
+		tty = COALESCE(excluded.tty, sessions.tty),
+		shell = COALESCE(excluded.shell, sessions.shell),
+		started_at = COALESCE(sessions.started_at, excluded.started_at),
+		last_seen = CASE WHEN sessions.last_seen IS NULL OR excluded.last_seen > sessions.last_seen THEN excluded.last_seen ELSE sessions.last_seen END,
+		ended_at = COALESCE(excluded.ended_at, sessions.ended_at),
+		synced = excluded.synced`).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build session upsert query: %w", err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin session transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("failed to upsert sessions: %w", err)
+	}
+	if err := r.reapRunning(ctx, tx, sessions); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// reapRunning marks commands that were still running when their session ended as abandoned
+func (r *LogRepo) reapRunning(ctx context.Context, tx *sql.Tx, sessions []*domain.Session) error {
+	endedAt := make(map[string]int64)
+	for _, session := range sessions {
+		if session.EndedAt != 0 {
+			endedAt[session.ID] = session.EndedAt
+		}
+	}
+	if len(endedAt) == 0 {
+		return nil
+	}
+
+	sqlStr, args, err := r.sb.Select("event_id", "session_id").From("logs").
+		Where(sq.Eq{"status": domain.StatusRunning, "session_id": slices.Collect(maps.Keys(endedAt))}).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build running entries query: %w", err)
+	}
+	rows, err := tx.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("failed to find running entries: %w", err)
+	}
+	var ids, sessionIDs []string
+	for rows.Next() {
+		var id, sessionID string
+		if err := rows.Scan(&id, &sessionID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan running entry: %w", err)
+		}
+		ids, sessionIDs = append(ids, id), append(sessionIDs, sessionID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	updatedAt := time.Now().UnixNano()
+	for i, id := range ids { // one at a time so each row gets its own updated_at (see insertQuery)
+		sqlStr, args, err := r.sb.Update("logs").
+			Set("status", domain.StatusAbandoned).
+			Set("end_ts", endedAt[sessionIDs[i]]).
+			Set("updated_at", updatedAt+int64(i)).
+			Where(sq.Eq{"event_id": id, "status": domain.StatusRunning}).ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build reap query: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+			return fmt.Errorf("failed to reap running entry: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *LogRepo) ListSessions(ctx context.Context, filter *domain.SessionFilter) ([]*domain.Session, error) {
+	query := r.sb.Select(sessionColumns...).From("sessions").OrderBy("last_seen DESC")
+	if filter != nil {
+		if filter.ActiveOnly {
+			query = query.Where(sq.Eq{"ended_at": nil})
+		}
+		if filter.SeenSince > 0 {
+			query = query.Where(sq.GtOrEq{"last_seen": filter.SeenSince})
+		}
+		if filter.User != "" {
+			query = query.Where(sq.Eq{"user_name": filter.User})
+		}
+		if filter.Host != "" {
+			query = query.Where(sq.Eq{"host": filter.Host})
+		}
+	}
+	return r.querySessions(ctx, query)
+}
+
+// PendingSessions returns sessions started, heartbeated or ended locally since they were last pushed to remote
+func (r *LogRepo) PendingSessions(ctx context.Context) ([]*domain.Session, error) {
+	return r.querySessions(ctx, r.sb.Select(sessionColumns...).From("sessions").Where(sq.Eq{"synced": false}))
+}
+
+func (r *LogRepo) MarkSessionsSynced(ctx context.Context, sessions []*domain.Session) error {
+	for _, session := range sessions {
+		// a heartbeat that landed after the push leaves the session pending
+		var lastSeen interface{} // IS NULL rather than = NULL for sessions that never heartbeated
+		if session.LastSeen != 0 {
+			lastSeen = session.LastSeen
+		}
+		sqlStr, args, err := r.sb.Update("sessions").Set("synced", true).
+			Where(sq.Eq{"session_id": session.ID, "last_seen": lastSeen}).ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build session update query: %w", err)
+		}
+		if _, err := r.db.ExecContext(ctx, sqlStr, args...); err != nil {
+			return fmt.Errorf("failed to mark sessions synced: %w", err)
+		}
+	}
+	return nil
+}
+
+// touchSessions heartbeats the sessions entries were logged from
+func (r *LogRepo) touchSessions(ctx context.Context, db execer, entries []*domain.LogEntry) error {
+	lastSeen := make(map[string]int64)
+	for _, entry := range entries {
+		if entry.SessionID != "" {
+			lastSeen[entry.SessionID] = max(lastSeen[entry.SessionID], entry.Timestamp, entry.EndTimestamp)
+		}
+	}
+
+	for id, ts := range lastSeen {
+		sqlStr, args, err := r.sb.Update("sessions").Set("last_seen", ts).Set("synced", false).
+			Where(sq.Eq{"session_id": id}).
+			Where(sq.Or{sq.Eq{"last_seen": nil}, sq.Lt{"last_seen": ts}}).ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build heartbeat query: %w", err)
+		}
+		if _, err := db.ExecContext(ctx, sqlStr, args...); err != nil {
+			return fmt.Errorf("failed to heartbeat session: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *LogRepo) querySessions(ctx context.Context, query sq.SelectBuilder) ([]*domain.Session, error) {
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build session query: %w", err)
+	}
+	rows, err := r.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute session query: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*domain.Session
+	for rows.Next() {
+		var session domain.Session
+		var host, user, tty, shell sql.NullString
+		var startedAt, lastSeen, endedAt sql.NullInt64
+		if err := rows.Scan(&session.ID, &host, &user, &tty, &shell, &startedAt, &lastSeen, &endedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		session.Host, session.User, session.TTY, session.Shell = host.String, user.String, tty.String, shell.String
+		session.StartedAt, session.LastSeen, session.EndedAt = startedAt.Int64, lastSeen.Int64, endedAt.Int64
+		sessions = append(sessions, &session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+	return sessions, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullInt(i int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: i, Valid: i != 0}
+}
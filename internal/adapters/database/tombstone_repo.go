@@ -0,0 +1,212 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/WillRabalais04/terminalLog/internal/core/domain"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+var tombstoneColumns = []string{"event_id", "deleted_at", "actor", "user_name"}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// PushTombstones applies deletes made on another copy (eg. a device's cache) and records them so they propagate
+func (r *LogRepo) PushTombstones(ctx context.Context, tombstones []*domain.Tombstone) error {
+	return r.forget(ctx, tombstones, false)
+}
+
+// UpsertTombstones applies tombstones pulled from a remote, marking them as synced so they aren't pushed back
+func (r *LogRepo) UpsertTombstones(ctx context.Context, tombstones []*domain.Tombstone) error {
+	return r.forget(ctx, tombstones, true)
+}
+
+func (r *LogRepo) forget(ctx context.Context, tombstones []*domain.Tombstone, synced bool) error {
+	if len(tombstones) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(tombstones))
+	for i, tombstone := range tombstones {
+		ids[i] = tombstone.EventID
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin forget transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := r.evict(ctx, tx, ids); err != nil {
+		return err
+	}
+	if err := r.insertTombstones(ctx, tx, tombstones, synced); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Evict removes entries without tombstoning them (eg. once they've been flushed to remote)
+func (r *LogRepo) Evict(ctx context.Context, ids []string) error {
+	return r.evict(ctx, r.db, ids)
+}
+
+func (r *LogRepo) evict(ctx context.Context, db execer, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	sqlStr, args, err := r.sb.Delete("logs").Where(sq.Eq{"event_id": ids}).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build evict query: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("failed to evict entries: %w", err)
+	}
+	return r.deleteOutputs(ctx, db, ids)
+}
+
+func (r *LogRepo) insertTombstones(ctx context.Context, db execer, tombstones []*domain.Tombstone, synced bool) error {
+	if len(tombstones) == 0 {
+		return nil
+	}
+
+	query := r.sb.Insert("tombstones").Columns(tombstoneColumns...).Columns("updated_at", "synced")
+	updatedAt := time.Now().UnixNano()
+	for i, tombstone := range tombstones {
+		query = query.Values(tombstone.EventID, tombstone.DeletedAt, tombstone.Actor, tombstone.User, updatedAt+int64(i), synced)
+	}
+
+	sqlStr, args, err := query.Suffix("ON CONFLICT(event_id) DO NOTHING").ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build tombstone insert query: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("failed to record tombstones: %w", err)
+	}
+	return nil
+}
+
+// dropTombstoned filters out entries that have already been deleted somewhere
+func (r *LogRepo) dropTombstoned(ctx context.Context, entries []*domain.LogEntry) ([]*domain.LogEntry, error) {
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.EventID != "" {
+			ids = append(ids, entry.EventID)
+		}
+	}
+	if len(ids) == 0 {
+		return entries, nil
+	}
+
+	sqlStr, args, err := r.sb.Select("event_id").From("tombstones").Where(sq.Eq{"event_id": ids}).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build tombstone query: %w", err)
+	}
+	rows, err := r.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tombstones: %w", err)
+	}
+	defer rows.Close()
+
+	tombstoned := make(map[string]struct{})
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan tombstone: %w", err)
+		}
+		tombstoned[id] = struct{}{}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+	if len(tombstoned) == 0 {
+		return entries, nil
+	}
+
+	kept := make([]*domain.LogEntry, 0, len(entries))
+	for _, entry := range entries {
+		if _, ok := tombstoned[entry.EventID]; !ok {
+			kept = append(kept, entry)
+		}
+	}
+	return kept, nil
+}
+
+// PullTombstones returns the user's tombstones recorded after the since watermark along with the new watermark
+func (r *LogRepo) PullTombstones(ctx context.Context, user string, since int64, limit uint64) ([]*domain.Tombstone, int64, error) {
+	query := r.sb.Select(tombstoneColumns...).Column("updated_at").From("tombstones").
+		Where(sq.Eq{"user_name": user}).
+		Where(sq.Gt{"updated_at": since}).
+		OrderBy("updated_at ASC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	tombstones, watermark, err := r.queryTombstones(ctx, query)
+	if err != nil {
+		return nil, since, err
+	}
+	return tombstones, max(since, watermark), nil
+}
+
+// PendingTombstones returns local deletes that haven't been pushed to remote yet
+func (r *LogRepo) PendingTombstones(ctx context.Context) ([]*domain.Tombstone, error) {
+	query := r.sb.Select(tombstoneColumns...).Column("updated_at").From("tombstones").
+		Where(sq.Eq{"synced": false}).
+		OrderBy("updated_at ASC")
+
+	tombstones, _, err := r.queryTombstones(ctx, query)
+	return tombstones, err
+}
+
+func (r *LogRepo) MarkTombstonesSynced(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	sqlStr, args, err := r.sb.Update("tombstones").Set("synced", true).Where(sq.Eq{"event_id": ids}).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build tombstone update query: %w", err)
+	}
+	_, err = r.db.ExecContext(ctx, sqlStr, args...)
+	return err
+}
+
+func (r *LogRepo) queryTombstones(ctx context.Context, query sq.SelectBuilder) ([]*domain.Tombstone, int64, error) {
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to build tombstone query: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to execute tombstone query: %w", err)
+	}
+	defer rows.Close()
+
+	var watermark int64
+	var tombstones []*domain.Tombstone
+	for rows.Next() {
+		var tombstone domain.Tombstone
+		var actor, user sql.NullString
+		var updatedAt int64
+		if err := rows.Scan(&tombstone.EventID, &tombstone.DeletedAt, &actor, &user, &updatedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan tombstone: %w", err)
+		}
+		tombstone.Actor, tombstone.User = actor.String, user.String
+		tombstones = append(tombstones, &tombstone)
+		watermark = max(watermark, updatedAt)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error during rows iteration: %w", err)
+	}
+	return tombstones, watermark, nil
+}
@@ -0,0 +1,235 @@
+package git
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Mode string
+
+const (
+	ModeOff   Mode = "off"   // don't collect anything
+	ModeLight Mode = "light" // repo root, branch and commit read straight from .git
+	ModeFull  Mode = "full"  // light + git status (bounded by a time budget)
+)
+
+const shortHashLen = 7
+
+type Info struct {
+	IsRepo bool
+	Root   string
+	Branch string
+	Commit string
+	Status string
+}
+
+func ParseMode(mode string) (Mode, error) {
+	switch Mode(mode) {
+	case "":
+		return ModeFull, nil
+	case ModeOff, ModeLight, ModeFull:
+		return Mode(mode), nil
+	default:
+		return ModeFull, fmt.Errorf("invalid git mode %q (should be off, light or full)", mode)
+	}
+}
+
+// Collect reads the git context of cwd without spawning git, except for git status in full mode which is
+// abandoned (leaving Status empty) if it takes longer than statusBudget.
+func Collect(cwd string, mode Mode, statusBudget time.Duration) Info {
+	if mode == ModeOff || cwd == "" {
+		return Info{}
+	}
+
+	root, gitDir, ok := findRepo(cwd)
+	if !ok {
+		return Info{}
+	}
+	info := Info{IsRepo: true, Root: root}
+	info.Branch, info.Commit = readHead(gitDir)
+
+	if mode == ModeFull {
+		info.Status = status(root, statusBudget)
+	}
+	return info
+}
+
+// findRepo walks up from dir to the first directory containing .git, resolving gitdir files (worktrees, submodules)
+func findRepo(dir string) (root, gitDir string, ok bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", false
+	}
+
+	for {
+		candidate := filepath.Join(dir, ".git")
+		if fi, err := os.Stat(candidate); err == nil {
+			if fi.IsDir() {
+				return dir, candidate, true
+			}
+			if linked, ok := readGitDirFile(candidate); ok {
+				return dir, linked, true
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", false
+		}
+		dir = parent
+	}
+}
+
+func readGitDirFile(path string) (string, bool) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	line := strings.TrimSpace(string(contents))
+	if !strings.HasPrefix(line, "gitdir:") {
+		return "", false
+	}
+	gitDir := strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(path), gitDir)
+	}
+	return gitDir, true
+}
+
+// readHead returns the branch ("HEAD" when detached like git rev-parse --abbrev-ref) and short commit hash
+func readHead(gitDir string) (branch, commit string) {
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return "", ""
+	}
+	ref := strings.TrimSpace(string(head))
+
+	if !strings.HasPrefix(ref, "ref:") {
+		return "HEAD", shorten(ref)
+	}
+	ref = strings.TrimSpace(strings.TrimPrefix(ref, "ref:"))
+	branch = strings.TrimPrefix(ref, "refs/heads/")
+	return branch, shorten(resolveRef(gitDir, ref))
+}
+
+// resolveCommonDir returns where linked worktrees keep the main repo's refs and config
+func resolveCommonDir(gitDir string) string {
+	contents, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+	dir := strings.TrimSpace(string(contents))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(gitDir, dir)
+	}
+	return dir
+}
+
+func resolveRef(gitDir, ref string) string {
+	commonDir := resolveCommonDir(gitDir)
+
+	for range 5 { // follow symbolic refs a few levels deep
+		var contents []byte
+		var err error
+		for _, dir := range []string{gitDir, commonDir} {
+			if contents, err = os.ReadFile(filepath.Join(dir, ref)); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return packedRef(commonDir, ref)
+		}
+
+		value := strings.TrimSpace(string(contents))
+		if !strings.HasPrefix(value, "ref:") {
+			return value
+		}
+		ref = strings.TrimSpace(strings.TrimPrefix(value, "ref:"))
+	}
+	return ""
+}
+
+func packedRef(gitDir, ref string) string {
+	file, err := os.Open(filepath.Join(gitDir, "packed-refs"))
+	if err != nil {
+		return "" // unborn branch (no commits yet)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "^") {
+			continue
+		}
+		hash, name, found := strings.Cut(line, " ")
+		if found && name == ref {
+			return hash
+		}
+	}
+	return ""
+}
+
+// RemoteURL reads a remote's url from the repo's config without any credentials embedded in it, empty if cwd isn't in a
+// repo or the remote isn't set
+func RemoteURL(cwd, remote string) string {
+	_, gitDir, ok := findRepo(cwd)
+	if !ok {
+		return ""
+	}
+	file, err := os.Open(filepath.Join(resolveCommonDir(gitDir), "config"))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	section := fmt.Sprintf("[remote %q]", remote)
+	inSection := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			inSection = line == section
+			continue
+		}
+		if key, value, found := strings.Cut(line, "="); inSection && found && strings.TrimSpace(key) == "url" {
+			return stripCredentials(strings.TrimSpace(value))
+		}
+	}
+	return ""
+}
+
+func stripCredentials(remote string) string {
+	parsed, err := url.Parse(remote)
+	if err != nil || parsed.User == nil || parsed.Scheme == "" {
+		return remote // scp-like git@host:path urls only carry the ssh user
+	}
+	parsed.User = nil
+	return parsed.String()
+}
+
+func shorten(hash string) string {
+	if len(hash) > shortHashLen {
+		return hash[:shortHashLen]
+	}
+	return hash
+}
+
+func status(root string, budget time.Duration) string {
+	ctx, cancel := context.WithTimeout(context.Background(), budget)
+	defer cancel()
+
+	// no index.lock, it would fail git commands the user runs meanwhile (and a killed status would leave it behind)
+	out, err := exec.CommandContext(ctx, "git", "--no-optional-locks", "-C", root, "status", "--porcelain=v1").Output()
+	if err != nil {
+		return "" // over budget (or git missing) so skip it rather than slow down the prompt
+	}
+	return strings.TrimRight(string(out), "\n")
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/WillRabalais04/terminalLog/internal/core/domain"
 	"github.com/WillRabalais04/terminalLog/internal/core/ports"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type ClientAdapter struct {
@@ -24,6 +26,13 @@ func (c *ClientAdapter) Log(ctx context.Context, entries []*domain.LogEntry) err
 	return err
 }
 
+func (c *ClientAdapter) Complete(ctx context.Context, entries []*domain.LogEntry) error {
+	_, err := c.client.Complete(ctx, &pb.CompleteRequest{
+		Entries: LogEntriesToProto(entries),
+	})
+	return err
+}
+
 func (c *ClientAdapter) Get(ctx context.Context, id string) (*domain.LogEntry, error) {
 	resp, err := c.client.Get(ctx, &pb.GetRequest{EventId: id})
 	if err != nil {
@@ -43,7 +52,7 @@ func (c *ClientAdapter) List(ctx context.Context, filter *domain.LogFilter) ([]*
 }
 
 func (c *ClientAdapter) Delete(ctx context.Context, id string) (*domain.LogEntry, error) {
-	resp, err := c.client.Delete(ctx, &pb.DeleteRequest{EventId: id})
+	resp, err := c.client.Delete(ctx, &pb.DeleteRequest{EventId: id, Actor: domain.ActorFromContext(ctx)})
 	if err != nil {
 		return nil, err
 	}
@@ -53,9 +62,90 @@ func (c *ClientAdapter) Delete(ctx context.Context, id string) (*domain.LogEntry
 func (c *ClientAdapter) DeleteMultiple(ctx context.Context, filter *domain.LogFilter) ([]*domain.LogEntry, error) {
 	resp, err := c.client.DeleteMultiple(ctx, &pb.DeleteMultipleRequest{
 		Filter: FilterToProto(filter),
+		Actor:  domain.ActorFromContext(ctx),
 	})
 	if err != nil {
 		return nil, err
 	}
 	return LogEntriesFromProto(resp.Deleted), nil
 }
+
+func (c *ClientAdapter) Pull(ctx context.Context, user string, since int64, limit uint64) ([]*domain.LogEntry, int64, error) {
+	req := &pb.PullRequest{User: user, Since: since}
+	if limit > 0 {
+		req.Limit = &limit
+	}
+	resp, err := c.client.Pull(ctx, req)
+	if err != nil {
+		return nil, since, err
+	}
+	return LogEntriesFromProto(resp.Logs), resp.GetWatermark(), nil
+}
+
+func (c *ClientAdapter) PushTombstones(ctx context.Context, tombstones []*domain.Tombstone) error {
+	_, err := c.client.PushTombstones(ctx, &pb.PushTombstonesRequest{
+		Tombstones: TombstonesToProto(tombstones),
+	})
+	return err
+}
+
+func (c *ClientAdapter) PullTombstones(ctx context.Context, user string, since int64, limit uint64) ([]*domain.Tombstone, int64, error) {
+	req := &pb.PullRequest{User: user, Since: since}
+	if limit > 0 {
+		req.Limit = &limit
+	}
+	resp, err := c.client.PullTombstones(ctx, req)
+	if err != nil {
+		return nil, since, err
+	}
+	return TombstonesFromProto(resp.Tombstones), resp.GetWatermark(), nil
+}
+
+// GetOutput returns nil if no output was captured for the entry
+func (c *ClientAdapter) GetOutput(ctx context.Context, eventID string) (*domain.CommandOutput, error) {
+	resp, err := c.client.GetOutput(ctx, &pb.GetOutputRequest{EventId: eventID})
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return CommandOutputFromProto(resp), nil
+}
+
+func (c *ClientAdapter) UpsertSessions(ctx context.Context, sessions []*domain.Session) error {
+	_, err := c.client.UpsertSessions(ctx, &pb.UpsertSessionsRequest{Sessions: SessionsToProto(sessions)})
+	return err
+}
+
+func (c *ClientAdapter) ListSessions(ctx context.Context, filter *domain.SessionFilter) ([]*domain.Session, error) {
+	req := &pb.ListSessionsRequest{}
+	if filter != nil {
+		req = &pb.ListSessionsRequest{ActiveOnly: filter.ActiveOnly, SeenSince: filter.SeenSince, User: filter.User, Host: filter.Host}
+	}
+	resp, err := c.client.ListSessions(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return SessionsFromProto(resp.GetSessions()), nil
+}
+
+func (c *ClientAdapter) SessionTimeline(ctx context.Context, sessionID string) ([]*domain.LogEntry, error) {
+	resp, err := c.client.SessionTimeline(ctx, &pb.SessionTimelineRequest{SessionId: sessionID})
+	if err != nil {
+		return nil, err
+	}
+	return LogEntriesFromProto(resp.GetLogs()), nil
+}
+
+func (c *ClientAdapter) RankDirectories(ctx context.Context, filter *domain.DirectoryFilter) ([]*domain.DirectoryRank, error) {
+	req := &pb.RankDirectoriesRequest{}
+	if filter != nil {
+		req = &pb.RankDirectoriesRequest{User: filter.User, Host: filter.Host, Fragments: filter.Fragments, Since: filter.Since, Limit: filter.Limit}
+	}
+	resp, err := c.client.RankDirectories(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return DirectoryRanksFromProto(resp.GetDirectories()), nil
+}
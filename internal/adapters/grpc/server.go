@@ -5,7 +5,10 @@ import (
 	"log"
 
 	pb "github.com/WillRabalais04/terminalLog/api/gen"
+	"github.com/WillRabalais04/terminalLog/internal/core/domain"
 	"github.com/WillRabalais04/terminalLog/internal/core/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type ServerAdapter struct {
@@ -48,6 +51,22 @@ func (a *ServerAdapter) Log(ctx context.Context, req *pb.LogRequest) (*pb.LogRes
 	return &pb.LogResponse{Success: true, EventIds: loggedEntryIDs}, nil
 }
 
+func (a *ServerAdapter) Complete(ctx context.Context, req *pb.CompleteRequest) (*pb.CompleteResponse, error) {
+	entries := LogEntriesFromProto(req.GetEntries())
+	log.Printf("🔼 complete request for %d entries", len(entries))
+
+	if err := a.svc.Complete(ctx, entries); err != nil {
+		log.Print("🔽 no entries completed")
+		return nil, err
+	}
+
+	log.Print("🔽 completed entries with id's:")
+	for _, entry := range entries {
+		log.Printf("\t- %s (%s, exit %d)", entry.EventID, entry.Status, entry.ExitCode)
+	}
+	return &pb.CompleteResponse{Success: true}, nil
+}
+
 func (a *ServerAdapter) Get(ctx context.Context, req *pb.GetRequest) (*pb.LogEntry, error) {
 	eventID := req.GetEventId()
 	log.Printf("🔼 get request for log (id: '%s')", eventID)
@@ -58,6 +77,13 @@ func (a *ServerAdapter) Get(ctx context.Context, req *pb.GetRequest) (*pb.LogEnt
 		return nil, err
 	}
 
+	if req.GetIncludeOutput() {
+		if entry.Output, err = a.svc.GetOutput(ctx, eventID); err != nil {
+			log.Printf("🔽 could not read output for id: '%s'", eventID)
+			return nil, err
+		}
+	}
+
 	log.Printf("🔽 found log (%s)", entry.EventID)
 	return LogEntryToProto(entry), nil
 }
@@ -90,7 +116,7 @@ func (a *ServerAdapter) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.
 	eventID := req.GetEventId()
 	log.Printf("🔼 delete request for log (id: '%s')", eventID)
 
-	deleted, err := a.svc.Delete(ctx, eventID)
+	deleted, err := a.svc.Delete(domain.WithActor(ctx, req.GetActor()), eventID)
 	if err != nil {
 		log.Printf("🔽 log not deleted for id: '%s'", eventID)
 		return nil, err
@@ -104,7 +130,7 @@ func (a *ServerAdapter) DeleteMultiple(ctx context.Context, req *pb.DeleteMultip
 	filters := FilterFromProto(req.GetFilter())
 	log.Printf("🔼 deletemultiple request with filter: {%s}", FilterToString(filters))
 
-	deleted, err := a.svc.DeleteMultiple(ctx, filters)
+	deleted, err := a.svc.DeleteMultiple(domain.WithActor(ctx, req.GetActor()), filters)
 	if err != nil {
 		log.Printf("🔽 logs not deleted")
 		return nil, err
@@ -123,3 +149,129 @@ func (a *ServerAdapter) DeleteMultiple(ctx context.Context, req *pb.DeleteMultip
 
 	return &pb.DeleteMultipleResponse{Success: true, Deleted: LogEntriesToProto(deleted)}, nil
 }
+
+func (a *ServerAdapter) Pull(ctx context.Context, req *pb.PullRequest) (*pb.PullResponse, error) {
+	log.Printf("🔼 pull request for user '%s' since %d", req.GetUser(), req.GetSince())
+
+	entries, watermark, err := a.svc.Pull(ctx, req.GetUser(), req.GetSince(), req.GetLimit())
+	if err != nil {
+		log.Printf("🔽 failed to pull entries for user '%s'", req.GetUser())
+		return nil, err
+	}
+
+	log.Printf("🔽 pulled %d entries (watermark: %d)", len(entries), watermark)
+	return &pb.PullResponse{Logs: LogEntriesToProto(entries), Watermark: watermark}, nil
+}
+
+func (a *ServerAdapter) PushTombstones(ctx context.Context, req *pb.PushTombstonesRequest) (*pb.PushTombstonesResponse, error) {
+	tombstones := TombstonesFromProto(req.GetTombstones())
+	log.Printf("🔼 push tombstones request for %d entries", len(tombstones))
+
+	if err := a.svc.PushTombstones(ctx, tombstones); err != nil {
+		log.Print("🔽 no tombstones recorded")
+		return nil, err
+	}
+
+	log.Print("🔽 recorded tombstones for id's:")
+	for _, tombstone := range tombstones {
+		log.Printf("\t- %s", tombstone.EventID)
+	}
+	return &pb.PushTombstonesResponse{Success: true}, nil
+}
+
+func (a *ServerAdapter) PullTombstones(ctx context.Context, req *pb.PullRequest) (*pb.PullTombstonesResponse, error) {
+	log.Printf("🔼 pull tombstones request for user '%s' since %d", req.GetUser(), req.GetSince())
+
+	tombstones, watermark, err := a.svc.PullTombstones(ctx, req.GetUser(), req.GetSince(), req.GetLimit())
+	if err != nil {
+		log.Printf("🔽 failed to pull tombstones for user '%s'", req.GetUser())
+		return nil, err
+	}
+
+	log.Printf("🔽 pulled %d tombstones (watermark: %d)", len(tombstones), watermark)
+	return &pb.PullTombstonesResponse{Tombstones: TombstonesToProto(tombstones), Watermark: watermark}, nil
+}
+
+func (a *ServerAdapter) GetOutput(ctx context.Context, req *pb.GetOutputRequest) (*pb.CommandOutput, error) {
+	eventID := req.GetEventId()
+	log.Printf("🔼 get output request for log (id: '%s')", eventID)
+
+	output, err := a.svc.GetOutput(ctx, eventID)
+	if err != nil {
+		log.Printf("🔽 could not read output for id: '%s'", eventID)
+		return nil, err
+	}
+	if output == nil {
+		log.Printf("🔽 no output captured for id: '%s'", eventID)
+		return nil, status.Errorf(codes.NotFound, "no output captured for %s", eventID)
+	}
+
+	log.Printf("🔽 found output (%d bytes)", len(output.Output))
+	return CommandOutputToProto(output), nil
+}
+
+func (a *ServerAdapter) UpsertSessions(ctx context.Context, req *pb.UpsertSessionsRequest) (*pb.UpsertSessionsResponse, error) {
+	sessions := SessionsFromProto(req.GetSessions())
+	log.Printf("🔼 upsert request for %d sessions", len(sessions))
+
+	if err := a.svc.UpsertSessions(ctx, sessions); err != nil {
+		log.Print("🔽 no sessions upserted")
+		return nil, err
+	}
+
+	log.Print("🔽 upserted sessions with id's:")
+	for _, session := range sessions {
+		log.Printf("\t- %s", session.ID)
+	}
+	return &pb.UpsertSessionsResponse{Success: true}, nil
+}
+
+func (a *ServerAdapter) ListSessions(ctx context.Context, req *pb.ListSessionsRequest) (*pb.ListSessionsResponse, error) {
+	log.Printf("🔼 list sessions request (active only: %t, user: '%s', host: '%s')", req.GetActiveOnly(), req.GetUser(), req.GetHost())
+
+	sessions, err := a.svc.ListSessions(ctx, &domain.SessionFilter{
+		ActiveOnly: req.GetActiveOnly(),
+		SeenSince:  req.GetSeenSince(),
+		User:       req.GetUser(),
+		Host:       req.GetHost(),
+	})
+	if err != nil {
+		log.Print("🔽 failed to list sessions")
+		return nil, err
+	}
+
+	log.Printf("🔽 found %d sessions", len(sessions))
+	return &pb.ListSessionsResponse{Sessions: SessionsToProto(sessions)}, nil
+}
+
+func (a *ServerAdapter) SessionTimeline(ctx context.Context, req *pb.SessionTimelineRequest) (*pb.ListResponse, error) {
+	log.Printf("🔼 timeline request for session '%s'", req.GetSessionId())
+
+	entries, err := a.svc.SessionTimeline(ctx, req.GetSessionId())
+	if err != nil {
+		log.Printf("🔽 failed to get timeline for session '%s'", req.GetSessionId())
+		return nil, err
+	}
+
+	log.Printf("🔽 found %d entries in session", len(entries))
+	return &pb.ListResponse{Logs: LogEntriesToProto(entries)}, nil
+}
+
+func (a *ServerAdapter) RankDirectories(ctx context.Context, req *pb.RankDirectoriesRequest) (*pb.RankDirectoriesResponse, error) {
+	log.Printf("🔼 rank directories request (user: '%s', host: '%s', fragments: %v)", req.GetUser(), req.GetHost(), req.GetFragments())
+
+	directories, err := a.svc.RankDirectories(ctx, &domain.DirectoryFilter{
+		User:      req.GetUser(),
+		Host:      req.GetHost(),
+		Fragments: req.GetFragments(),
+		Since:     req.GetSince(),
+		Limit:     req.GetLimit(),
+	})
+	if err != nil {
+		log.Print("🔽 failed to rank directories")
+		return nil, err
+	}
+
+	log.Printf("🔽 ranked %d directories", len(directories))
+	return &pb.RankDirectoriesResponse{Directories: DirectoryRanksToProto(directories)}, nil
+}
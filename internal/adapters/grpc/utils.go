@@ -32,6 +32,23 @@ func LogEntryToProto(entry *domain.LogEntry) *pb.LogEntry {
 		GitCommit:            entry.GitCommit,
 		GitStatus:            entry.GitStatus,
 		LoggedSuccessfully:   entry.LoggedSuccessfully,
+		DisabledFields:       entry.DisabledFields,
+		Redacted:             entry.Redacted,
+		Output:               CommandOutputToProto(entry.Output),
+		SessionId:            entry.SessionID,
+		Status:               entry.Status,
+		EndTimestamp:         entry.EndTimestamp,
+		Pipestatus:           entry.PipeStatus,
+		TerminationSignal:    entry.TerminationSignal,
+		Usage:                ResourceUsageToProto(entry.Usage),
+		Context:              RuntimeContextToProto(entry.Context),
+		KubeContext:          entry.KubeContext,
+		KubeNamespace:        entry.KubeNamespace,
+		AwsProfile:           entry.AWSProfile,
+		AwsRegion:            entry.AWSRegion,
+		GcloudConfig:         entry.GCloudConfig,
+		TerraformWorkspace:   entry.TerraformWorkspace,
+		Labels:               entry.Labels,
 	}
 }
 
@@ -66,6 +83,23 @@ func LogEntryFromProto(entry *pb.LogEntry) *domain.LogEntry {
 		GitCommit:            entry.GetGitCommit(),
 		GitStatus:            entry.GetGitStatus(),
 		LoggedSuccessfully:   entry.GetLoggedSuccessfully(),
+		DisabledFields:       entry.GetDisabledFields(),
+		Redacted:             entry.GetRedacted(),
+		Output:               CommandOutputFromProto(entry.GetOutput()),
+		SessionID:            entry.GetSessionId(),
+		Status:               entry.GetStatus(),
+		EndTimestamp:         entry.GetEndTimestamp(),
+		PipeStatus:           entry.GetPipestatus(),
+		TerminationSignal:    entry.GetTerminationSignal(),
+		Usage:                ResourceUsageFromProto(entry.GetUsage()),
+		Context:              RuntimeContextFromProto(entry.GetContext()),
+		KubeContext:          entry.GetKubeContext(),
+		KubeNamespace:        entry.GetKubeNamespace(),
+		AWSProfile:           entry.GetAwsProfile(),
+		AWSRegion:            entry.GetAwsRegion(),
+		GCloudConfig:         entry.GetGcloudConfig(),
+		TerraformWorkspace:   entry.GetTerraformWorkspace(),
+		Labels:               entry.GetLabels(),
 	}
 }
 
@@ -78,6 +112,178 @@ func LogEntriesFromProto(entries []*pb.LogEntry) []*domain.LogEntry {
 	return out
 }
 
+func CommandOutputToProto(output *domain.CommandOutput) *pb.CommandOutput {
+	if output == nil {
+		return nil
+	}
+	return &pb.CommandOutput{
+		EventId:    output.EventID,
+		Output:     output.Output,
+		Truncated:  output.Truncated,
+		TotalBytes: output.TotalBytes,
+	}
+}
+
+func CommandOutputFromProto(output *pb.CommandOutput) *domain.CommandOutput {
+	if output == nil {
+		return nil
+	}
+	return &domain.CommandOutput{
+		EventID:    output.GetEventId(),
+		Output:     output.GetOutput(),
+		Truncated:  output.GetTruncated(),
+		TotalBytes: output.GetTotalBytes(),
+	}
+}
+
+func ResourceUsageToProto(usage *domain.ResourceUsage) *pb.ResourceUsage {
+	if usage == nil {
+		return nil
+	}
+	return &pb.ResourceUsage{
+		UserTimeUs:             usage.UserTimeMicros,
+		SysTimeUs:              usage.SysTimeMicros,
+		MaxRssKb:               usage.MaxRSSKB,
+		VoluntaryCtxSwitches:   usage.VoluntaryCtxSwitches,
+		InvoluntaryCtxSwitches: usage.InvoluntaryCtxSwitches,
+		BlockInputOps:          usage.BlockInputOps,
+		BlockOutputOps:         usage.BlockOutputOps,
+	}
+}
+
+func ResourceUsageFromProto(usage *pb.ResourceUsage) *domain.ResourceUsage {
+	if usage == nil {
+		return nil
+	}
+	return &domain.ResourceUsage{
+		UserTimeMicros:         usage.GetUserTimeUs(),
+		SysTimeMicros:          usage.GetSysTimeUs(),
+		MaxRSSKB:               usage.GetMaxRssKb(),
+		VoluntaryCtxSwitches:   usage.GetVoluntaryCtxSwitches(),
+		InvoluntaryCtxSwitches: usage.GetInvoluntaryCtxSwitches(),
+		BlockInputOps:          usage.GetBlockInputOps(),
+		BlockOutputOps:         usage.GetBlockOutputOps(),
+	}
+}
+
+func RuntimeContextToProto(runtime *domain.RuntimeContext) *pb.RuntimeContext {
+	if runtime == nil {
+		return nil
+	}
+	return &pb.RuntimeContext{
+		Container:  runtime.Container,
+		Pod:        runtime.Pod,
+		Venv:       runtime.Venv,
+		Conda:      runtime.Conda,
+		Nix:        runtime.Nix,
+		Direnv:     runtime.Direnv,
+		Toolchains: runtime.Toolchains,
+	}
+}
+
+func RuntimeContextFromProto(runtime *pb.RuntimeContext) *domain.RuntimeContext {
+	if runtime == nil {
+		return nil
+	}
+	return &domain.RuntimeContext{
+		Container:  runtime.GetContainer(),
+		Pod:        runtime.GetPod(),
+		Venv:       runtime.GetVenv(),
+		Conda:      runtime.GetConda(),
+		Nix:        runtime.GetNix(),
+		Direnv:     runtime.GetDirenv(),
+		Toolchains: runtime.GetToolchains(),
+	}
+}
+
+func SessionsToProto(sessions []*domain.Session) []*pb.Session {
+	out := make([]*pb.Session, 0, len(sessions))
+	for _, session := range sessions {
+		out = append(out, &pb.Session{
+			SessionId: session.ID,
+			Host:      session.Host,
+			User:      session.User,
+			Tty:       session.TTY,
+			Shell:     session.Shell,
+			StartedAt: session.StartedAt,
+			LastSeen:  session.LastSeen,
+			EndedAt:   session.EndedAt,
+		})
+	}
+	return out
+}
+
+func SessionsFromProto(sessions []*pb.Session) []*domain.Session {
+	out := make([]*domain.Session, 0, len(sessions))
+	for _, session := range sessions {
+		out = append(out, &domain.Session{
+			ID:        session.GetSessionId(),
+			Host:      session.GetHost(),
+			User:      session.GetUser(),
+			TTY:       session.GetTty(),
+			Shell:     session.GetShell(),
+			StartedAt: session.GetStartedAt(),
+			LastSeen:  session.GetLastSeen(),
+			EndedAt:   session.GetEndedAt(),
+		})
+	}
+	return out
+}
+
+func DirectoryRanksToProto(directories []*domain.DirectoryRank) []*pb.DirectoryRank {
+	out := make([]*pb.DirectoryRank, 0, len(directories))
+	for _, directory := range directories {
+		out = append(out, &pb.DirectoryRank{
+			Path:     directory.Path,
+			Visits:   directory.Visits,
+			LastUsed: directory.LastUsed,
+			Score:    directory.Score,
+		})
+	}
+	return out
+}
+
+func DirectoryRanksFromProto(directories []*pb.DirectoryRank) []*domain.DirectoryRank {
+	out := make([]*domain.DirectoryRank, 0, len(directories))
+	for _, directory := range directories {
+		out = append(out, &domain.DirectoryRank{
+			Path:     directory.GetPath(),
+			Visits:   directory.GetVisits(),
+			LastUsed: directory.GetLastUsed(),
+			Score:    directory.GetScore(),
+		})
+	}
+	return out
+}
+
+func TombstonesToProto(tombstones []*domain.Tombstone) []*pb.Tombstone {
+	out := make([]*pb.Tombstone, 0, len(tombstones))
+
+	for _, tombstone := range tombstones {
+		out = append(out, &pb.Tombstone{
+			EventId:   tombstone.EventID,
+			DeletedAt: tombstone.DeletedAt,
+			Actor:     tombstone.Actor,
+			User:      tombstone.User,
+		})
+	}
+	return out
+}
+
+func TombstonesFromProto(tombstones []*pb.Tombstone) []*domain.Tombstone {
+	out := make([]*domain.Tombstone, 0, len(tombstones))
+
+	for _, tombstone := range tombstones {
+		out = append(out, &domain.Tombstone{
+			EventID:   tombstone.GetEventId(),
+			DeletedAt: tombstone.GetDeletedAt(),
+			Actor:     tombstone.GetActor(),
+			User:      tombstone.GetUser(),
+		})
+	}
+	return out
+}
+
 func FilterToProto(filter *domain.LogFilter) *pb.LogFilter {
 	if filter == nil {
 		return &pb.LogFilter{}
@@ -99,6 +305,9 @@ func FilterToProto(filter *domain.LogFilter) *pb.LogFilter {
 			Values: values.Values,
 		}
 	}
+	if filter.Prefix != "" {
+		protoFilter.Prefix = &filter.Prefix
+	}
 
 	filterMode := pb.FilterMode(int32(filter.FilterMode))
 	protoFilter.FilterMode = &filterMode
@@ -164,6 +373,7 @@ func FilterFromProto(protoFilter *pb.LogFilter) *domain.LogFilter {
 	}
 
 	domainFilter.OrderBy = protoFilter.OrderBy
+	domainFilter.Prefix = protoFilter.GetPrefix()
 
 	if protoFilter.StartTime != nil {
 		startTime := protoFilter.StartTime.AsTime().Unix()
@@ -197,6 +407,9 @@ func FilterToString(filter *domain.LogFilter) string {
 		}
 		parts = append(parts, fmt.Sprintf("searches: {%s}", strings.Join(termParts, " ")))
 	}
+	if filter.Prefix != "" {
+		parts = append(parts, fmt.Sprintf("prefix: %q", filter.Prefix))
+	}
 	if filter.StartTime != nil {
 		parts = append(parts, fmt.Sprintf("startTime: %s", time.Unix(*filter.StartTime, 0).Format(time.RFC3339)))
 	}
@@ -0,0 +1,203 @@
+package importer
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/WillRabalais04/terminalLog/internal/core/domain"
+	"github.com/google/uuid"
+
+	_ "modernc.org/sqlite"
+)
+
+// ReadAtuin reads atuin's history.db. Entries keep atuin's ids so history imported from every machine synced to it
+// (and imported again later) is only stored once, and they're logged as opts.User whoever ran them.
+func ReadAtuin(path string, opts Options) ([]*domain.LogEntry, error) {
+	db, err := openDatabase(path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	deleted := "NULL"
+	if ok, err := hasColumn(db, "history", "deleted_at"); err != nil {
+		return nil, err
+	} else if ok { // older versions can't delete history
+		deleted = "deleted_at"
+	}
+	rows, err := db.Query(`SELECT id, timestamp, duration, exit, command, cwd, session, hostname FROM history
+		WHERE ` + deleted + ` IS NULL ORDER BY timestamp`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query atuin history: %w", err)
+	}
+	defer rows.Close()
+
+	c := newCollector("atuin", opts)
+	for rows.Next() {
+		var id, command, cwd, session, hostname string
+		var ts, duration, exit int64
+		if err := rows.Scan(&id, &ts, &duration, &exit, &command, &cwd, &session, &hostname); err != nil {
+			return nil, fmt.Errorf("failed to scan atuin history: %w", err)
+		}
+
+		// times are in nanoseconds, the duration and exit code are -1 when atuin didn't see the command finish
+		entry := &domain.LogEntry{Command: command, Timestamp: ts / 1e9, SessionID: session}
+		var known []string
+		if duration >= 0 {
+			entry.EndTimestamp = (ts + duration) / 1e9
+		}
+		if exit >= 0 {
+			entry.ExitCode = int32(exit)
+			known = append(known, "exit_code")
+		}
+		if cwd != "" && cwd != "unknown" {
+			entry.WorkingDirectory = cwd
+			known = append(known, "cwd")
+		}
+		// written as host:user, the user is whoever is importing so the entries show up in (and sync with) their history,
+		// atuin's own is kept in a label
+		entry.Hostname, _, _ = strings.Cut(hostname, ":")
+		entry.Labels = map[string]string{"atuin_host": hostname}
+
+		if parsed, err := uuid.Parse(id); err == nil { // atuin's are uuids written without dashes
+			entry.EventID = parsed.String()
+		}
+		c.add(entry, id, known...)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+	return c.finish(), nil
+}
+
+// ReadMcfly reads McFly's history.db. Its ids are only unique to the database so they're combined with when the command
+// was run for dedupe.
+func ReadMcfly(path string, opts Options) ([]*domain.LogEntry, error) {
+	db, err := openDatabase(path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	columns := []string{"exit_code", "session_id", "dir", "old_dir"}
+	selected := []string{"id", "cmd", "when_run"}
+	for _, column := range columns { // added over several releases
+		ok, err := hasColumn(db, "commands", column)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			column = "NULL"
+		}
+		selected = append(selected, column)
+	}
+	rows, err := db.Query("SELECT " + strings.Join(selected, ", ") + " FROM commands ORDER BY when_run, id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query mcfly history: %w", err)
+	}
+	defer rows.Close()
+
+	c := newCollector("mcfly", opts)
+	for rows.Next() {
+		var id, ts int64
+		var command string
+		var exit sql.NullInt64
+		var session, dir, oldDir sql.NullString
+		if err := rows.Scan(&id, &command, &ts, &exit, &session, &dir, &oldDir); err != nil {
+			return nil, fmt.Errorf("failed to scan mcfly history: %w", err)
+		}
+
+		entry := &domain.LogEntry{Command: command, Timestamp: ts}
+		var known []string
+		if exit.Valid {
+			entry.ExitCode = int32(exit.Int64)
+			known = append(known, "exit_code")
+		}
+		if session.Valid && session.String != "" {
+			entry.SessionID = session.String
+		}
+		if dir.Valid && dir.String != "" {
+			entry.WorkingDirectory = dir.String
+			known = append(known, "cwd")
+		}
+		if oldDir.Valid && oldDir.String != "" {
+			entry.PrevWorkingDirectory = oldDir.String
+			known = append(known, "prev_cwd")
+		}
+		c.add(entry, fmt.Sprintf("%d\x00%d\x00%s", id, ts, command), known...)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+	return c.finish(), nil
+}
+
+// openDatabase opens another tool's sqlite database read only, waiting if it's being written to
+func openDatabase(path string) (*sql.DB, error) {
+	source := (&url.URL{Scheme: "file", OmitHost: true, Path: path, RawQuery: "mode=ro&_pragma=busy_timeout(5000)"}).String()
+	db, err := sql.Open("sqlite", source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open db: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping db: %w", err)
+	}
+	return db, nil
+}
+
+func hasColumn(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return false, fmt.Errorf("failed to read the columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	found, exists := false, false
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, fmt.Errorf("failed to read the columns of %s: %w", table, err)
+		}
+		exists = true
+		found = found || name == column
+	}
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("failed to read the columns of %s: %w", table, err)
+	}
+	if !exists {
+		return false, fmt.Errorf("no %s table, is this the right database?", table)
+	}
+	return found, nil
+}
+
+func atuinPath(home string) string {
+	if path := os.Getenv("ATUIN_DB_PATH"); path != "" {
+		return path
+	}
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "atuin", "history.db")
+	}
+	return filepath.Join(home, ".local", "share", "atuin", "history.db")
+}
+
+func mcflyPath(home string) string {
+	paths := []string{
+		filepath.Join(home, ".local", "share", "mcfly", "history.db"),
+		filepath.Join(home, "Library", "Application Support", "McFly", "history.db"), // macOS
+		filepath.Join(home, ".mcfly", "history.db"),                                  // before 0.5.10
+	}
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		paths[0] = filepath.Join(dir, "mcfly", "history.db")
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return paths[0]
+}
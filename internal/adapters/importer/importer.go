@@ -0,0 +1,184 @@
+package importer
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/WillRabalais04/terminalLog/internal/core/domain"
+	"github.com/google/uuid"
+)
+
+// Options are what history files don't record about the commands in them
+type Options struct {
+	User     string
+	Hostname string
+	ModTime  time.Time // undated commands (eg. bash without HISTTIMEFORMAT) are timed counting back from here
+}
+
+// Format is a history format that can be imported
+type Format struct {
+	Name string
+	Path func(home string) string // where the tool keeps its history by default
+	Read func(path string, opts Options) ([]*domain.LogEntry, error)
+}
+
+var Formats = []Format{
+	{Name: "bash", Path: bashPath, Read: readFile(ParseBash)},
+	{Name: "zsh", Path: zshPath, Read: readFile(ParseZsh)},
+	{Name: "fish", Path: fishPath, Read: readFile(ParseFish)},
+	{Name: "atuin", Path: atuinPath, Read: ReadAtuin},
+	{Name: "mcfly", Path: mcflyPath, Read: ReadMcfly},
+}
+
+func GetFormat(name string) (Format, error) {
+	for _, format := range Formats {
+		if format.Name == name {
+			return format, nil
+		}
+	}
+	names := make([]string, 0, len(Formats))
+	for _, format := range Formats {
+		names = append(names, format.Name)
+	}
+	return Format{}, fmt.Errorf("unknown history format %q (should be %s)", name, strings.Join(names, ", "))
+}
+
+// ReadFile imports the history at path in the given format
+func ReadFile(format Format, path string, opts Options) ([]*domain.LogEntry, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	entries, err := format.Read(path, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// readFile adapts a history file parser, undated commands are timed from when the file was last written
+func readFile(parse func(r io.Reader, opts Options) ([]*domain.LogEntry, error)) func(string, Options) ([]*domain.LogEntry, error) {
+	return func(path string, opts Options) ([]*domain.LogEntry, error) {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		if info, err := file.Stat(); err == nil && opts.ModTime.IsZero() {
+			opts.ModTime = info.ModTime()
+		}
+		return parse(file, opts)
+	}
+}
+
+// namespace of the imported entries' ids, derived from what was run so importing the same history again inserts nothing
+var namespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("https://github.com/WillRabalais04/terminalLog/import"))
+
+// collector builds entries for one history source
+type collector struct {
+	source      string
+	opts        Options
+	entries     []*domain.LogEntry
+	runs        map[string]int // runs of a command at the same second seen so far, they're told apart by their ids
+	undatedRuns []string       // in the order they were run
+}
+
+func newCollector(source string, opts Options) *collector {
+	return &collector{source: source, opts: opts, runs: map[string]int{}}
+}
+
+// add fills in what every imported entry has, marking fields that weren't in the history (other than known) as not
+// captured. key identifies the entry within its source when the source has its own ids, otherwise its time and command
+// are used. An id already set on the entry is kept.
+func (c *collector) add(entry *domain.LogEntry, key string, known ...string) {
+	entry.Command = strings.ToValidUTF8(entry.Command, "�")
+	if strings.TrimSpace(entry.Command) == "" {
+		return
+	}
+	if entry.User == "" {
+		entry.User = c.opts.User
+	}
+	if entry.Hostname == "" {
+		entry.Hostname = c.opts.Hostname
+	}
+	if key == "" {
+		run := fmt.Sprintf("%d\x00%s", entry.Timestamp, entry.Command)
+		key = fmt.Sprintf("%s\x00%d", run, c.runs[run])
+		c.runs[run]++
+	}
+	if entry.EventID == "" { // kept when the source's ids are already uuids
+		entry.EventID = uuid.NewSHA1(namespace, []byte(strings.Join([]string{c.source, entry.User, entry.Hostname, key}, "\x00"))).String()
+	}
+	entry.Status = domain.StatusCompleted
+	entry.LoggedSuccessfully = true
+	if entry.Labels == nil {
+		entry.Labels = map[string]string{}
+	}
+	entry.Labels["imported"] = c.source
+
+	fields, _ := domain.ResolveFields("", append(known, "hostname", "labels"), nil)
+	fields.Apply(entry)
+	c.entries = append(c.entries, entry)
+}
+
+// dated adds a command run at ts, duration is in seconds (0 when unknown)
+func (c *collector) dated(command string, ts, duration int64) {
+	entry := &domain.LogEntry{Command: command, Timestamp: ts}
+	if duration > 0 {
+		entry.EndTimestamp = ts + duration
+	}
+	c.add(entry, "")
+}
+
+// undated adds a command with no time, only its last run is kept since there's nothing to tell the runs apart by
+func (c *collector) undated(command string) {
+	if strings.TrimSpace(command) == "" {
+		return
+	}
+	c.undatedRuns = append(c.undatedRuns, command)
+}
+
+// finish times the undated commands a second apart ending at the file's modification time, oldest first
+func (c *collector) finish() []*domain.LogEntry {
+	last := map[string]int{}
+	for i, command := range c.undatedRuns {
+		last[command] = i
+	}
+	end := c.opts.ModTime.Unix()
+	if c.opts.ModTime.IsZero() {
+		end = time.Now().Unix()
+	}
+	for i, command := range c.undatedRuns {
+		if last[command] != i {
+			continue
+		}
+		entry := &domain.LogEntry{Command: command, Timestamp: end - int64(len(c.undatedRuns)-1-i)}
+		entry.Labels = map[string]string{"ts": "estimated"}
+		c.add(entry, "undated\x00"+command)
+	}
+	slices.SortStableFunc(c.entries, func(a, b *domain.LogEntry) int { return cmp.Compare(a.Timestamp, b.Timestamp) })
+	return c.entries
+}
+
+func bashPath(home string) string {
+	return filepath.Join(home, ".bash_history")
+}
+
+func zshPath(home string) string {
+	if dir := os.Getenv("ZDOTDIR"); dir != "" {
+		return filepath.Join(dir, ".zsh_history")
+	}
+	return filepath.Join(home, ".zsh_history")
+}
+
+func fishPath(home string) string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "fish", "fish_history")
+	}
+	return filepath.Join(home, ".local", "share", "fish", "fish_history")
+}
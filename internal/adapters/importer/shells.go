@@ -0,0 +1,154 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/WillRabalais04/terminalLog/internal/core/domain"
+)
+
+// ParseBash reads ~/.bash_history. With HISTTIMEFORMAT set bash writes a '#<unix time>' line before each command and
+// everything up to the next one is the command (multi line with lithist), otherwise every line is an undated command.
+func ParseBash(r io.Reader, opts Options) ([]*domain.LogEntry, error) {
+	c := newCollector("bash", opts)
+	var command []string
+	ts := int64(-1)
+	flush := func() {
+		if ts >= 0 && len(command) > 0 {
+			c.dated(strings.Join(command, "\n"), ts, 0)
+		}
+		command = nil
+	}
+
+	scanner := newScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			if stamp, err := strconv.ParseInt(line[1:], 10, 64); err == nil {
+				flush()
+				ts = stamp
+				continue
+			}
+		}
+		if ts < 0 { // no timestamp yet
+			c.undated(line)
+			continue
+		}
+		command = append(command, line)
+	}
+	flush()
+	return c.finish(), scanner.Err()
+}
+
+var zshExtended = regexp.MustCompile(`^: *(\d+):(\d+);`)
+
+// ParseZsh reads ~/.zsh_history, either with EXTENDED_HISTORY (': <start>:<duration>;<command>') or plain undated lines.
+// Lines of a multi line command end in a backslash.
+func ParseZsh(r io.Reader, opts Options) ([]*domain.LogEntry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	c := newCollector("zsh", opts)
+	lines := strings.Split(string(unmetafy(data)), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		for strings.HasSuffix(line, "\\") && i+1 < len(lines) && !zshExtended.MatchString(lines[i+1]) {
+			i++
+			line = line[:len(line)-1] + "\n" + lines[i]
+		}
+
+		match := zshExtended.FindStringSubmatch(line)
+		if match == nil {
+			c.undated(line)
+			continue
+		}
+		ts, _ := strconv.ParseInt(match[1], 10, 64)
+		duration, _ := strconv.ParseInt(match[2], 10, 64)
+		c.dated(line[len(match[0]):], ts, duration)
+	}
+	return c.finish(), nil
+}
+
+// unmetafy undoes zsh's escaping of bytes it uses internally: they're written as 0x83 followed by the byte xor 32
+func unmetafy(data []byte) []byte {
+	if bytes.IndexByte(data, 0x83) < 0 {
+		return data
+	}
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] == 0x83 && i+1 < len(data) {
+			i++
+			out = append(out, data[i]^32)
+			continue
+		}
+		out = append(out, data[i])
+	}
+	return out
+}
+
+// ParseFish reads fish_history, a yaml like list of '- cmd: <command>' items with a 'when: <unix time>' under each.
+// Commands have newlines and backslashes escaped.
+func ParseFish(r io.Reader, opts Options) ([]*domain.LogEntry, error) {
+	c := newCollector("fish", opts)
+	var command string
+	ts := int64(-1)
+	flush := func() {
+		switch {
+		case command == "":
+		case ts >= 0:
+			c.dated(command, ts, 0)
+		default:
+			c.undated(command)
+		}
+		command, ts = "", -1
+	}
+
+	scanner := newScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if cmd, ok := strings.CutPrefix(line, "- cmd: "); ok {
+			flush()
+			command = unescapeFish(cmd)
+		} else if when, ok := strings.CutPrefix(line, "  when: "); ok {
+			if stamp, err := strconv.ParseInt(strings.TrimSpace(when), 10, 64); err == nil {
+				ts = stamp
+			}
+		}
+	}
+	flush()
+	return c.finish(), scanner.Err()
+}
+
+func unescapeFish(command string) string {
+	if !strings.Contains(command, `\`) {
+		return command
+	}
+	var out strings.Builder
+	for i := 0; i < len(command); i++ {
+		if command[i] == '\\' && i+1 < len(command) {
+			switch command[i+1] {
+			case 'n':
+				out.WriteByte('\n')
+				i++
+				continue
+			case '\\':
+				out.WriteByte('\\')
+				i++
+				continue
+			}
+		}
+		out.WriteByte(command[i])
+	}
+	return out.String()
+}
+
+func newScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024) // pasted scripts make for very long lines
+	return scanner
+}
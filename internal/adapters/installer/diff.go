@@ -0,0 +1,102 @@
+package installer
+
+import (
+	"fmt"
+	"strings"
+)
+
+type diffLine struct {
+	kind   byte // ' ', '-' or '+'
+	text   string
+	before int // line numbers (0 based) in before/after at this point of the diff
+	after  int
+}
+
+// unifiedDiff renders the hunks that turn before into after with the given lines of context (rc files are small so
+// the quadratic LCS is fine)
+func unifiedDiff(before, after string, context int) string {
+	a, b := splitLines(before), splitLines(after)
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i], i, j})
+			i, j = i+1, j+1
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', a[i], i, j})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j], i, j})
+			j++
+		}
+	}
+
+	var diff strings.Builder
+	for start := 0; start < len(lines); {
+		first := start
+		for first < len(lines) && lines[first].kind == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+		// extend the hunk while the next change is close enough for the contexts to touch
+		last := first
+		for k := first; k < len(lines) && k <= last+2*context; k++ {
+			if lines[k].kind != ' ' {
+				last = k
+			}
+		}
+		from, to := max(first-context, start), min(last+context+1, len(lines))
+		writeHunk(&diff, lines[from:to])
+		start = to
+	}
+	return diff.String()
+}
+
+func writeHunk(diff *strings.Builder, hunk []diffLine) {
+	beforeLen, afterLen := 0, 0
+	for _, line := range hunk {
+		if line.kind != '+' {
+			beforeLen++
+		}
+		if line.kind != '-' {
+			afterLen++
+		}
+	}
+	fmt.Fprintf(diff, "@@ -%s +%s @@\n", hunkRange(hunk[0].before, beforeLen), hunkRange(hunk[0].after, afterLen))
+	for _, line := range hunk {
+		fmt.Fprintf(diff, "%c%s\n", line.kind, line.text)
+	}
+}
+
+func hunkRange(start, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start) // an empty range names the line before it
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
+
+func splitLines(contents string) []string {
+	if contents == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(contents, "\n"), "\n")
+}
@@ -0,0 +1,616 @@
+package installer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/WillRabalais04/terminalLog/hooks"
+)
+
+const (
+	StartMarker = "### >>> logger start >>>"
+	EndMarker   = "### <<< logger end <<<"
+
+	legacyBinDir = "/usr/local/bin" // where the hooks expect the binaries, and where the Makefile used to put them
+)
+
+// Shells that termlogger has hooks for
+var Shells = []string{"bash", "zsh", "fish", "nu"}
+
+type Options struct {
+	Home        string
+	Prefix      string   // binaries are installed into <prefix>/bin
+	Shells      []string // shells to hook, detected when empty
+	Executable  string   // binary installed as termlogger, termloggerd is taken from the same directory
+	EnvFile     string   // installed as the config's .env when set
+	ProjectRoot string   // checkout json mode logs are written into
+	Purge       bool     // uninstall also removes the config dir
+	GOOS        string   // defaults to runtime.GOOS, bash login shells are hooked too on darwin
+	Now         time.Time
+}
+
+type Action string
+
+const (
+	Create Action = "create"
+	Update Action = "update"
+	Remove Action = "remove"
+)
+
+// Change is a single file or directory that setup/uninstall creates, rewrites or deletes
+type Change struct {
+	Action Action
+	Path   string
+	Before string // current contents (empty for new files, binaries and directories)
+	After  string
+	Source string // binaries are copied from here instead of written from After
+	Dir    bool
+	Mode   os.FileMode
+	Backup string // the file is copied here before it's changed (set for files the user also edits)
+}
+
+type Plan struct {
+	Changes []Change
+	Notes   []string // things the user has to do themselves
+}
+
+// PlanSetup works out what installing termlogger for opts.Shells would change without touching anything, files that are
+// already up to date aren't part of the plan so running it twice is a no-op
+func PlanSetup(opts Options) (*Plan, error) {
+	opts = withDefaults(opts)
+	plan := &Plan{}
+	binDir := filepath.Join(opts.Prefix, "bin")
+
+	if opts.Executable != "" {
+		if err := plan.copyBinary(opts.Executable, filepath.Join(binDir, "termlogger")); err != nil {
+			return nil, err
+		}
+		daemon := filepath.Join(filepath.Dir(opts.Executable), "termloggerd")
+		if _, err := os.Stat(daemon); err == nil {
+			if err := plan.copyBinary(daemon, filepath.Join(binDir, "termloggerd")); err != nil {
+				return nil, err
+			}
+		} else {
+			plan.Notes = append(plan.Notes, fmt.Sprintf("termloggerd wasn't found next to %s, the hook will write entries itself.", opts.Executable))
+		}
+	}
+
+	configDir := filepath.Join(opts.Home, ".termlogger")
+	if _, err := os.Stat(configDir); os.IsNotExist(err) {
+		plan.Changes = append(plan.Changes, Change{Action: Create, Path: configDir, Dir: true, Mode: 0755})
+	}
+	if opts.EnvFile != "" {
+		env, err := os.ReadFile(opts.EnvFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read env file: %w", err)
+		}
+		if err := plan.write(filepath.Join(configDir, ".env"), string(env), 0600, opts.Now); err != nil {
+			return nil, err
+		}
+	}
+	if opts.ProjectRoot != "" {
+		if err := plan.write(filepath.Join(configDir, "project_root"), opts.ProjectRoot+"\n", 0644, time.Time{}); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, shell := range opts.Shells {
+		if err := plan.hookShell(shell, opts, binDir); err != nil {
+			return nil, err
+		}
+	}
+
+	if !slices.Contains(filepath.SplitList(os.Getenv("PATH")), binDir) {
+		plan.Notes = append(plan.Notes, fmt.Sprintf("%s isn't on your PATH, add it to run termlogger yourself (the hooks use the full path).", binDir))
+	}
+	plan.noteLegacyInstall(binDir)
+	return plan, nil
+}
+
+// PlanUninstall works out what removing termlogger would change, every shell's hook is removed whether it was
+// detected or not
+func PlanUninstall(opts Options) (*Plan, error) {
+	opts = withDefaults(opts)
+	plan := &Plan{}
+
+	for _, shell := range Shells {
+		rc := RCPath(shell, opts.Home)
+		switch shell {
+		case "fish":
+			plan.remove(rc, false)
+		case "nu":
+			plan.remove(filepath.Join(filepath.Dir(rc), "termlogger.nu"), false)
+			fallthrough
+		default:
+			if err := plan.removeBlock(rc, opts.Now); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, name := range bashLoginFiles {
+		if err := plan.removeBlock(filepath.Join(opts.Home, name), opts.Now); err != nil {
+			return nil, err
+		}
+	}
+
+	binDir := filepath.Join(opts.Prefix, "bin")
+	plan.remove(filepath.Join(binDir, "termlogger"), true)
+	plan.remove(filepath.Join(binDir, "termloggerd"), true)
+
+	configDir := filepath.Join(opts.Home, ".termlogger")
+	if _, err := os.Stat(configDir); err == nil {
+		if opts.Purge {
+			plan.Changes = append(plan.Changes, Change{Action: Remove, Path: configDir, Dir: true})
+		} else {
+			plan.Notes = append(plan.Notes, fmt.Sprintf("kept your config and logs in %s (pass --purge to remove them).", configDir))
+		}
+	}
+	plan.noteLegacyInstall(binDir)
+	return plan, nil
+}
+
+func withDefaults(opts Options) Options {
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	if opts.GOOS == "" {
+		opts.GOOS = runtime.GOOS
+	}
+	if len(opts.Shells) == 0 {
+		opts.Shells = DetectShells(opts.Home)
+	}
+	return opts
+}
+
+func (p *Plan) hookShell(shell string, opts Options, binDir string) error {
+	rc := RCPath(shell, opts.Home)
+	switch shell {
+	case "bash", "zsh":
+		hook, err := renderHook("termlogger_hook.sh", binDir)
+		if err != nil {
+			return err
+		}
+		before, err := readFile(rc)
+		if err != nil {
+			return err
+		}
+		if err := p.write(rc, UpsertBlock(before, hook), 0644, opts.Now); err != nil {
+			return err
+		}
+		if shell == "bash" && opts.GOOS == "darwin" { // terminals on macOS start login shells, which skip .bashrc
+			return p.hookBashLogin(opts)
+		}
+		return nil
+	case "fish": // conf.d is sourced by fish itself so the hook gets its own file
+		hook, err := renderHook("termlogger_hook.fish", binDir)
+		if err != nil {
+			return err
+		}
+		return p.write(rc, hook, 0644, time.Time{})
+	case "nu": // nushell can't source files it doesn't know about at parse time, so config.nu sources the hook by path
+		hook, err := renderHook("termlogger_hook.nu", binDir)
+		if err != nil {
+			return err
+		}
+		hookPath := filepath.Join(filepath.Dir(rc), "termlogger.nu")
+		if err := p.write(hookPath, hook, 0644, time.Time{}); err != nil {
+			return err
+		}
+		before, err := readFile(rc)
+		if err != nil {
+			return err
+		}
+		block := fmt.Sprintf("%s\nsource '%s'\n%s\n", StartMarker, hookPath, EndMarker)
+		return p.write(rc, UpsertBlock(before, block), 0644, opts.Now)
+	default:
+		return fmt.Errorf("unsupported shell %q (supported: %s)", shell, strings.Join(Shells, ", "))
+	}
+}
+
+// bashLoginFiles are what bash login shells read instead of .bashrc, the first that exists
+var bashLoginFiles = []string{".bash_profile", ".bash_login", ".profile"}
+
+// hookBashLogin has the login file source .bashrc (and so the hook) unless it already does
+func (p *Plan) hookBashLogin(opts Options) error {
+	path := filepath.Join(opts.Home, bashLoginFiles[0])
+	for _, name := range bashLoginFiles {
+		if _, err := os.Stat(filepath.Join(opts.Home, name)); err == nil {
+			path = filepath.Join(opts.Home, name)
+			break
+		}
+	}
+	before, err := readFile(path)
+	if err != nil {
+		return err
+	}
+	if rest, _ := RemoveBlock(before); strings.Contains(rest, ".bashrc") {
+		return p.write(path, rest, 0644, opts.Now) // dropping our block if the user has since added their own
+	}
+	block := fmt.Sprintf("%s\nif [ -n \"$BASH_VERSION\" ] && [ -f \"$HOME/.bashrc\" ]; then . \"$HOME/.bashrc\"; fi\n%s\n", StartMarker, EndMarker)
+	return p.write(path, UpsertBlock(before, block), 0644, opts.Now)
+}
+
+// write plans path's contents to become after, backing it up first when backupAt is set and it already exists
+func (p *Plan) write(path, after string, mode os.FileMode, backupAt time.Time) error {
+	before, err := readFile(path)
+	if err != nil {
+		return err
+	}
+	_, statErr := os.Stat(path)
+	if statErr == nil && before == after {
+		return nil
+	}
+
+	change := Change{Action: Create, Path: path, Before: before, After: after, Mode: mode}
+	if statErr == nil {
+		change.Action, change.Mode = Update, modeOf(path, mode)
+		if !backupAt.IsZero() {
+			change.Backup = backupPath(path, backupAt)
+		}
+	}
+	p.Changes = append(p.Changes, change)
+	return nil
+}
+
+func (p *Plan) copyBinary(src, dst string) error {
+	if same, _ := samePath(src, dst); same {
+		return nil // re-running the installed binary's setup
+	}
+	srcBytes, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", src, err)
+	}
+	change := Change{Action: Create, Path: dst, Source: src, Mode: 0755}
+	if dstBytes, err := os.ReadFile(dst); err == nil {
+		if bytes.Equal(srcBytes, dstBytes) {
+			return nil
+		}
+		change.Action = Update
+	}
+	p.Changes = append(p.Changes, change)
+	return nil
+}
+
+// noteLegacyInstall points out binaries the Makefile put in /usr/local/bin, they need sudo so they're left alone
+func (p *Plan) noteLegacyInstall(binDir string) {
+	if binDir == legacyBinDir {
+		return
+	}
+	if _, err := os.Stat(filepath.Join(legacyBinDir, "termlogger")); err == nil {
+		p.Notes = append(p.Notes, fmt.Sprintf("an older install is still in %s, remove it with 'sudo rm %s/termlogger %s/termloggerd'.", legacyBinDir, legacyBinDir, legacyBinDir))
+	}
+}
+
+func (p *Plan) removeBlock(path string, backupAt time.Time) error {
+	before, err := readFile(path)
+	if err != nil {
+		return err
+	}
+	if after, found := RemoveBlock(before); found {
+		p.Changes = append(p.Changes, Change{Action: Update, Path: path, Before: before, After: after, Mode: modeOf(path, 0644), Backup: backupPath(path, backupAt)})
+	}
+	return nil
+}
+
+func (p *Plan) remove(path string, binary bool) {
+	if _, err := os.Lstat(path); err != nil {
+		return
+	}
+	change := Change{Action: Remove, Path: path}
+	if binary {
+		change.Source = path // only named in the diff
+	} else {
+		change.Before, _ = readFile(path)
+	}
+	p.Changes = append(p.Changes, change)
+}
+
+// Apply makes the change, files are written to a temp file and renamed into place so a failure never leaves half of one
+func (c Change) Apply() error {
+	if c.Action == Remove {
+		if c.Dir {
+			return os.RemoveAll(c.Path)
+		}
+		if err := os.Remove(c.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if c.Dir {
+		return os.MkdirAll(c.Path, c.Mode)
+	}
+
+	path := c.Path
+	if c.Source == "" { // rc files kept in a dotfiles repo (stow, chezmoi, yadm) are symlinks, write through them
+		if resolved, err := filepath.EvalSymlinks(c.Path); err == nil {
+			path = resolved
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if c.Backup != "" {
+		if err := os.WriteFile(c.Backup, []byte(c.Before), c.Mode); err != nil {
+			return fmt.Errorf("could not back up %s: %w", c.Path, err)
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // a no-op once it's been renamed
+
+	if c.Source != "" {
+		src, err := os.Open(c.Source)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		_, err = io.Copy(tmp, src)
+		src.Close()
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+	} else if _, err := tmp.WriteString(c.After); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), c.Mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path) // renaming (rather than rewriting) also replaces a binary that's running
+}
+
+func (c Change) String() string {
+	verb := map[Action]string{Create: "created", Update: "updated", Remove: "removed"}[c.Action]
+	if c.Backup != "" {
+		return fmt.Sprintf("%s %s (backup at %s)", verb, c.Path, c.Backup)
+	}
+	return fmt.Sprintf("%s %s", verb, c.Path)
+}
+
+// Diff shows the change as a unified diff, binaries and directories are only named
+func (c Change) Diff() string {
+	if c.Dir || c.Source != "" {
+		return fmt.Sprintf("%s %s\n", c.Action, c.Path)
+	}
+	from, to := c.Path, c.Path
+	switch c.Action {
+	case Create:
+		from = "/dev/null"
+	case Remove:
+		to = "/dev/null"
+	}
+	var diff strings.Builder
+	fmt.Fprintf(&diff, "--- %s\n+++ %s\n", from, to)
+	diff.WriteString(unifiedDiff(c.Before, c.After, 3))
+	return diff.String()
+}
+
+// UpsertBlock replaces the marked hook block in an rc file's contents with block where it is, appending it if there
+// isn't one yet
+func UpsertBlock(contents, block string) string {
+	lines := splitLines(contents)
+	blocks := findBlocks(lines)
+	block = strings.Trim(block, "\n")
+	if len(blocks) == 0 {
+		if contents = strings.TrimRight(contents, "\n"); contents != "" {
+			contents += "\n\n"
+		}
+		return contents + block + "\n"
+	}
+
+	var kept []string
+	next := 0
+	for i, found := range blocks {
+		kept = append(kept, lines[next:found[0]]...)
+		if i == 0 { // any later copies are dropped
+			kept = append(kept, block)
+		}
+		next = found[1] + 1
+	}
+	return strings.Join(append(kept, lines[next:]...), "\n") + "\n"
+}
+
+// RemoveBlock strips every marked hook block from an rc file's contents (and the blank lines left behind at the end),
+// reporting whether there was one
+func RemoveBlock(contents string) (string, bool) {
+	lines := splitLines(contents)
+	blocks := findBlocks(lines)
+	if len(blocks) == 0 {
+		return contents, false
+	}
+
+	var kept []string
+	next := 0
+	for _, found := range blocks {
+		kept = append(kept, lines[next:found[0]]...)
+		next = found[1] + 1
+	}
+	stripped := strings.TrimRight(strings.Join(append(kept, lines[next:]...), "\n"), "\n \t")
+	if stripped == "" {
+		return "", true
+	}
+	return stripped + "\n", true
+}
+
+// findBlocks returns the first and last line of each marked hook block. A start marker that's never ended (eg. the end
+// was deleted by hand) isn't a block, the lines after it are the user's.
+func findBlocks(lines []string) [][2]int {
+	var blocks [][2]int
+	start := -1
+	for i, line := range lines {
+		switch strings.TrimSpace(line) {
+		case StartMarker:
+			start = i // supersedes an earlier start that wasn't ended
+		case EndMarker:
+			if start >= 0 {
+				blocks = append(blocks, [2]int{start, i})
+				start = -1
+			}
+		}
+	}
+	return blocks
+}
+
+// RCPath is the file a shell's hook is written into
+func RCPath(shell, home string) string {
+	switch shell {
+	case "bash":
+		return filepath.Join(home, ".bashrc")
+	case "zsh":
+		if zdotdir := os.Getenv("ZDOTDIR"); zdotdir != "" {
+			return filepath.Join(zdotdir, ".zshrc")
+		}
+		return filepath.Join(home, ".zshrc")
+	case "fish":
+		return filepath.Join(configHome(home), "fish", "conf.d", "termlogger.fish")
+	case "nu":
+		return nuConfigPath(home)
+	}
+	return ""
+}
+
+func configHome(home string) string {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return xdg
+	}
+	return filepath.Join(home, ".config")
+}
+
+func nuConfigPath(home string) string {
+	if os.Getenv("XDG_CONFIG_HOME") == "" && home == userHome() {
+		if _, err := exec.LookPath("nu"); err == nil { // nushell knows best (it moves between versions and platforms)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if out, err := exec.CommandContext(ctx, "nu", "-c", "$nu.config-path").Output(); err == nil && strings.TrimSpace(string(out)) != "" {
+				return strings.TrimSpace(string(out))
+			}
+		}
+		if runtime.GOOS == "darwin" {
+			return filepath.Join(home, "Library", "Application Support", "nushell", "config.nu")
+		}
+	}
+	return filepath.Join(configHome(home), "nushell", "config.nu")
+}
+
+// DetectShells returns the user's login shells ($SHELL and their passwd entry) plus any shell that's already hooked,
+// so re-running setup updates every existing install
+func DetectShells(home string) []string {
+	var shells []string
+	add := func(shellPath string) {
+		name := filepath.Base(shellPath)
+		if name == "nushell" {
+			name = "nu"
+		}
+		if slices.Contains(Shells, name) && !slices.Contains(shells, name) {
+			shells = append(shells, name)
+		}
+	}
+
+	add(os.Getenv("SHELL"))
+	add(passwdShell("/etc/passwd", os.Getuid()))
+	for _, shell := range Shells {
+		contents, _ := readFile(RCPath(shell, home))
+		if (shell == "fish" && contents != "") || strings.Contains(contents, StartMarker) {
+			add(shell)
+		}
+	}
+	return shells
+}
+
+// passwdShell looks up uid's login shell, empty if it can't be read (eg. users from LDAP)
+func passwdShell(path string, uid int) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) == 7 && fields[2] == strconv.Itoa(uid) {
+			return fields[6]
+		}
+	}
+	return ""
+}
+
+// renderHook points an embedded hook at the binaries in binDir
+// renderHook fills binDir into the hook's _termlogger_bin, which every call to the binaries goes through
+func renderHook(name, binDir string) (string, error) {
+	hook, err := hooks.FS.ReadFile(name)
+	if err != nil {
+		return "", fmt.Errorf("could not read %s hook: %w", name, err)
+	}
+	placeholder := "'" + legacyBinDir + "'"
+	if strings.Count(string(hook), placeholder) != 1 {
+		return "", fmt.Errorf("the %s hook doesn't set _termlogger_bin once", name)
+	}
+	return strings.TrimLeft(strings.Replace(string(hook), placeholder, quoteFor(name, binDir), 1), "\n"), nil
+}
+
+// quoteFor quotes s as a string literal in the hook's shell
+func quoteFor(name, s string) string {
+	switch filepath.Ext(name) {
+	case ".fish":
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+	case ".nu": // single quoted strings can't hold a quote at all
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+	default:
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	}
+}
+
+func readFile(path string) (string, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("could not read %s: %w", path, err)
+	}
+	return string(contents), nil
+}
+
+func modeOf(path string, fallback os.FileMode) os.FileMode {
+	if info, err := os.Stat(path); err == nil {
+		return info.Mode().Perm()
+	}
+	return fallback
+}
+
+func backupPath(path string, now time.Time) string {
+	return fmt.Sprintf("%s.backup.%d", path, now.Unix())
+}
+
+func samePath(a, b string) (bool, error) {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	bInfo, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(aInfo, bInfo), nil
+}
+
+func userHome() string {
+	home, _ := os.UserHomeDir()
+	return home
+}
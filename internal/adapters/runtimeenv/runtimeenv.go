@@ -0,0 +1,189 @@
+package runtimeenv
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/WillRabalais04/terminalLog/internal/core/domain"
+)
+
+// toolchains maps the name recorded in the context to the binaries tried on PATH and the args printing their version
+var toolchains = []struct {
+	name     string
+	binaries []string
+	args     []string
+}{
+	{name: "go", binaries: []string{"go"}, args: []string{"version"}},
+	{name: "node", binaries: []string{"node"}, args: []string{"--version"}},
+	{name: "python", binaries: []string{"python3", "python"}, args: []string{"--version"}},
+}
+
+var versionPattern = regexp.MustCompile(`\d+(\.\d+)+`)
+
+// Detect reports what the current process is running inside of. Files like /.dockerenv are looked up under root ("/"
+// outside of tests). Toolchain versions are cached in cachePath by binary so they only run when a binary changes, any
+// that fail or don't answer within versionBudget are left out until they do.
+func Detect(root, cachePath string, versionBudget time.Duration) *domain.RuntimeContext {
+	runtime := &domain.RuntimeContext{
+		Venv:   os.Getenv("VIRTUAL_ENV"),
+		Conda:  os.Getenv("CONDA_DEFAULT_ENV"),
+		Nix:    os.Getenv("IN_NIX_SHELL"),
+		Direnv: strings.TrimPrefix(os.Getenv("DIRENV_DIR"), "-"), // direnv prefixes the dir with a dash
+	}
+	runtime.Container = container(root)
+	if runtime.Container == "kubernetes" {
+		runtime.Pod = os.Getenv("HOSTNAME") // kubernetes names the pod's containers after it
+		if runtime.Pod == "" {
+			runtime.Pod, _ = os.Hostname()
+		}
+	}
+	runtime.Toolchains = toolchainVersions(cachePath, versionBudget)
+	return runtime
+}
+
+func container(root string) string {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return "kubernetes"
+	}
+	// pid 1's cgroup names the runtime on cgroup v1 hosts, v2 hides it so the marker files are checked too
+	if cgroup, err := os.ReadFile(filepath.Join(root, "proc/1/cgroup")); err == nil {
+		switch text := string(cgroup); {
+		case strings.Contains(text, "kubepods"):
+			return "kubernetes"
+		case strings.Contains(text, "libpod"):
+			return "podman"
+		case strings.Contains(text, "docker"):
+			return "docker"
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "run/.containerenv")); err == nil {
+		return "podman"
+	}
+	if _, err := os.Stat(filepath.Join(root, ".dockerenv")); err == nil {
+		return "docker"
+	}
+	return ""
+}
+
+// shimManagers maps the dir holding a version manager's shims (under its root) to the manager, which is asked for the
+// binary the shim picks in the current dir since the shim itself never changes when the version does
+var shimManagers = []struct {
+	rootEnv, rootDir, command string
+}{
+	{rootEnv: "PYENV_ROOT", rootDir: ".pyenv", command: "pyenv"},
+	{rootEnv: "ASDF_DATA_DIR", rootDir: ".asdf", command: "asdf"},
+}
+
+type cachedVersion struct {
+	ModTime int64  `json:"mod_time"`
+	Size    int64  `json:"size"`
+	Version string `json:"version"`
+	Failed  bool   `json:"failed,omitempty"` // not run again until the binary changes
+}
+
+// toolchainVersions resolves each toolchain on PATH, going through pyenv/asdf shims to the binary they'd run.
+func toolchainVersions(cachePath string, budget time.Duration) map[string]string {
+	cache := map[string]cachedVersion{}
+	if contents, err := os.ReadFile(cachePath); err == nil {
+		json.Unmarshal(contents, &cache) // a corrupt cache is rebuilt
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), budget)
+	defer cancel()
+
+	versions := map[string]string{}
+	changed := false
+	for _, toolchain := range toolchains {
+		for _, binary := range toolchain.binaries {
+			path, err := exec.LookPath(binary)
+			if err != nil {
+				continue
+			}
+			if path, err = resolveShim(ctx, path, binary); err != nil {
+				break // the shim's version isn't installed, or its manager is missing or over budget
+			}
+			if resolved, err := filepath.EvalSymlinks(path); err == nil {
+				path = resolved
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			cached, ok := cache[path]
+			if !ok || cached.ModTime != info.ModTime().UnixNano() || cached.Size != info.Size() {
+				if ctx.Err() != nil {
+					break // budget used up by the toolchains before it, try again next time
+				}
+				out, err := exec.CommandContext(ctx, path, toolchain.args...).CombinedOutput() // python 2 prints it to stderr
+				cached = cachedVersion{ModTime: info.ModTime().UnixNano(), Size: info.Size(), Version: versionPattern.FindString(string(out))}
+				cached.Failed = err != nil // broken or too slow, either way running it on every prompt won't help
+				if cached.Failed {
+					cached.Version = ""
+				}
+				cache[path], changed = cached, true
+			}
+			if cached.Version != "" {
+				versions[toolchain.name] = cached.Version
+			}
+			break
+		}
+	}
+	if changed {
+		saveCache(cachePath, cache)
+	}
+	if len(versions) == 0 {
+		return nil
+	}
+	return versions
+}
+
+// resolveShim returns the binary a version manager's shim at path runs in the current dir, or path if it isn't a shim
+func resolveShim(ctx context.Context, path, binary string) (string, error) {
+	dir := filepath.Dir(path)
+	if filepath.Base(dir) != "shims" {
+		return path, nil
+	}
+	root := filepath.Dir(dir)
+	for _, manager := range shimManagers {
+		if root != os.Getenv(manager.rootEnv) && filepath.Base(root) != manager.rootDir {
+			continue
+		}
+		command := filepath.Join(root, "bin", manager.command) // where git installs keep it, package managers put it on PATH
+		if _, err := os.Stat(command); err != nil {
+			command = manager.command
+		}
+		out, err := exec.CommandContext(ctx, command, "which", binary).Output()
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+	return path, nil
+}
+
+// saveCache replaces the cache in one rename since concurrent prompts can write it at the same time
+func saveCache(cachePath string, cache map[string]cachedVersion) {
+	contents, err := json.Marshal(cache)
+	if err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(cachePath), ".toolchains-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		return
+	}
+	os.Rename(tmp.Name(), cachePath)
+}
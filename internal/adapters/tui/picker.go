@@ -0,0 +1,337 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/WillRabalais04/terminalLog/internal/core/domain"
+)
+
+// Mode narrows the picker's results to part of the scope it was opened from
+type Mode int
+
+const (
+	ModeAll       Mode = iota // every host
+	ModeSession               // the shell the picker was opened from
+	ModeDirectory             // the current directory
+	ModeRepo                  // anywhere in the current git repo
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeSession:
+		return "this session"
+	case ModeDirectory:
+		return "this directory"
+	case ModeRepo:
+		return "this repo"
+	}
+	return "all hosts"
+}
+
+// Scope is where the picker was opened from
+type Scope struct {
+	User      string
+	SessionID string
+	Cwd       string
+	RepoRoot  string // empty outside a git repo
+}
+
+// Modes are the modes that make sense for the scope (eg. no repo mode outside a git repo), in the order ctrl-r cycles them
+func (s Scope) Modes() []Mode {
+	modes := []Mode{ModeAll}
+	if s.SessionID != "" {
+		modes = append(modes, ModeSession)
+	}
+	if s.Cwd != "" {
+		modes = append(modes, ModeDirectory)
+	}
+	if s.RepoRoot != "" {
+		modes = append(modes, ModeRepo)
+	}
+	return modes
+}
+
+// Filter builds the LogFilter for what's typed into the picker, newest first. Only the query's longest word is searched
+// for in the db (a search term only matches one substring), the rest are matched on the results by Matches.
+func Filter(query string, mode Mode, scope Scope, limit uint64) *domain.LogFilter {
+	builder := domain.NewFilterBuilder().SetFilterMode(domain.AND).SetOrderBy("ts").SetLimit(limit)
+	if scope.User != "" {
+		builder.AddFilterTerm("user_name", scope.User)
+	}
+	switch mode {
+	case ModeSession:
+		builder.AddFilterTerm("session_id", scope.SessionID)
+	case ModeDirectory:
+		builder.AddFilterTerm("cwd", scope.Cwd)
+	case ModeRepo:
+		builder.AddFilterTerm("git_repo_root", scope.RepoRoot)
+	}
+
+	var longest string
+	for _, word := range strings.Fields(query) {
+		if len(word) > len(longest) {
+			longest = word
+		}
+	}
+	if longest != "" {
+		builder.AddSearchTerm("command", longest)
+	}
+	return builder.Build()
+}
+
+// Matches reports whether command contains every word of the query, in any order and ignoring case
+func Matches(command, query string) bool {
+	command = strings.ToLower(command)
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if !strings.Contains(command, word) {
+			return false
+		}
+	}
+	return true
+}
+
+type KeyCode int
+
+const (
+	KeyRune KeyCode = iota
+	KeyUp
+	KeyDown
+	KeyEnter
+	KeyCancel
+	KeyBackspace
+	KeyClear // ctrl-u
+	KeyMode  // ctrl-r cycles the modes like reverse search cycles matches
+	KeyUnknown
+)
+
+type Key struct {
+	Code KeyCode
+	Rune rune
+}
+
+// ParseKeys splits what was read from the terminal into keys (a read can hold several, eg. when pasting)
+func ParseKeys(input []byte) []Key {
+	var keys []Key
+	for len(input) > 0 {
+		switch {
+		case input[0] == 0x1b:
+			if len(input) == 1 { // a lone escape, sequences arrive in a single read
+				return append(keys, Key{Code: KeyCancel})
+			}
+			if len(input) >= 3 && (input[1] == '[' || input[1] == 'O') {
+				switch input[2] {
+				case 'A':
+					keys = append(keys, Key{Code: KeyUp})
+				case 'B':
+					keys = append(keys, Key{Code: KeyDown})
+				default:
+					keys = append(keys, Key{Code: KeyUnknown})
+				}
+				// skip the rest of the sequence (eg. the ~ of page up), it ends at the first letter or ~
+				end := 2
+				for end < len(input) && !(input[end] >= 0x40 && input[end] <= 0x7e) {
+					end++
+				}
+				input = input[min(end+1, len(input)):]
+				continue
+			}
+			keys = append(keys, Key{Code: KeyUnknown}) // alt+key
+			input = input[2:]
+			continue
+		case input[0] == '\r' || input[0] == '\n':
+			keys = append(keys, Key{Code: KeyEnter})
+		case input[0] == 0x03 || input[0] == 0x07 || input[0] == 0x04: // ctrl-c, ctrl-g, ctrl-d
+			keys = append(keys, Key{Code: KeyCancel})
+		case input[0] == 0x7f || input[0] == 0x08:
+			keys = append(keys, Key{Code: KeyBackspace})
+		case input[0] == 0x15:
+			keys = append(keys, Key{Code: KeyClear})
+		case input[0] == 0x12:
+			keys = append(keys, Key{Code: KeyMode})
+		case input[0] == 0x10: // ctrl-p
+			keys = append(keys, Key{Code: KeyUp})
+		case input[0] == 0x0e: // ctrl-n
+			keys = append(keys, Key{Code: KeyDown})
+		case input[0] < 0x20:
+			keys = append(keys, Key{Code: KeyUnknown})
+		default:
+			r, size := utf8.DecodeRune(input)
+			keys = append(keys, Key{Code: KeyRune, Rune: r})
+			input = input[size:]
+			continue
+		}
+		input = input[1:]
+	}
+	return keys
+}
+
+type Action int
+
+const (
+	ActionNone    Action = iota
+	ActionRequery        // the query or mode changed
+	ActionAccept
+	ActionCancel
+)
+
+// Picker is the picker's state, Update applies a key and View draws it
+type Picker struct {
+	Query    string
+	Mode     Mode
+	Modes    []Mode
+	Results  []*domain.LogEntry // newest first, one per command
+	Selected int
+	Err      error // shown instead of failing so a slow remote doesn't lose what was typed
+	Now      time.Time
+}
+
+func NewPicker(query string, scope Scope) *Picker {
+	return &Picker{Query: query, Mode: ModeAll, Modes: scope.Modes(), Now: time.Now()}
+}
+
+// SetResults keeps the entries matching every word of the query, dropping older runs of the same command
+func (p *Picker) SetResults(entries []*domain.LogEntry) {
+	seen := map[string]bool{}
+	p.Results = p.Results[:0]
+	for _, entry := range entries {
+		if seen[entry.Command] || strings.TrimSpace(entry.Command) == "" || !Matches(entry.Command, p.Query) {
+			continue
+		}
+		seen[entry.Command] = true
+		p.Results = append(p.Results, entry)
+	}
+	p.Selected = max(0, min(p.Selected, len(p.Results)-1))
+}
+
+func (p *Picker) Update(key Key) Action {
+	switch key.Code {
+	case KeyRune:
+		p.Query += string(key.Rune)
+	case KeyBackspace:
+		if p.Query == "" {
+			return ActionNone
+		}
+		_, size := utf8.DecodeLastRuneInString(p.Query)
+		p.Query = p.Query[:len(p.Query)-size]
+	case KeyClear:
+		p.Query = ""
+	case KeyMode:
+		p.Mode = p.Modes[(slices.Index(p.Modes, p.Mode)+1)%len(p.Modes)]
+	case KeyUp: // older
+		p.Selected = min(p.Selected+1, max(len(p.Results)-1, 0))
+		return ActionNone
+	case KeyDown:
+		p.Selected = max(p.Selected-1, 0)
+		return ActionNone
+	case KeyEnter:
+		if len(p.Results) == 0 {
+			return ActionNone
+		}
+		return ActionAccept
+	case KeyCancel:
+		return ActionCancel
+	default:
+		return ActionNone
+	}
+	p.Selected = 0
+	return ActionRequery
+}
+
+// Chosen is the selected command
+func (p *Picker) Chosen() string {
+	if p.Selected >= len(p.Results) {
+		return ""
+	}
+	return p.Results[p.Selected].Command
+}
+
+const (
+	reset   = "\x1b[0m"
+	dim     = "\x1b[2m"
+	reverse = "\x1b[7m"
+	red     = "\x1b[31m"
+	green   = "\x1b[32m"
+	yellow  = "\x1b[33m"
+)
+
+// View draws the picker fzf style: the prompt on the bottom line with the newest result right above it
+func (p *Picker) View(width, height int) string {
+	var view strings.Builder
+	view.WriteString("\x1b[H\x1b[2J") // home and clear
+
+	rows := max(height-2, 0)
+	first := max(0, p.Selected-rows+1) // scroll so the selection stays on screen
+	for row := rows - 1; row >= 0; row-- {
+		index := first + row
+		if index >= len(p.Results) {
+			view.WriteString("\r\n")
+			continue
+		}
+		view.WriteString(p.resultLine(p.Results[index], index == p.Selected, width))
+		view.WriteString("\r\n")
+	}
+
+	status := fmt.Sprintf("  %d results  %s (ctrl-r to change)", len(p.Results), p.Mode)
+	if p.Err != nil {
+		status = fmt.Sprintf("  %s", p.Err)
+	}
+	view.WriteString(dim + truncate(status, width) + reset + "\r\n")
+	view.WriteString("> " + p.Query)
+	return view.String()
+}
+
+func (p *Picker) resultLine(entry *domain.LogEntry, selected bool, width int) string {
+	exit, color := fmt.Sprint(entry.ExitCode), green
+	switch {
+	case entry.Status == domain.StatusRunning:
+		exit, color = "…", yellow
+	case entry.ExitCode != 0:
+		color = red
+	}
+	prefix := "  "
+	if selected {
+		prefix = "> "
+	}
+	command := strings.ReplaceAll(entry.Command, "\n", " ")
+	plain := fmt.Sprintf("%s%4s %3s  ", prefix, RelativeTime(p.Now, entry.Timestamp), exit)
+	command = truncate(command, width-utf8.RuneCountInString(plain))
+
+	line := fmt.Sprintf("%s%s%4s%s %s%3s%s  %s", prefix, dim, RelativeTime(p.Now, entry.Timestamp), reset, color, exit, reset, command)
+	if selected {
+		return reverse + strings.ReplaceAll(line, reset, reset+reverse) + reset
+	}
+	return line
+}
+
+// RelativeTime is how long ago ts was, eg. 42s, 5m, 3h, 2d, 4mo or 1y
+func RelativeTime(now time.Time, ts int64) string {
+	ago := now.Sub(time.Unix(ts, 0))
+	switch {
+	case ago < time.Minute:
+		return fmt.Sprintf("%ds", max(int(ago.Seconds()), 0))
+	case ago < time.Hour:
+		return fmt.Sprintf("%dm", int(ago.Minutes()))
+	case ago < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(ago.Hours()))
+	case ago < 30*24*time.Hour:
+		return fmt.Sprintf("%dd", int(ago.Hours()/24))
+	case ago < 365*24*time.Hour:
+		return fmt.Sprintf("%dmo", int(ago.Hours()/24/30))
+	}
+	return fmt.Sprintf("%dy", int(ago.Hours()/24/365))
+}
+
+func truncate(text string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:width-1]) + "…"
+}
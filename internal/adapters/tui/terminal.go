@@ -0,0 +1,175 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/WillRabalais04/terminalLog/internal/core/domain"
+)
+
+const (
+	resultLimit = 500
+	maxPages    = 20
+)
+
+// Lister is where the picker reads history from (LogService or any repo)
+type Lister interface {
+	List(ctx context.Context, filter *domain.LogFilter) ([]*domain.LogEntry, error)
+}
+
+// Run shows the picker on /dev/tty (stdout is left for the shell widget to read the pick from) starting from query.
+// It returns the chosen command, ok is false when the user cancelled.
+func Run(ctx context.Context, lister Lister, scope Scope, query string) (command string, ok bool, err error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", false, fmt.Errorf("no terminal to show the picker on: %w", err)
+	}
+	defer tty.Close()
+
+	saved, err := stty(tty, "-g")
+	if err != nil {
+		return "", false, fmt.Errorf("could not read the terminal settings: %w", err)
+	}
+	if _, err := stty(tty, "raw", "-echo"); err != nil {
+		return "", false, fmt.Errorf("could not switch the terminal to raw mode: %w", err)
+	}
+	fmt.Fprint(tty, "\x1b[?1049h") // alternate screen so the scrollback is left as it was
+	defer func() {
+		fmt.Fprint(tty, "\x1b[?1049l")
+		stty(tty, strings.TrimSpace(saved))
+	}()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	keys, readErr := make(chan []Key), make(chan error, 1)
+	go func() { // reads never hold up drawing results, and results never hold up typing
+		buf := make([]byte, 256)
+		for {
+			n, err := tty.Read(buf)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case keys <- ParseKeys(buf[:n]):
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	type results struct {
+		generation int
+		entries    []*domain.LogEntry
+		err        error
+	}
+	found := make(chan results)
+	picker := NewPicker(query, scope)
+	generation, cancelQuery := 0, context.CancelFunc(func() {})
+	defer func() { cancelQuery() }()
+	refresh := func() { // a query still running for what was typed before is cancelled and its results dropped
+		cancelQuery()
+		generation++
+		var queryCtx context.Context
+		queryCtx, cancelQuery = context.WithTimeout(ctx, 5*time.Second)
+		go func(generation int, filter *domain.LogFilter, query string) {
+			entries, err := Search(queryCtx, lister, filter, query)
+			select {
+			case found <- results{generation: generation, entries: entries, err: err}:
+			case <-done:
+			}
+		}(generation, Filter(picker.Query, picker.Mode, scope, resultLimit), picker.Query)
+	}
+	refresh()
+
+	for {
+		width, height := size(tty)
+		fmt.Fprint(tty, picker.View(width, height))
+
+		select {
+		case err := <-readErr:
+			return "", false, err
+		case result := <-found:
+			if result.generation != generation {
+				continue
+			}
+			picker.Err = result.err
+			picker.Now = time.Now()
+			picker.SetResults(result.entries)
+		case read := <-keys:
+			requery := false
+			for _, key := range read {
+				switch picker.Update(key) {
+				case ActionAccept:
+					return picker.Chosen(), true, nil
+				case ActionCancel:
+					return "", false, nil
+				case ActionRequery:
+					requery = true
+				}
+			}
+			if requery { // once per read so a paste doesn't run a query per character
+				refresh()
+			}
+		}
+	}
+}
+
+// Search pages through the history filter matches until resultLimit distinct commands contain every word of the query
+// (or it runs out, maxPages at most), since repeated runs of a command and words only matched in memory can leave a
+// single page well short
+func Search(ctx context.Context, lister Lister, filter *domain.LogFilter, query string) ([]*domain.LogEntry, error) {
+	var entries []*domain.LogEntry
+	distinct := map[string]bool{}
+	for page := range uint64(maxPages) {
+		filter.Offset = page * filter.Limit
+		found, err := lister.List(ctx, filter)
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, found...)
+		for _, entry := range found {
+			if Matches(entry.Command, query) {
+				distinct[entry.Command] = true
+			}
+		}
+		if len(distinct) >= resultLimit || filter.Limit == 0 || uint64(len(found)) < filter.Limit {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// stty runs stty on the terminal, there's no x/term in the module and stty is everywhere the hooks run
+func stty(tty *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// size is the terminal's width and height, asked for on every draw so resizing just works
+func size(tty *os.File) (width, height int) {
+	width, height = 80, 24
+	out, err := stty(tty, "size")
+	if err != nil {
+		return width, height
+	}
+	fields := strings.Fields(out)
+	if len(fields) != 2 {
+		return width, height
+	}
+	if rows, err := strconv.Atoi(fields[0]); err == nil && rows > 0 {
+		height = rows
+	}
+	if cols, err := strconv.Atoi(fields[1]); err == nil && cols > 0 {
+		width = cols
+	}
+	return width, height
+}
@@ -0,0 +1,153 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// fields every entry needs (identity, ordering and per-user sync)
+var RequiredFields = []string{"event_id", "command", "ts", "user_name", "logged_successfully"}
+
+// fields that can be turned off, named after their columns
+var OptionalFields = []string{
+	"exit_code", "shell_pid", "shell_uptime", "cwd", "prev_cwd", "euid", "term", "hostname",
+	"ssh_client", "tty", "git_repo", "git_repo_root", "git_branch", "git_commit", "git_status", "pipestatus",
+	"context", "kube_context", "kube_namespace", "aws_profile", "aws_region", "gcloud_config", "terraform_workspace",
+	"labels",
+}
+
+// ForensicFields are the heavier extras only the forensic profile captures
+var ForensicFields = []string{
+	"context", // probes the toolchains on PATH
+}
+
+const DefaultProfile = "standard"
+
+var Profiles = map[string][]string{
+	"minimal":  {"exit_code", "cwd"},
+	"standard": slices.DeleteFunc(slices.Clone(OptionalFields), func(field string) bool { return slices.Contains(ForensicFields, field) }),
+	"forensic": OptionalFields,
+}
+
+type FieldSet map[string]struct{}
+
+// ResolveFields builds the captured field set from a profile, an explicit field list (which replaces the profile's) and
+// fields to disable on top of either.
+func ResolveFields(profile string, fields, disabled []string) (FieldSet, error) {
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	selected, ok := Profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("unknown capture profile %q (should be minimal, standard or forensic)", profile)
+	}
+	if len(fields) > 0 {
+		selected = fields
+	}
+
+	set := make(FieldSet, len(RequiredFields)+len(selected))
+	for _, field := range RequiredFields {
+		set[field] = struct{}{}
+	}
+	for _, field := range selected {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !slices.Contains(OptionalFields, field) && !slices.Contains(RequiredFields, field) {
+			return nil, fmt.Errorf("unknown capture field %q", field)
+		}
+		set[field] = struct{}{}
+	}
+	for _, field := range disabled {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if slices.Contains(RequiredFields, field) {
+			return nil, fmt.Errorf("%s is always captured and can't be disabled", field)
+		}
+		if !slices.Contains(OptionalFields, field) { // a typo would otherwise quietly leave the field captured
+			return nil, fmt.Errorf("unknown capture field %q", field)
+		}
+		delete(set, field)
+	}
+	if !set.Enabled("exit_code") { // each stage's code gives the exit status away
+		delete(set, "pipestatus")
+	}
+	return set, nil
+}
+
+func (s FieldSet) Enabled(field string) bool {
+	if s == nil {
+		return true
+	}
+	_, ok := s[field]
+	return ok
+}
+
+// Apply clears the entry's disabled fields and records them so they are stored as NULL rather than zero values
+func (s FieldSet) Apply(entry *LogEntry) {
+	entry.DisabledFields = nil
+	for _, field := range OptionalFields {
+		if s.Enabled(field) {
+			continue
+		}
+		entry.DisabledFields = append(entry.DisabledFields, field)
+		switch field {
+		case "exit_code":
+			entry.ExitCode, entry.TerminationSignal = 0, ""
+		case "shell_pid":
+			entry.Shell_PID = 0
+		case "shell_uptime":
+			entry.ShellUptime = 0
+		case "cwd":
+			entry.WorkingDirectory = ""
+		case "prev_cwd":
+			entry.PrevWorkingDirectory = ""
+		case "euid":
+			entry.EUID = 0
+		case "term":
+			entry.Term = ""
+		case "hostname":
+			entry.Hostname = ""
+		case "ssh_client":
+			entry.SSHClient = ""
+		case "tty":
+			entry.TTY = ""
+		case "git_repo":
+			entry.GitRepo = false
+		case "git_repo_root":
+			entry.GitRepoRoot = ""
+		case "git_branch":
+			entry.GitBranch = ""
+		case "git_commit":
+			entry.GitCommit = ""
+		case "git_status":
+			entry.GitStatus = ""
+		case "pipestatus":
+			entry.PipeStatus = nil
+		case "context":
+			entry.Context = nil
+		case "kube_context":
+			entry.KubeContext = ""
+		case "kube_namespace":
+			entry.KubeNamespace = ""
+		case "aws_profile":
+			entry.AWSProfile = ""
+		case "aws_region":
+			entry.AWSRegion = ""
+		case "gcloud_config":
+			entry.GCloudConfig = ""
+		case "terraform_workspace":
+			entry.TerraformWorkspace = ""
+		case "labels":
+			entry.Labels = nil
+		}
+	}
+}
+
+func (e *LogEntry) IsDisabled(field string) bool {
+	return slices.Contains(e.DisabledFields, field)
+}
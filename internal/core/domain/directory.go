@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"cmp"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// DirectoryRank is a directory commands were run in, ranked by frecency (how often and how recently it was used)
+type DirectoryRank struct {
+	Path     string
+	Visits   int64 // commands run in it
+	LastUsed int64
+	Score    float64
+}
+
+// DirectoryFilter picks the history directories are ranked from
+type DirectoryFilter struct {
+	User      string
+	Host      string   // empty for every host, entries logged without a hostname count for every host
+	Fragments []string // see MatchDirectory
+	Since     int64
+	Limit     uint64
+}
+
+// Frecency weighs visits by how long ago the directory was last used, the same buckets z uses
+func Frecency(visits, lastUsed, now int64) float64 {
+	age := now - lastUsed
+	switch {
+	case age < 60*60:
+		return float64(visits) * 4
+	case age < 24*60*60:
+		return float64(visits) * 2
+	case age < 7*24*60*60:
+		return float64(visits) / 2
+	}
+	return float64(visits) / 4
+}
+
+// MatchDirectory reports whether the fragments appear in the path in order (ignoring case) with the last one in its
+// final component, so 'j foo bar' matches /foo/x/bar but not /bar/x/foo or /foo/bar/baz
+func MatchDirectory(path string, fragments []string) bool {
+	if len(fragments) == 0 {
+		return true
+	}
+	path = strings.ToLower(path)
+	rest := path
+	for _, fragment := range fragments {
+		fragment = strings.ToLower(fragment)
+		index := strings.Index(rest, fragment)
+		if index < 0 {
+			return false
+		}
+		rest = rest[index+len(fragment):]
+	}
+	return strings.Contains(filepath.Base(path), strings.ToLower(fragments[len(fragments)-1]))
+}
+
+// RankDirectories scores directories at now, keeping those matching the filter's fragments, best first
+func RankDirectories(directories []*DirectoryRank, filter *DirectoryFilter, now int64) []*DirectoryRank {
+	ranked := make([]*DirectoryRank, 0, len(directories))
+	for _, directory := range directories {
+		if filter != nil && !MatchDirectory(directory.Path, filter.Fragments) {
+			continue
+		}
+		directory.Score = Frecency(directory.Visits, directory.LastUsed, now)
+		ranked = append(ranked, directory)
+	}
+	slices.SortFunc(ranked, func(a, b *DirectoryRank) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(b.LastUsed, a.LastUsed), strings.Compare(a.Path, b.Path))
+	})
+	if filter != nil && filter.Limit > 0 && uint64(len(ranked)) > filter.Limit {
+		ranked = ranked[:filter.Limit]
+	}
+	return ranked
+}
@@ -0,0 +1,33 @@
+package domain
+
+// signals with the same number on linux and macOS (shells report death by signal n as exit code 128+n)
+var signalNames = map[int32]string{
+	1:  "SIGHUP",
+	2:  "SIGINT",
+	3:  "SIGQUIT",
+	4:  "SIGILL",
+	5:  "SIGTRAP",
+	6:  "SIGABRT",
+	8:  "SIGFPE",
+	9:  "SIGKILL",
+	11: "SIGSEGV",
+	13: "SIGPIPE",
+	14: "SIGALRM",
+	15: "SIGTERM",
+}
+
+// TerminationSignal decodes an exit code like 130 (ctrl-c), 137 (kill -9) or 143 (kill) into the signal that ended
+// the command, returning "" for normal exits
+func TerminationSignal(exitCode int32) string {
+	if exitCode <= 128 {
+		return ""
+	}
+	return signalNames[exitCode-128]
+}
+
+// DecodeExit fills in the entry's termination signal from its exit code if it wasn't already set
+func (e *LogEntry) DecodeExit() {
+	if e.TerminationSignal == "" && !e.IsDisabled("exit_code") {
+		e.TerminationSignal = TerminationSignal(e.ExitCode)
+	}
+}
@@ -0,0 +1,143 @@
+package domain
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreRules decides which commands are never logged
+type IgnoreRules struct {
+	commands    []*regexp.Regexp
+	dirs        []*regexp.Regexp
+	IgnoreSpace bool // HISTCONTROL=ignorespace, commands typed with a leading space
+}
+
+// ParseIgnoreRules reads rules, one "<glob|regex|dir> <pattern>" per line (blank lines and # comments are skipped).
+// Globs match the whole command like HISTIGNORE and dir rules cover the directory and everything below it.
+func ParseIgnoreRules(text, homeDir string) (*IgnoreRules, error) {
+	rules := &IgnoreRules{}
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for line := 1; scanner.Scan(); line++ {
+		rule := strings.TrimSpace(scanner.Text())
+		if rule == "" || strings.HasPrefix(rule, "#") {
+			continue
+		}
+		kind, pattern, found := strings.Cut(rule, " ")
+		pattern = strings.TrimSpace(pattern)
+		if !found || pattern == "" {
+			return nil, fmt.Errorf("ignore rule on line %d should be \"<glob|regex|dir> <pattern>\"", line)
+		}
+
+		switch kind {
+		case "glob":
+			if err := rules.AddGlob(pattern); err != nil {
+				return nil, fmt.Errorf("invalid ignore rule on line %d: %w", line, err)
+			}
+		case "regex":
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ignore rule on line %d: %w", line, err)
+			}
+			rules.commands = append(rules.commands, re)
+		case "dir":
+			if pattern == "~" || strings.HasPrefix(pattern, "~/") {
+				pattern = filepath.Join(homeDir, pattern[1:])
+			}
+			re, err := regexp.Compile("^" + globToRegex(strings.TrimSuffix(pattern, "/")) + "(/.*)?$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid ignore rule on line %d: %w", line, err)
+			}
+			rules.dirs = append(rules.dirs, re)
+		default:
+			return nil, fmt.Errorf("unknown ignore rule %q on line %d", kind, line)
+		}
+	}
+	return rules, scanner.Err()
+}
+
+// AddGlob ignores commands matching a HISTIGNORE style pattern
+func (r *IgnoreRules) AddGlob(pattern string) error {
+	re, err := regexp.Compile("^" + globToRegex(pattern) + "$")
+	if err != nil {
+		return fmt.Errorf("invalid glob %q: %w", pattern, err)
+	}
+	r.commands = append(r.commands, re)
+	return nil
+}
+
+// AddHistIgnore adds the colon separated patterns of a HISTIGNORE value (\: escapes a literal colon). Invalid patterns
+// are skipped and returned as the error, the rest are still added.
+func (r *IgnoreRules) AddHistIgnore(histIgnore string) error {
+	var errs []error
+	var pattern strings.Builder
+	for i := 0; i < len(histIgnore); i++ {
+		switch {
+		case histIgnore[i] == '\\' && i+1 < len(histIgnore) && histIgnore[i+1] == ':':
+			pattern.WriteByte(':')
+			i++
+		case histIgnore[i] == ':':
+			if pattern.Len() > 0 {
+				errs = append(errs, r.AddGlob(pattern.String()))
+			}
+			pattern.Reset()
+		default:
+			pattern.WriteByte(histIgnore[i])
+		}
+	}
+	if pattern.Len() > 0 {
+		errs = append(errs, r.AddGlob(pattern.String()))
+	}
+	return errors.Join(errs...)
+}
+
+func (r *IgnoreRules) Ignores(command, cwd string) bool {
+	if r.IgnoreSpace && strings.HasPrefix(command, " ") {
+		return true
+	}
+	for _, re := range r.commands {
+		if re.MatchString(strings.TrimSpace(command)) {
+			return true
+		}
+	}
+	for _, re := range r.dirs {
+		if re.MatchString(cwd) {
+			return true
+		}
+	}
+	return false
+}
+
+func globToRegex(glob string) string {
+	var re strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			re.WriteString(".*")
+		case '?':
+			re.WriteString(".")
+		case '[':
+			if end := strings.IndexByte(glob[i+1:], ']'); end > 0 {
+				class := glob[i+1 : i+1+end]
+				if strings.HasPrefix(class, "!") {
+					class = "^" + class[1:]
+				}
+				re.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+				i += end + 1
+				continue
+			}
+			re.WriteString(`\[`)
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				re.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return re.String()
+}
@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	MaxLabels          = 32
+	MaxLabelValueBytes = 256
+)
+
+// label keys are used as-is in labels.<key> filters so they're limited to plain names
+var labelKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+func ValidLabelKey(key string) bool {
+	return labelKeyPattern.MatchString(key)
+}
+
+// ValidateLabels rejects labels that couldn't be filtered on or would bloat every entry
+func ValidateLabels(labels map[string]string) error {
+	if len(labels) > MaxLabels {
+		return fmt.Errorf("too many labels (%d, at most %d)", len(labels), MaxLabels)
+	}
+	for key, value := range labels {
+		if !ValidLabelKey(key) {
+			return fmt.Errorf("invalid label key %q (letters, digits, _ and - only)", key)
+		}
+		if len(value) > MaxLabelValueBytes {
+			return fmt.Errorf("label %s is longer than %d bytes", key, MaxLabelValueBytes)
+		}
+	}
+	return nil
+}
+
+// ParseLabels reads comma separated key=value pairs (eg. TERMLOGGER_LABELS=team=payments,ticket=OPS-42)
+func ParseLabels(list string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, pair := range strings.Split(list, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, fmt.Errorf("label %q should be key=value", pair)
+		}
+		labels[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return labels, ValidateLabels(labels)
+}
+
+// CollectorConfig is one line of the user's collectors file: <kind> <name> <timeout> [args...]
+type CollectorConfig struct {
+	Kind    string // builtin or exec
+	Name    string // the builtin to run, or any name for an exec collector
+	Timeout time.Duration
+	Args    []string // a builtin's arguments, or the executable and its arguments
+}
+
+func ParseCollectorConfig(text string) ([]CollectorConfig, error) {
+	var configs []CollectorConfig
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("collector on line %d should be \"<builtin|exec> <name> <timeout> [args...]\"", line)
+		}
+		timeout, err := time.ParseDuration(fields[2])
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q for collector on line %d", fields[2], line)
+		}
+		config := CollectorConfig{Kind: fields[0], Name: fields[1], Timeout: timeout, Args: fields[3:]}
+		switch config.Kind {
+		case "builtin":
+		case "exec":
+			if len(config.Args) == 0 {
+				return nil, fmt.Errorf("exec collector %s on line %d needs a command", config.Name, line)
+			}
+		default:
+			return nil, fmt.Errorf("unknown collector kind %q on line %d (should be builtin or exec)", config.Kind, line)
+		}
+		configs = append(configs, config)
+	}
+	return configs, scanner.Err()
+}
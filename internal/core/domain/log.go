@@ -1,11 +1,13 @@
 package domain
 
 import (
+	"context"
 	"time"
 )
 
 type LogEntry struct {
 	EventID              string
+	SessionID            string
 	Command              string
 	ExitCode             int32
 	Timestamp            int64
@@ -25,6 +27,47 @@ type LogEntry struct {
 	GitCommit            string
 	GitStatus            string
 	LoggedSuccessfully   bool
+	DisabledFields       []string       // optional fields that weren't captured (stored as NULL)
+	Redacted             bool           // secrets were replaced with <redacted:type> placeholders
+	Output               *CommandOutput // only set when captured (termlogger run) or explicitly requested
+	Status               string         // running until the command finishes (empty is treated as completed)
+	EndTimestamp         int64          // when the command finished, 0 if unknown or still running
+	PipeStatus           []int32        // exit code of every stage of a pipeline
+	TerminationSignal    string         // eg. SIGINT if the command was killed by a signal (see TerminationSignal)
+	Usage                *ResourceUsage // only set for commands run with termlogger exec
+	Context              *RuntimeContext
+	KubeContext          string // from KUBECONFIG / ~/.kube/config
+	KubeNamespace        string
+	AWSProfile           string
+	AWSRegion            string
+	GCloudConfig         string            // gcloud's active named configuration
+	TerraformWorkspace   string            // only set in terraform working directories (or with TF_WORKSPACE)
+	Labels               map[string]string // free form metadata from the user's collectors, filtered on as labels.<key>
+}
+
+const (
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusAbandoned = "abandoned" // its session ended before the command was completed
+)
+
+type Tombstone struct { // marks a deleted entry so it isn't resurrected by another copy
+	EventID   string
+	DeletedAt int64
+	Actor     string
+	User      string
+}
+
+type actorKey struct{}
+
+// WithActor attaches who is deleting entries to ctx so repos can record it on tombstones
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
 }
 
 type FilterValues struct {
@@ -52,6 +95,7 @@ type LogFilter struct {
 	OrderBy     *string
 	StartTime   *int64
 	EndTime     *int64
+	Prefix      string // commands starting with it, matched literally
 }
 
 type FilterBuilder struct {
@@ -108,6 +152,11 @@ func (b *FilterBuilder) SetOrderBy(orderBy string) *FilterBuilder {
 	return b
 }
 
+func (b *FilterBuilder) SetCommandPrefix(prefix string) *FilterBuilder {
+	b.filter.Prefix = prefix
+	return b
+}
+
 func (b *FilterBuilder) SetTimeRange(start, end time.Time) *FilterBuilder {
 	startTime := start.Unix()
 	endTime := end.Unix()
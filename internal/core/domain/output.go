@@ -0,0 +1,26 @@
+package domain
+
+import "unicode/utf8"
+
+const DefaultMaxOutputBytes = 64 << 10
+
+// CommandOutput is the tail of a command's combined stdout/stderr, stored separately from its entry
+type CommandOutput struct {
+	EventID    string
+	Output     string
+	Truncated  bool  // only the last part of the output was kept
+	TotalBytes int64 // size of the full output
+}
+
+// Cap keeps at most the last max bytes of the output, starting on a rune so the rest stays valid utf-8
+func (o *CommandOutput) Cap(max int) {
+	if max <= 0 || len(o.Output) <= max {
+		return
+	}
+	start := len(o.Output) - max
+	for start < len(o.Output) && !utf8.RuneStart(o.Output[start]) {
+		start++
+	}
+	o.Output = o.Output[start:]
+	o.Truncated = true
+}
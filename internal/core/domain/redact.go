@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RedactionRule replaces its pattern's "secret" group (or the whole match if it has none) with <redacted:Type>
+type RedactionRule struct {
+	Type    string
+	Pattern *regexp.Regexp
+}
+
+const quotedOrWord = `"[^"]*"|'[^']*'|\S+`
+
+var builtinRedactionRules = []RedactionRule{
+	{"aws-access-key", regexp.MustCompile(`\b(?P<secret>(?:AKIA|ASIA)[0-9A-Z]{16})\b`)},
+	{"github-token", regexp.MustCompile(`\b(?P<secret>(?:gh[pousr]|github_pat)_[A-Za-z0-9_]{20,})`)},
+	{"slack-token", regexp.MustCompile(`\b(?P<secret>xox[abposr]-[A-Za-z0-9-]{10,})`)},
+	{"stripe-key", regexp.MustCompile(`\b(?P<secret>[sr]k_(?:live|test)_[A-Za-z0-9]{16,})`)},
+	{"jwt", regexp.MustCompile(`\b(?P<secret>eyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,})`)},
+	{"private-key", regexp.MustCompile(`(?P<secret>-----BEGIN [A-Z ]*PRIVATE KEY-----)`)},
+	{"password", regexp.MustCompile(`(?i)(?:^|\s)--?(?:password|passwd|pwd)(?:=|\s+)(?P<secret>` + quotedOrWord + `)`)},
+	{"env-secret", regexp.MustCompile(`(?i)\b[A-Z0-9_]*(?:SECRET|TOKEN|PASSWORD|PASSWD|API_?KEY|ACCESS_KEY|PRIVATE_KEY|CREDENTIALS?)[A-Z0-9_]*=(?P<secret>` + quotedOrWord + `)`)},
+	{"auth-header", regexp.MustCompile(`(?i)authorization:\s*(?:(?:bearer|basic|token)\s+)?(?P<secret>[^\s"']+)`)},
+	{"bearer-token", regexp.MustCompile(`(?i)\bbearer\s+(?P<secret>[A-Za-z0-9\-._~+/]{16,}=*)`)},
+	{"url-credentials", regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s:/@]+:(?P<secret>[^\s@/]+)@`)},
+	{"query-secret", regexp.MustCompile(`(?i)[?&](?:api_?key|access_token|token|secret|password)=(?P<secret>[^&\s"']+)`)},
+	{"password", regexp.MustCompile(`\b(?:mysql|mysqldump|mysqladmin|mariadb)\b.*?\s-p(?P<secret>[^\s-]\S*)`)},
+	{"password", regexp.MustCompile(`\bsshpass\s+-p\s*(?P<secret>\S+)`)},
+}
+
+type Redactor struct {
+	rules []RedactionRule
+}
+
+// NewRedactor uses the built-in detectors followed by any user-defined rules
+func NewRedactor(extra ...RedactionRule) *Redactor {
+	return &Redactor{rules: append(append([]RedactionRule{}, builtinRedactionRules...), extra...)}
+}
+
+// ParseRedactionRules reads user rules, one "<type> <regex>" per line (blank lines and # comments are skipped)
+func ParseRedactionRules(text string) ([]RedactionRule, error) {
+	var rules []RedactionRule
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for line := 1; scanner.Scan(); line++ {
+		rule := strings.TrimSpace(scanner.Text())
+		if rule == "" || strings.HasPrefix(rule, "#") {
+			continue
+		}
+		ruleType, pattern, found := strings.Cut(rule, " ")
+		if !found {
+			return nil, fmt.Errorf("redaction rule on line %d should be \"<type> <regex>\"", line)
+		}
+		re, err := regexp.Compile(strings.TrimSpace(pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction rule on line %d: %w", line, err)
+		}
+		rules = append(rules, RedactionRule{Type: ruleType, Pattern: re})
+	}
+	return rules, scanner.Err()
+}
+
+// Redact replaces secrets in the entry's command and captured output, flagging the entry if anything was found
+func (r *Redactor) Redact(entry *LogEntry) {
+	if r == nil {
+		return
+	}
+	if command, redacted := r.RedactString(entry.Command); redacted {
+		entry.Command = command
+		entry.Redacted = true
+	}
+	if entry.Output != nil {
+		if output, redacted := r.RedactString(entry.Output.Output); redacted {
+			entry.Output.Output = output
+			entry.Redacted = true
+		}
+	}
+	for key, value := range entry.Labels { // external collectors can echo anything back
+		if value, redacted := r.RedactString(value); redacted {
+			entry.Labels[key] = value
+			entry.Redacted = true
+		}
+	}
+}
+
+func (r *Redactor) RedactString(s string) (string, bool) {
+	redacted := false
+	for _, rule := range r.rules {
+		var found bool
+		s, found = rule.apply(s)
+		redacted = redacted || found
+	}
+	return s, redacted
+}
+
+func (rule RedactionRule) apply(s string) (string, bool) {
+	matches := rule.Pattern.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s, false
+	}
+	group := rule.Pattern.SubexpIndex("secret")
+	placeholder := "<redacted:" + rule.Type + ">"
+
+	var out strings.Builder
+	last, found := 0, false
+	for _, match := range matches {
+		start, end := match[0], match[1]
+		if group > 0 {
+			start, end = match[2*group], match[2*group+1]
+		}
+		if start < 0 || strings.HasPrefix(s[start:end], "<redacted:") { // already redacted (eg. by the client before the server)
+			continue
+		}
+		out.WriteString(s[last:start])
+		out.WriteString(placeholder)
+		last, found = end, true
+	}
+	out.WriteString(s[last:])
+	return out.String(), found
+}
@@ -0,0 +1,13 @@
+package domain
+
+// RuntimeContext is what the command ran inside of, stored as JSON in the context column so its keys can be filtered
+// on (eg. context.container=docker or context.toolchains.go=1.24.1)
+type RuntimeContext struct {
+	Container  string            `json:"container,omitempty"`  // docker, podman or kubernetes
+	Pod        string            `json:"pod,omitempty"`        // the pod's name when running in kubernetes
+	Venv       string            `json:"venv,omitempty"`       // active python virtualenv
+	Conda      string            `json:"conda,omitempty"`      // active conda env
+	Nix        string            `json:"nix,omitempty"`        // pure or impure inside a nix-shell / nix develop
+	Direnv     string            `json:"direnv,omitempty"`     // directory of the loaded .envrc
+	Toolchains map[string]string `json:"toolchains,omitempty"` // versions of go, node and python resolved on PATH
+}
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Session is one running shell, registered by the hook when the shell starts and ended when it exits
+type Session struct {
+	ID        string
+	Host      string
+	User      string
+	TTY       string
+	Shell     string
+	StartedAt int64
+	LastSeen  int64 // bumped by logged commands and idle prompts (heartbeat), at most every HeartbeatInterval when idle
+	EndedAt   int64 // 0 while the shell is still running
+}
+
+// HeartbeatInterval is the most often (in seconds) the hooks heartbeat a session from an idle prompt
+const HeartbeatInterval = 60
+
+func (s *Session) Active() bool {
+	return s.EndedAt == 0
+}
+
+// Reapable reports whether the session should be ended for its shell: it's still open, it's gone unseen for longer
+// than an idle prompt's heartbeats allow, and alive says its shell is gone. Going unseen alone doesn't make it
+// reapable, an idle shell or a long running command stays quiet for as long as it likes.
+func (s *Session) Reapable(now int64, alive func(pid int) bool) bool {
+	if !s.Active() || now-s.LastSeen <= 2*HeartbeatInterval {
+		return false
+	}
+	pid := s.ShellPID()
+	return pid != 0 && !alive(pid)
+}
+
+// ShellPID is the pid of the session's shell, which the hooks put in its id (<host>-<pid>-<started at>-<random>), or 0
+// for ids made some other way
+func (s *Session) ShellPID() int {
+	parts := strings.Split(s.ID, "-") // from the end since hostnames can have dashes
+	if len(parts) < 4 {
+		return 0
+	}
+	pid, err := strconv.Atoi(parts[len(parts)-3])
+	if err != nil || pid <= 0 {
+		return 0
+	}
+	return pid
+}
+
+type SessionFilter struct {
+	ActiveOnly bool
+	SeenSince  int64 // only sessions with a heartbeat at or after this time
+	User       string
+	Host       string
+}
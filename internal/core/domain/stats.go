@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// HistoryStats summarises a set of entries (termlogger stats)
+type HistoryStats struct {
+	Total          int
+	Failed         int // completed with a non zero exit code
+	Running        int
+	Sessions       int
+	First          int64 // oldest and newest timestamps
+	Last           int64
+	TopPrograms    []Count // by the program that was run, eg. git for every git command
+	TopCommands    []Count
+	TopDirectories []Count
+}
+
+type Count struct {
+	Value string
+	Count int
+}
+
+// ComputeStats counts entries keeping the top most frequent programs, commands and directories
+func ComputeStats(entries []*LogEntry, top int) *HistoryStats {
+	stats := &HistoryStats{Total: len(entries)}
+	programs, commands, dirs, sessions := map[string]int{}, map[string]int{}, map[string]int{}, map[string]bool{}
+
+	for _, entry := range entries {
+		switch {
+		case entry.Status == StatusRunning:
+			stats.Running++
+		case entry.ExitCode != 0:
+			stats.Failed++
+		}
+		if stats.First == 0 || entry.Timestamp < stats.First {
+			stats.First = entry.Timestamp
+		}
+		stats.Last = max(stats.Last, entry.Timestamp)
+		if entry.SessionID != "" {
+			sessions[entry.SessionID] = true
+		}
+		if program := ProgramName(entry.Command); program != "" {
+			programs[program]++
+		}
+		if command := strings.TrimSpace(entry.Command); command != "" {
+			commands[command]++
+		}
+		if entry.WorkingDirectory != "" {
+			dirs[entry.WorkingDirectory]++
+		}
+	}
+
+	stats.Sessions = len(sessions)
+	stats.TopPrograms = topCounts(programs, top)
+	stats.TopCommands = topCounts(commands, top)
+	stats.TopDirectories = topCounts(dirs, top)
+	return stats
+}
+
+// ProgramName is the program a command line runs, skipping env assignments and sudo (eg. git for 'sudo FOO=1 git push')
+func ProgramName(command string) string {
+	for _, word := range strings.Fields(command) {
+		if word == "sudo" || word == "env" || (strings.Contains(word, "=") && !strings.HasPrefix(word, "=")) {
+			continue
+		}
+		return word
+	}
+	return ""
+}
+
+// topCounts sorts by count (ties alphabetically so the output is stable)
+func topCounts(counts map[string]int, top int) []Count {
+	sorted := make([]Count, 0, len(counts))
+	for _, value := range slices.Sorted(maps.Keys(counts)) {
+		sorted = append(sorted, Count{Value: value, Count: counts[value]})
+	}
+	slices.SortStableFunc(sorted, func(a, b Count) int { return cmp.Compare(b.Count, a.Count) })
+	if top > 0 && len(sorted) > top {
+		sorted = sorted[:top]
+	}
+	return sorted
+}
@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// SuggestContext is the prompt a suggestion is for
+type SuggestContext struct {
+	Prefix   string // what's been typed so far
+	Cwd      string
+	RepoRoot string // empty outside a git repo
+	Previous string // the last command run in the same shell
+}
+
+// how much each past run of a command counts towards suggesting it again
+const (
+	suggestSameCwd   = 4.0
+	suggestSameRepo  = 2.0
+	suggestAfterPrev = 3.0
+	suggestFailed    = 0.25 // failed runs are mostly typos, they still count a little
+	suggestHalfLife  = 30 * 24 * 60 * 60
+)
+
+// Suggest ranks the commands starting with the prefix by how well their past runs fit the prompt: run in the same
+// directory (or at least the same repo), right after the same command and exiting 0, with recent runs counting more.
+// recent is the user's latest history, complete so a run's predecessor in its session is known, matches are older runs
+// starting with the prefix (their predecessors aren't known so they're only ranked on the rest).
+func Suggest(recent, matches []*LogEntry, sc SuggestContext, limit int) []string {
+	previous := predecessors(recent)
+	newest := int64(0)
+	for _, entry := range slices.Concat(recent, matches) {
+		newest = max(newest, entry.Timestamp)
+	}
+
+	scores, last, seen := map[string]float64{}, map[string]int64{}, map[string]bool{}
+	for _, entry := range slices.Concat(recent, matches) {
+		if seen[entry.EventID] || entry.Status == StatusRunning || !strings.HasPrefix(entry.Command, sc.Prefix) || entry.Command == sc.Prefix {
+			continue
+		}
+		if entry.EventID != "" { // runs in both recent and matches only count once
+			seen[entry.EventID] = true
+		}
+
+		score := 1.0
+		switch {
+		case sc.Cwd != "" && entry.WorkingDirectory == sc.Cwd:
+			score += suggestSameCwd
+		case sc.RepoRoot != "" && entry.GitRepoRoot == sc.RepoRoot:
+			score += suggestSameRepo
+		}
+		if prev, ok := previous[entry.EventID]; ok && sc.Previous != "" && strings.TrimSpace(prev) == strings.TrimSpace(sc.Previous) {
+			score += suggestAfterPrev
+		}
+		if entry.ExitCode != 0 || entry.TerminationSignal != "" {
+			score *= suggestFailed
+		}
+		score /= 1 + float64(newest-entry.Timestamp)/suggestHalfLife
+
+		scores[entry.Command] += score
+		last[entry.Command] = max(last[entry.Command], entry.Timestamp)
+	}
+
+	commands := make([]string, 0, len(scores))
+	for command := range scores {
+		commands = append(commands, command)
+	}
+	slices.SortFunc(commands, func(a, b string) int {
+		return cmp.Or(cmp.Compare(scores[b], scores[a]), cmp.Compare(last[b], last[a]), strings.Compare(a, b))
+	})
+	if limit > 0 && len(commands) > limit {
+		commands = commands[:limit]
+	}
+	return commands
+}
+
+// predecessors maps each entry to the command run before it in its session, the first entry of each session is left out
+// since what came before it may not be in entries
+func predecessors(entries []*LogEntry) map[string]string {
+	sessions := map[string][]*LogEntry{}
+	for _, entry := range entries {
+		if entry.SessionID != "" {
+			sessions[entry.SessionID] = append(sessions[entry.SessionID], entry)
+		}
+	}
+	previous := map[string]string{}
+	for _, session := range sessions {
+		slices.SortStableFunc(session, func(a, b *LogEntry) int { return cmp.Compare(a.Timestamp, b.Timestamp) })
+		for i := 1; i < len(session); i++ {
+			previous[session[i].EventID] = session[i-1].Command
+		}
+	}
+	return previous
+}
@@ -0,0 +1,12 @@
+package domain
+
+// ResourceUsage is what the kernel reported (wait4's rusage) for a command run through termlogger exec
+type ResourceUsage struct {
+	UserTimeMicros         int64
+	SysTimeMicros          int64
+	MaxRSSKB               int64
+	VoluntaryCtxSwitches   int64
+	InvoluntaryCtxSwitches int64
+	BlockInputOps          int64
+	BlockOutputOps         int64
+}
@@ -0,0 +1,14 @@
+package ports
+
+import (
+	"context"
+	"time"
+)
+
+// MetadataCollector adds labels to entries as they're logged. Collectors that go over their timeout are dropped for that
+// entry rather than holding up the log.
+type MetadataCollector interface {
+	Name() string
+	Timeout() time.Duration
+	Collect(ctx context.Context, cwd string) map[string]string
+}
@@ -8,8 +8,42 @@ import (
 
 type LogRepositoryPort interface {
 	Log(ctx context.Context, entry []*domain.LogEntry) error
+	// Complete finishes in-flight entries (exit code, end time and status), inserting any whose start never made it
+	Complete(ctx context.Context, entries []*domain.LogEntry) error
 	Get(ctx context.Context, id string) (*domain.LogEntry, error)
 	List(ctx context.Context, filters *domain.LogFilter) ([]*domain.LogEntry, error)
 	Delete(ctx context.Context, id string) (*domain.LogEntry, error) // probably should refactor into just one delete
 	DeleteMultiple(ctx context.Context, filters *domain.LogFilter) ([]*domain.LogEntry, error)
 }
+
+type LogSyncPort interface { // repos that can serve incremental pulls to other devices
+	Pull(ctx context.Context, user string, since int64, limit uint64) ([]*domain.LogEntry, int64, error)
+	PushTombstones(ctx context.Context, tombstones []*domain.Tombstone) error
+	PullTombstones(ctx context.Context, user string, since int64, limit uint64) ([]*domain.Tombstone, int64, error)
+}
+
+type LogMirrorPort interface { // local repos that store entries pulled from a remote
+	Upsert(ctx context.Context, entries []*domain.LogEntry) error
+	Evict(ctx context.Context, ids []string) error // removes flushed entries without tombstoning them
+	UpsertTombstones(ctx context.Context, tombstones []*domain.Tombstone) error
+	PendingTombstones(ctx context.Context) ([]*domain.Tombstone, error)
+	MarkTombstonesSynced(ctx context.Context, ids []string) error
+	PendingSessions(ctx context.Context) ([]*domain.Session, error)
+	MarkSessionsSynced(ctx context.Context, sessions []*domain.Session) error
+	GetWatermark(ctx context.Context, name string) (int64, error)
+	SetWatermark(ctx context.Context, name string, watermark int64) error
+}
+
+type LogOutputPort interface { // repos that store captured command output alongside entries
+	GetOutput(ctx context.Context, eventID string) (*domain.CommandOutput, error) // nil if nothing was captured
+}
+
+type LogSessionPort interface { // repos that track running shells
+	// UpsertSessions registers, heartbeats and ends sessions (last_seen only moves forward and empty fields are left as is)
+	UpsertSessions(ctx context.Context, sessions []*domain.Session) error
+	ListSessions(ctx context.Context, filter *domain.SessionFilter) ([]*domain.Session, error)
+}
+
+type LogDirectoryPort interface { // repos that can rank the directories commands were run in
+	RankDirectories(ctx context.Context, filter *domain.DirectoryFilter) ([]*domain.DirectoryRank, error)
+}
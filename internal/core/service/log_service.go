@@ -2,23 +2,74 @@ package service
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/WillRabalais04/terminalLog/internal/core/domain"
 	"github.com/WillRabalais04/terminalLog/internal/core/ports"
 )
 
 type LogService struct {
-	repo ports.LogRepositoryPort
+	repo           ports.LogRepositoryPort
+	redactor       *domain.Redactor
+	maxOutputBytes int
 }
 
 func NewLogService(repo ports.LogRepositoryPort) *LogService {
+	return NewLogServiceWithRedactor(repo, domain.NewRedactor())
+}
+
+// NewLogServiceWithRedactor scrubs secrets from entries with the given redactor before they reach the repo
+func NewLogServiceWithRedactor(repo ports.LogRepositoryPort, redactor *domain.Redactor) *LogService {
 	return &LogService{
-		repo: repo,
+		repo:           repo,
+		redactor:       redactor,
+		maxOutputBytes: domain.DefaultMaxOutputBytes,
+	}
+}
+
+// SetMaxOutputBytes sets how much of a command's output is kept (the end of it), 0 or less keeps the default
+func (s *LogService) SetMaxOutputBytes(max int) *LogService {
+	if max <= 0 {
+		max = domain.DefaultMaxOutputBytes
 	}
+	s.maxOutputBytes = max
+	return s
 }
+
 func (s *LogService) Log(ctx context.Context, entries []*domain.LogEntry) error {
+	for _, entry := range entries {
+		if err := domain.ValidateLabels(entry.Labels); err != nil {
+			return err
+		}
+		entry.DecodeExit()
+		s.redactor.Redact(entry) // before capping so a secret isn't cut into a piece the detectors miss
+		if entry.Output != nil {
+			entry.Output.Cap(s.maxOutputBytes)
+		}
+	}
 	return s.repo.Log(ctx, entries)
 }
+
+// Complete finishes entries logged when their command started
+func (s *LogService) Complete(ctx context.Context, entries []*domain.LogEntry) error {
+	for _, entry := range entries {
+		if entry.EventID == "" {
+			return fmt.Errorf("completions require an event id")
+		}
+		if err := domain.ValidateLabels(entry.Labels); err != nil {
+			return err
+		}
+		if entry.Status == "" {
+			entry.Status = domain.StatusCompleted
+		}
+		entry.DecodeExit()
+		s.redactor.Redact(entry) // the whole entry is inserted if its start never arrived
+		if entry.Output != nil {
+			entry.Output.Cap(s.maxOutputBytes)
+		}
+	}
+	return s.repo.Complete(ctx, entries)
+}
 func (s *LogService) Get(ctx context.Context, id string) (*domain.LogEntry, error) {
 	return s.repo.Get(ctx, id)
 }
@@ -31,3 +82,97 @@ func (s *LogService) Delete(ctx context.Context, id string) (*domain.LogEntry, e
 func (s *LogService) DeleteMultiple(ctx context.Context, filters *domain.LogFilter) ([]*domain.LogEntry, error) {
 	return s.repo.DeleteMultiple(ctx, filters)
 }
+
+func (s *LogService) Pull(ctx context.Context, user string, since int64, limit uint64) ([]*domain.LogEntry, int64, error) {
+	if user == "" {
+		return nil, since, fmt.Errorf("pull requires a user")
+	}
+	syncer, err := s.syncer()
+	if err != nil {
+		return nil, since, err
+	}
+	return syncer.Pull(ctx, user, since, limit)
+}
+
+func (s *LogService) PushTombstones(ctx context.Context, tombstones []*domain.Tombstone) error {
+	syncer, err := s.syncer()
+	if err != nil {
+		return err
+	}
+	return syncer.PushTombstones(ctx, tombstones)
+}
+
+func (s *LogService) PullTombstones(ctx context.Context, user string, since int64, limit uint64) ([]*domain.Tombstone, int64, error) {
+	if user == "" {
+		return nil, since, fmt.Errorf("pull requires a user")
+	}
+	syncer, err := s.syncer()
+	if err != nil {
+		return nil, since, err
+	}
+	return syncer.PullTombstones(ctx, user, since, limit)
+}
+
+func (s *LogService) GetOutput(ctx context.Context, eventID string) (*domain.CommandOutput, error) {
+	outputs, ok := s.repo.(ports.LogOutputPort)
+	if !ok {
+		return nil, fmt.Errorf("repository does not store command output")
+	}
+	return outputs.GetOutput(ctx, eventID)
+}
+
+func (s *LogService) UpsertSessions(ctx context.Context, sessions []*domain.Session) error {
+	for _, session := range sessions {
+		if session.ID == "" {
+			return fmt.Errorf("sessions require an id")
+		}
+	}
+	sessionRepo, err := s.sessions()
+	if err != nil {
+		return err
+	}
+	return sessionRepo.UpsertSessions(ctx, sessions)
+}
+func (s *LogService) ListSessions(ctx context.Context, filter *domain.SessionFilter) ([]*domain.Session, error) {
+	sessionRepo, err := s.sessions()
+	if err != nil {
+		return nil, err
+	}
+	return sessionRepo.ListSessions(ctx, filter)
+}
+
+// SessionTimeline returns every command run in a session, oldest first
+func (s *LogService) SessionTimeline(ctx context.Context, sessionID string) ([]*domain.LogEntry, error) {
+	if sessionID == "" {
+		return nil, fmt.Errorf("timeline requires a session id")
+	}
+	return s.repo.List(ctx, domain.NewFilterBuilder().
+		AddFilterTerm("session_id", sessionID).
+		SetOrderBy("-ts").
+		Build())
+}
+
+// RankDirectories lists the directories commands were run in by frecency, best first (termlogger jump)
+func (s *LogService) RankDirectories(ctx context.Context, filter *domain.DirectoryFilter) ([]*domain.DirectoryRank, error) {
+	directories, ok := s.repo.(ports.LogDirectoryPort)
+	if !ok {
+		return nil, fmt.Errorf("repository does not rank directories")
+	}
+	return directories.RankDirectories(ctx, filter)
+}
+
+func (s *LogService) sessions() (ports.LogSessionPort, error) {
+	sessionRepo, ok := s.repo.(ports.LogSessionPort)
+	if !ok {
+		return nil, fmt.Errorf("repository does not track sessions")
+	}
+	return sessionRepo, nil
+}
+
+func (s *LogService) syncer() (ports.LogSyncPort, error) {
+	syncer, ok := s.repo.(ports.LogSyncPort)
+	if !ok {
+		return nil, fmt.Errorf("repository does not support syncing")
+	}
+	return syncer, nil
+}
@@ -35,6 +35,7 @@ func LogEntryToString(entry *domain.LogEntry) string {
 	s += fmt.Sprintf("GitCommit:		%s\n", entry.GitCommit)
 	s += fmt.Sprintf("GitStatus:		%s\n", entry.GitStatus)
 	s += fmt.Sprintf("LoggedSuccessfully:		%t\n", entry.LoggedSuccessfully)
+	s += fmt.Sprintf("Redacted:		%t\n", entry.Redacted)
 	s += "}\n"
 
 	return s
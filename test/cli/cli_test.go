@@ -0,0 +1,111 @@
+package cli_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/WillRabalais04/terminalLog/cmd/utils"
+	"github.com/WillRabalais04/terminalLog/internal/core/domain"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"90m", now.Add(-90 * time.Minute)},
+		{"7d", now.AddDate(0, 0, -7)},
+		{"2w", now.AddDate(0, 0, -14)},
+		{"2025-06-01", time.Date(2025, 6, 1, 0, 0, 0, 0, time.Local)},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := utils.ParseSince(test.value, now)
+			if err != nil {
+				t.Fatalf("ParseSince failed: %v", err)
+			}
+			if !got.Equal(test.want) {
+				t.Errorf("Expected %v, but got %v", test.want, got)
+			}
+		})
+	}
+
+	for _, invalid := range []string{"soon", "-1h", "xd"} {
+		if _, err := utils.ParseSince(invalid, now); err == nil {
+			t.Errorf("Expected an error for %q, but got none", invalid)
+		}
+	}
+}
+
+func TestParseTerms(t *testing.T) {
+	terms, err := utils.ParseTerms([]string{"exit_code=1", "labels.team=a=b", "exit_code=2"})
+	if err != nil {
+		t.Fatalf("ParseTerms failed: %v", err)
+	}
+	if got := terms["exit_code"]; len(got) != 2 || got[0] != "1" || got[1] != "2" {
+		t.Errorf("Expected exit_code to be [1 2], but got %v", got)
+	}
+	if got := terms["labels.team"]; len(got) != 1 || got[0] != "a=b" {
+		t.Errorf("Expected the value to keep its '=', but got %v", got)
+	}
+	if _, err := utils.ParseTerms([]string{"exit_code"}); err == nil {
+		t.Error("Expected an error for a term without a value, but got none")
+	}
+}
+
+func TestComputeStats(t *testing.T) {
+	entries := []*domain.LogEntry{
+		{Command: "git status", ExitCode: 0, Timestamp: 100, WorkingDirectory: "/repo", SessionID: "a"},
+		{Command: "sudo FOO=1 git push", ExitCode: 1, Timestamp: 300, WorkingDirectory: "/repo", SessionID: "a"},
+		{Command: "make test", Status: domain.StatusRunning, Timestamp: 200, WorkingDirectory: "/tmp", SessionID: "b"},
+	}
+	stats := domain.ComputeStats(entries, 1)
+
+	if stats.Total != 3 || stats.Failed != 1 || stats.Running != 1 || stats.Sessions != 2 {
+		t.Errorf("Expected 3 total, 1 failed, 1 running and 2 sessions, but got %+v", stats)
+	}
+	if stats.First != 100 || stats.Last != 300 {
+		t.Errorf("Expected the range 100-300, but got %d-%d", stats.First, stats.Last)
+	}
+	if len(stats.TopPrograms) != 1 || stats.TopPrograms[0] != (domain.Count{Value: "git", Count: 2}) {
+		t.Errorf("Expected git to be the top program, but got %v", stats.TopPrograms)
+	}
+	if len(stats.TopDirectories) != 1 || stats.TopDirectories[0].Value != "/repo" {
+		t.Errorf("Expected /repo to be the top directory, but got %v", stats.TopDirectories)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	recent := []*domain.LogEntry{
+		{EventID: "1", SessionID: "a", Command: "make build", Timestamp: 100, WorkingDirectory: "/api"},
+		{EventID: "2", SessionID: "a", Command: "git push", Timestamp: 110, WorkingDirectory: "/api", GitRepoRoot: "/api"},
+		{EventID: "3", SessionID: "b", Command: "git pull", Timestamp: 120, WorkingDirectory: "/web"},
+		{EventID: "4", SessionID: "b", Command: "git pull", Timestamp: 130, WorkingDirectory: "/web"},
+		{EventID: "5", SessionID: "b", Command: "git pusj", Timestamp: 140, WorkingDirectory: "/api", ExitCode: 127},
+	}
+	older := []*domain.LogEntry{
+		{EventID: "0", SessionID: "c", Command: "git prune", Timestamp: 50, WorkingDirectory: "/api"},
+		{EventID: "2", SessionID: "a", Command: "git push", Timestamp: 110, WorkingDirectory: "/api", GitRepoRoot: "/api"},
+	}
+
+	tests := []struct {
+		name string
+		sc   domain.SuggestContext
+		want []string
+	}{
+		{"same directory first", domain.SuggestContext{Prefix: "git p", Cwd: "/api"}, []string{"git push", "git prune", "git pull", "git pusj"}},
+		{"more runs elsewhere", domain.SuggestContext{Prefix: "git p", Cwd: "/web"}, []string{"git pull", "git push", "git prune", "git pusj"}},
+		{"after the same command", domain.SuggestContext{Prefix: "git", Cwd: "/tmp", Previous: "make build"}, []string{"git push", "git pull", "git prune", "git pusj"}},
+		{"nothing to add", domain.SuggestContext{Prefix: "git pull"}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := domain.Suggest(recent, older, test.sc, 0)
+			if !slices.Equal(got, test.want) {
+				t.Errorf("Expected %v, but got %v", test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package cloud_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/WillRabalais04/terminalLog/internal/adapters/cloud"
+)
+
+func write(t *testing.T, path, contents string) string {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("could not create %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("could not write %s: %v", path, err)
+	}
+	return path
+}
+
+const kubeConfig = `apiVersion: v1
+clusters:
+- cluster:
+    server: https://prod.example.com
+  name: prod
+contexts:
+- context:
+    cluster: prod
+    namespace: payments # where the incident was
+    user: admin
+  name: prod-eu
+- name: "staging"
+  context:
+    cluster: staging
+    user: admin
+current-context: prod-eu
+kind: Config
+`
+
+func TestCollect(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, key := range []string{"KUBECONFIG", "AWS_PROFILE", "AWS_DEFAULT_PROFILE", "AWS_REGION", "AWS_DEFAULT_REGION", "AWS_CONFIG_FILE",
+		"CLOUDSDK_ACTIVE_CONFIG_NAME", "CLOUDSDK_CONFIG", "TF_WORKSPACE", "TF_DATA_DIR"} {
+		t.Setenv(key, "")
+	}
+
+	t.Run("Nothing Configured", func(t *testing.T) {
+		if info := cloud.Collect(t.TempDir()); info != (cloud.Info{}) {
+			t.Errorf("Expected an empty info, but got %+v", info)
+		}
+	})
+
+	t.Run("Kube Context", func(t *testing.T) {
+		write(t, filepath.Join(home, ".kube", "config"), kubeConfig)
+		info := cloud.Collect("")
+		if info.KubeContext != "prod-eu" || info.KubeNamespace != "payments" {
+			t.Errorf("Expected prod-eu/payments, but got %s/%s", info.KubeContext, info.KubeNamespace)
+		}
+	})
+
+	t.Run("KUBECONFIG Merge", func(t *testing.T) {
+		dir := t.TempDir()
+		// the first file setting current-context wins, contexts can come from any of them
+		first := write(t, filepath.Join(dir, "current.json"), `{"current-context": "staging"}`)
+		second := write(t, filepath.Join(dir, "clusters.yaml"), kubeConfig)
+		t.Setenv("KUBECONFIG", first+string(filepath.ListSeparator)+second)
+		info := cloud.Collect("")
+		if info.KubeContext != "staging" || info.KubeNamespace != "default" {
+			t.Errorf("Expected staging/default, but got %s/%s", info.KubeContext, info.KubeNamespace)
+		}
+	})
+
+	t.Run("AWS Profile", func(t *testing.T) {
+		write(t, filepath.Join(home, ".aws", "config"), "[default]\nregion = us-east-1\n\n[profile prod]\nregion = eu-west-1\noutput = json\n")
+		t.Setenv("AWS_PROFILE", "prod")
+		if info := cloud.Collect(""); info.AWSProfile != "prod" || info.AWSRegion != "eu-west-1" {
+			t.Errorf("Expected prod/eu-west-1, but got %s/%s", info.AWSProfile, info.AWSRegion)
+		}
+		t.Setenv("AWS_REGION", "ap-south-1")
+		if info := cloud.Collect(""); info.AWSRegion != "ap-south-1" {
+			t.Errorf("Expected AWS_REGION to win, but got %s", info.AWSRegion)
+		}
+	})
+
+	t.Run("GCloud Config", func(t *testing.T) {
+		write(t, filepath.Join(home, ".config", "gcloud", "active_config"), "analytics\n")
+		if info := cloud.Collect(""); info.GCloudConfig != "analytics" {
+			t.Errorf("Expected analytics, but got '%s'", info.GCloudConfig)
+		}
+	})
+
+	t.Run("Terraform Workspace", func(t *testing.T) {
+		plain, initialised, selected := t.TempDir(), t.TempDir(), t.TempDir()
+		os.Mkdir(filepath.Join(initialised, ".terraform"), 0755)
+		write(t, filepath.Join(selected, ".terraform", "environment"), "prod")
+
+		tests := []struct {
+			cwd      string
+			expected string
+		}{{plain, ""}, {initialised, "default"}, {selected, "prod"}}
+		for _, tt := range tests {
+			if info := cloud.Collect(tt.cwd); info.TerraformWorkspace != tt.expected {
+				t.Errorf("Expected workspace '%s' in %s, but got '%s'", tt.expected, tt.cwd, info.TerraformWorkspace)
+			}
+		}
+	})
+}
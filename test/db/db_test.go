@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 
@@ -206,6 +207,21 @@ func runStandardTests(t *testing.T, svc *service.LogService) {
 				t.Error("Expected an error when getting a non-existent entry, but got nil")
 			}
 		})
+
+		t.Run("Get Pipestatus", func(t *testing.T) { // an INTEGER[] in postgres, text in sqlite
+			piped := &domain.LogEntry{Command: "make | tee out.log", ExitCode: 2, PipeStatus: []int32{2, 0}}
+			if err := svc.Log(ctx, []*domain.LogEntry{piped}); err != nil {
+				t.Fatalf("Failed to log entry: %v", err)
+			}
+
+			found, err := svc.Get(ctx, piped.EventID)
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			if !slices.Equal(found.PipeStatus, []int32{2, 0}) {
+				t.Errorf("Expected pipestatus [2 0], but got %v", found.PipeStatus)
+			}
+		})
 	})
 
 	t.Run("List with Filters", func(t *testing.T) {
@@ -0,0 +1,91 @@
+package git_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WillRabalais04/terminalLog/internal/adapters/git"
+)
+
+func run(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@t", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@t")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// TestCollectMatchesGit checks the .git reads agree with what the git cli reports
+func TestCollectMatchesGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	repo := t.TempDir()
+	run(t, repo, "init", "-q", "-b", "main")
+	run(t, repo, "commit", "-q", "--allow-empty", "-m", "first")
+	subdir := filepath.Join(repo, "a", "b")
+	os.MkdirAll(subdir, 0755)
+
+	check := func(t *testing.T, dir, wantRoot string) {
+		t.Helper()
+		info := git.Collect(dir, git.ModeLight, time.Second)
+		if !info.IsRepo {
+			t.Fatalf("Expected %s to be in a repo", dir)
+		}
+		if info.Root != wantRoot {
+			t.Errorf("Expected root %s, but got %s", wantRoot, info.Root)
+		}
+		if branch := run(t, dir, "rev-parse", "--abbrev-ref", "HEAD"); info.Branch != branch {
+			t.Errorf("Expected branch %s, but got %s", branch, info.Branch)
+		}
+		if commit := run(t, dir, "rev-parse", "--short=7", "HEAD"); info.Commit != commit {
+			t.Errorf("Expected commit %s, but got %s", commit, info.Commit)
+		}
+	}
+
+	t.Run("Subdirectory", func(t *testing.T) {
+		check(t, subdir, repo)
+	})
+
+	t.Run("PackedRefs", func(t *testing.T) {
+		run(t, repo, "pack-refs", "--all")
+		check(t, repo, repo)
+	})
+
+	t.Run("LinkedWorktree", func(t *testing.T) {
+		worktree := filepath.Join(t.TempDir(), "wt")
+		run(t, repo, "worktree", "add", "-q", "-b", "feature", worktree)
+		run(t, worktree, "commit", "-q", "--allow-empty", "-m", "second")
+		check(t, worktree, worktree)
+	})
+
+	t.Run("DetachedHead", func(t *testing.T) {
+		run(t, repo, "checkout", "-q", "--detach")
+		check(t, repo, repo)
+	})
+
+	t.Run("OutsideRepoAndOff", func(t *testing.T) {
+		if info := git.Collect(t.TempDir(), git.ModeFull, time.Second); info.IsRepo {
+			t.Errorf("Expected no repo, but got %+v", info)
+		}
+		if info := git.Collect(repo, git.ModeOff, time.Second); info.IsRepo {
+			t.Errorf("Expected nothing collected in off mode, but got %+v", info)
+		}
+	})
+
+	t.Run("StatusInFullMode", func(t *testing.T) {
+		os.WriteFile(filepath.Join(repo, "new.txt"), []byte("x"), 0644)
+		if info := git.Collect(repo, git.ModeFull, 5*time.Second); !strings.Contains(info.Status, "new.txt") {
+			t.Errorf("Expected status to list new.txt, but got %q", info.Status)
+		}
+	})
+}
@@ -14,14 +14,18 @@ import (
 	grpcClient "github.com/WillRabalais04/terminalLog/internal/adapters/grpc"
 	grpcServer "github.com/WillRabalais04/terminalLog/internal/adapters/grpc"
 	"github.com/WillRabalais04/terminalLog/internal/core/domain"
+	"github.com/WillRabalais04/terminalLog/internal/core/ports"
 	"github.com/WillRabalais04/terminalLog/internal/core/service"
 	"github.com/WillRabalais04/terminalLog/internal/testutils"
+	"github.com/google/uuid"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/test/bufconn"
 )
 
 var testSvc *service.LogService
+var testRemote ports.LogRepositoryPort
+var testClient pb.LogServiceClient
 
 func TestMain(m *testing.M) {
 	lis := bufconn.Listen(1024 * 1024)
@@ -57,6 +61,8 @@ func TestMain(m *testing.M) {
 	defer conn.Close()
 
 	clientAdapter := grpcClient.NewClientAdapter(conn)
+	testRemote = clientAdapter
+	testClient = pb.NewLogServiceClient(conn)
 	testSvc = service.NewLogService(clientAdapter)
 
 	exitCode := m.Run()
@@ -196,3 +202,422 @@ func TestClientServerInteraction(t *testing.T) {
 		}
 	})
 }
+
+func TestPullIntoCache(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := testSvc.DeleteMultiple(ctx, &domain.LogFilter{}); err != nil {
+		t.Fatalf("Failed to clean database before test: %v", err)
+	}
+
+	remoteEntries := []*domain.LogEntry{
+		{Command: "make build", User: "puller", Hostname: "laptop"},
+		{Command: "make test", User: "puller", Hostname: "desktop"},
+		{Command: "whoami", User: "someone_else", Hostname: "desktop"},
+	}
+	if err := testSvc.Log(ctx, remoteEntries); err != nil {
+		t.Fatalf("Failed to log remote entries: %v", err)
+	}
+
+	t.Run("Pull Scoped to User", func(t *testing.T) {
+		entries, watermark, err := testSvc.Pull(ctx, "puller", 0, 0)
+		if err != nil {
+			t.Fatalf("Pull request failed: %v", err)
+		}
+		if len(entries) != 2 {
+			t.Errorf("Expected 2 entries for user 'puller', but got %d", len(entries))
+		}
+
+		entries, _, err = testSvc.Pull(ctx, "puller", watermark, 0)
+		if err != nil {
+			t.Fatalf("Pull request from watermark failed: %v", err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("Expected 0 entries after watermark, but got %d", len(entries))
+		}
+	})
+
+	t.Run("MultiRepo Pull and Flush", func(t *testing.T) {
+		cache, err := database.GetLocalRepo(filepath.Join(t.TempDir(), "device_cache.db"))
+		if err != nil {
+			t.Fatalf("Failed to init device cache: %v", err)
+		}
+		multiRepo := database.NewMultiRepo(cache, testRemote)
+		multiRepo.SetSyncUser("puller")
+
+		pending := &domain.LogEntry{Command: "git push", User: "puller"}
+		if err := cache.Log(ctx, []*domain.LogEntry{pending}); err != nil {
+			t.Fatalf("Failed to log pending entry to cache: %v", err)
+		}
+
+		if err := multiRepo.Sync(ctx); err != nil {
+			t.Fatalf("Sync failed: %v", err)
+		}
+
+		cached, err := cache.List(ctx, &domain.LogFilter{})
+		if err != nil {
+			t.Fatalf("Failed to list cache after sync: %v", err)
+		}
+		if len(cached) != 3 {
+			t.Errorf("Expected 3 entries in cache after sync, but got %d", len(cached))
+		}
+
+		flushed, err := multiRepo.FlushCache(ctx)
+		if err != nil {
+			t.Fatalf("FlushCache after pull failed: %v", err)
+		}
+		if len(flushed) != 0 {
+			t.Errorf("Expected pulled entries not to be flushed, but %d were", len(flushed))
+		}
+
+		late := &domain.LogEntry{EventID: uuid.New().String(), Command: "make release", User: "puller"}
+		if err := testSvc.Log(ctx, []*domain.LogEntry{late}); err != nil {
+			t.Fatalf("Failed to log late entry: %v", err)
+		}
+		ahead := time.Now().Add(10 * time.Second).UnixNano() // as if a pull had already moved past it before it committed
+		if err := cache.SetWatermark(ctx, "pull:puller", ahead); err != nil {
+			t.Fatalf("Failed to move the watermark: %v", err)
+		}
+		if err := multiRepo.Sync(ctx); err != nil {
+			t.Fatalf("Sync failed: %v", err)
+		}
+		if _, err := cache.Get(ctx, late.EventID); err != nil {
+			t.Errorf("Expected an entry written just behind the watermark to be pulled: %v", err)
+		}
+		if watermark, _ := cache.GetWatermark(ctx, "pull:puller"); watermark != ahead {
+			t.Errorf("Expected the watermark to stay at %d, but got %d", ahead, watermark)
+		}
+	})
+}
+
+func TestTombstonePropagation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	newDevice := func(name string) (*database.LogRepo, *database.MultiRepo) {
+		cache, err := database.GetLocalRepo(filepath.Join(t.TempDir(), name+".db"))
+		if err != nil {
+			t.Fatalf("Failed to init %s cache: %v", name, err)
+		}
+		multiRepo := database.NewMultiRepo(cache, testRemote)
+		multiRepo.SetSyncUser("forgetter")
+		return cache, multiRepo
+	}
+	laptopCache, laptop := newDevice("laptop")
+	desktopCache, desktop := newDevice("desktop")
+
+	secret := &domain.LogEntry{Command: "mysql -pHunter2", User: "forgetter"}
+	if err := laptopCache.Log(ctx, []*domain.LogEntry{secret}); err != nil {
+		t.Fatalf("Failed to log entry to laptop cache: %v", err)
+	}
+	if err := laptop.Sync(ctx); err != nil {
+		t.Fatalf("Laptop sync failed: %v", err)
+	}
+	if err := desktop.Sync(ctx); err != nil {
+		t.Fatalf("Desktop sync failed: %v", err)
+	}
+	if _, err := desktopCache.Get(ctx, secret.EventID); err != nil {
+		t.Fatalf("Expected desktop to have pulled the entry: %v", err)
+	}
+
+	if _, err := laptopCache.Delete(ctx, secret.EventID); err != nil { // cache only delete, like MultiRepo's fallback
+		t.Fatalf("Failed to delete entry from laptop cache: %v", err)
+	}
+	if err := laptop.Sync(ctx); err != nil {
+		t.Fatalf("Laptop sync after delete failed: %v", err)
+	}
+	if _, err := testSvc.Get(ctx, secret.EventID); err == nil {
+		t.Error("Expected tombstone to delete the entry on remote, but it still exists.")
+	}
+
+	if err := desktop.Sync(ctx); err != nil {
+		t.Fatalf("Desktop sync after delete failed: %v", err)
+	}
+	if _, err := desktopCache.Get(ctx, secret.EventID); err == nil {
+		t.Error("Expected pulled tombstone to delete the desktop copy, but it still exists.")
+	}
+
+	if err := testSvc.Log(ctx, []*domain.LogEntry{secret}); err != nil {
+		t.Fatalf("Re-logging tombstoned entry failed: %v", err)
+	}
+	if _, err := testSvc.Get(ctx, secret.EventID); err == nil {
+		t.Error("Expected remote to refuse re-inserting a tombstoned entry, but it was resurrected.")
+	}
+
+	t.Run("Evicted Entries Are Forgotten Too", func(t *testing.T) { // what termlogger forget does for flushed commands
+		flushed := &domain.LogEntry{Command: "export AWS_SECRET_ACCESS_KEY=abc", User: "forgetter"}
+		if err := laptopCache.Log(ctx, []*domain.LogEntry{flushed}); err != nil {
+			t.Fatalf("Failed to log entry to laptop cache: %v", err)
+		}
+		if err := laptop.Sync(ctx); err != nil {
+			t.Fatalf("Laptop sync failed: %v", err)
+		}
+		if err := laptopCache.Evict(ctx, []string{flushed.EventID}); err != nil {
+			t.Fatalf("Evict failed: %v", err)
+		}
+
+		tombstone := &domain.Tombstone{EventID: flushed.EventID, DeletedAt: time.Now().Unix(), User: "forgetter"}
+		if err := laptopCache.PushTombstones(ctx, []*domain.Tombstone{tombstone}); err != nil {
+			t.Fatalf("PushTombstones failed: %v", err)
+		}
+		if err := laptop.Sync(ctx); err != nil {
+			t.Fatalf("Laptop sync after forgetting failed: %v", err)
+		}
+		if _, err := testSvc.Get(ctx, flushed.EventID); err == nil {
+			t.Error("Expected the tombstone to delete the entry on remote, but it still exists.")
+		}
+	})
+}
+
+func TestCommandOutput(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	entry := &domain.LogEntry{
+		EventID: uuid.New().String(),
+		Command: "make build",
+		User:    "output_user",
+		Output:  &domain.CommandOutput{Output: "main.go:3: undefined: foo\nexport API_TOKEN=abc123\n", TotalBytes: 48},
+	}
+	plain := &domain.LogEntry{EventID: uuid.New().String(), Command: "ls", User: "output_user"}
+	if err := testSvc.Log(ctx, []*domain.LogEntry{entry, plain}); err != nil {
+		t.Fatalf("Log request failed: %v", err)
+	}
+
+	t.Run("GetOutput", func(t *testing.T) {
+		resp, err := testClient.GetOutput(ctx, &pb.GetOutputRequest{EventId: entry.EventID})
+		if err != nil {
+			t.Fatalf("GetOutput failed: %v", err)
+		}
+		if resp.GetOutput() != "main.go:3: undefined: foo\nexport API_TOKEN=<redacted:env-secret>\n" {
+			t.Errorf("Expected redacted output, but got %q", resp.GetOutput())
+		}
+		if _, err := testClient.GetOutput(ctx, &pb.GetOutputRequest{EventId: plain.EventID}); err == nil {
+			t.Error("Expected an error for an entry without captured output.")
+		}
+	})
+
+	t.Run("Get Includes Output On Request", func(t *testing.T) {
+		without, err := testClient.Get(ctx, &pb.GetRequest{EventId: entry.EventID})
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if without.GetOutput() != nil {
+			t.Error("Expected output to be left out unless requested.")
+		}
+		with, err := testClient.Get(ctx, &pb.GetRequest{EventId: entry.EventID, IncludeOutput: true})
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if with.GetOutput().GetTotalBytes() != 48 || !with.GetRedacted() {
+			t.Errorf("Expected output (48 bytes) on a redacted entry, but got %v", with.GetOutput())
+		}
+	})
+
+	t.Run("Capped On A Rune", func(t *testing.T) {
+		long := &domain.LogEntry{
+			EventID: uuid.New().String(),
+			Command: "cat notes",
+			User:    "output_user",
+			Output:  &domain.CommandOutput{Output: "aé€b", TotalBytes: 7},
+		}
+		// the cut at 5 bytes lands inside é, sending half of it would fail to marshal
+		if err := service.NewLogService(testRemote).SetMaxOutputBytes(5).Log(ctx, []*domain.LogEntry{long}); err != nil {
+			t.Fatalf("Log request failed: %v", err)
+		}
+		resp, err := testClient.GetOutput(ctx, &pb.GetOutputRequest{EventId: long.EventID})
+		if err != nil {
+			t.Fatalf("GetOutput failed: %v", err)
+		}
+		if resp.GetOutput() != "€b" || !resp.GetTruncated() {
+			t.Errorf("Expected the output to be cut to '€b', but got %q (truncated: %v)", resp.GetOutput(), resp.GetTruncated())
+		}
+	})
+
+	t.Run("Delete Removes Output", func(t *testing.T) {
+		if _, err := testSvc.Delete(ctx, entry.EventID); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if output, err := testRemote.(ports.LogOutputPort).GetOutput(ctx, entry.EventID); err != nil || output != nil {
+			t.Errorf("Expected output to be deleted with its entry, but got %v (err: %v)", output, err)
+		}
+	})
+}
+
+func TestSessions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sessions := testRemote.(ports.LogSessionPort)
+	sessionID := "host-" + uuid.New().String()
+	if err := sessions.UpsertSessions(ctx, []*domain.Session{{ID: sessionID, Host: "fleet-host", User: "session_user", Shell: "zsh", StartedAt: 100, LastSeen: 100}}); err != nil {
+		t.Fatalf("UpsertSessions failed: %v", err)
+	}
+
+	entries := []*domain.LogEntry{
+		{EventID: uuid.New().String(), SessionID: sessionID, Command: "cd api", Timestamp: 110, User: "session_user"},
+		{EventID: uuid.New().String(), SessionID: sessionID, Command: "make test", Timestamp: 120, User: "session_user"},
+		{EventID: uuid.New().String(), SessionID: "other", Command: "ls", Timestamp: 115, User: "session_user"},
+	}
+	if err := testSvc.Log(ctx, entries); err != nil {
+		t.Fatalf("Log request failed: %v", err)
+	}
+
+	t.Run("Active Sessions", func(t *testing.T) {
+		active, err := sessions.ListSessions(ctx, &domain.SessionFilter{ActiveOnly: true, Host: "fleet-host"})
+		if err != nil {
+			t.Fatalf("ListSessions failed: %v", err)
+		}
+		if len(active) != 1 || active[0].Shell != "zsh" || active[0].LastSeen != 120 {
+			t.Errorf("Expected the session heartbeated by its last command (last seen 120), but got %v", active)
+		}
+	})
+
+	t.Run("Timeline", func(t *testing.T) {
+		timeline, err := testRemote.(interface {
+			SessionTimeline(ctx context.Context, sessionID string) ([]*domain.LogEntry, error)
+		}).SessionTimeline(ctx, sessionID)
+		if err != nil {
+			t.Fatalf("SessionTimeline failed: %v", err)
+		}
+		if len(timeline) != 2 || timeline[0].Command != "cd api" || timeline[1].Command != "make test" {
+			t.Errorf("Expected the session's 2 commands oldest first, but got %s", testutils.LogEntriesToString(timeline))
+		}
+	})
+
+	t.Run("Ended Sessions Are Not Active", func(t *testing.T) {
+		if err := sessions.UpsertSessions(ctx, []*domain.Session{{ID: sessionID, LastSeen: 130, EndedAt: 130}}); err != nil {
+			t.Fatalf("UpsertSessions failed: %v", err)
+		}
+		active, err := sessions.ListSessions(ctx, &domain.SessionFilter{ActiveOnly: true, Host: "fleet-host"})
+		if err != nil {
+			t.Fatalf("ListSessions failed: %v", err)
+		}
+		if len(active) != 0 {
+			t.Errorf("Expected no active sessions, but got %v", active)
+		}
+		all, err := sessions.ListSessions(ctx, &domain.SessionFilter{Host: "fleet-host"})
+		if err != nil || len(all) != 1 || all[0].Shell != "zsh" || all[0].EndedAt != 130 {
+			t.Errorf("Expected the ended session to keep its start details, but got %v (err: %v)", all, err)
+		}
+	})
+}
+
+func TestExitDetails(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	interrupted := &domain.LogEntry{EventID: uuid.New().String(), Command: "make test | tee out.log", ExitCode: 130, PipeStatus: []int32{130, 0}, User: "exit_user"}
+	failed := &domain.LogEntry{EventID: uuid.New().String(), Command: "grep foo missing.txt", ExitCode: 2, PipeStatus: []int32{2}, User: "exit_user"}
+	if err := testSvc.Log(ctx, []*domain.LogEntry{interrupted, failed}); err != nil {
+		t.Fatalf("Log request failed: %v", err)
+	}
+
+	t.Run("Signal Exits Are Decoded", func(t *testing.T) {
+		found, err := testSvc.List(ctx, domain.NewFilterBuilder().
+			AddFilterTerm("user_name", "exit_user").
+			AddFilterTerm("termination_signal", "SIGINT").
+			Build())
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if len(found) != 1 || found[0].EventID != interrupted.EventID {
+			t.Fatalf("Expected only the interrupted command, but got %s", testutils.LogEntriesToString(found))
+		}
+		if len(found[0].PipeStatus) != 2 || found[0].PipeStatus[0] != 130 || found[0].PipeStatus[1] != 0 {
+			t.Errorf("Expected pipestatus [130 0], but got %v", found[0].PipeStatus)
+		}
+	})
+
+	t.Run("Normal Exits Have No Signal", func(t *testing.T) {
+		stored, err := testSvc.Get(ctx, failed.EventID)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if stored.TerminationSignal != "" || stored.ExitCode != 2 {
+			t.Errorf("Expected exit 2 without a signal, but got exit %d signal %q", stored.ExitCode, stored.TerminationSignal)
+		}
+	})
+}
+
+func TestLabels(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	incident := &domain.LogEntry{EventID: uuid.New().String(), Command: "kubectl rollout undo deploy/api", User: "label_user",
+		Labels: map[string]string{"team": "payments", "ticket": "INC-1042"}}
+	routine := &domain.LogEntry{EventID: uuid.New().String(), Command: "kubectl get pods", User: "label_user",
+		Labels: map[string]string{"team": "payments"}}
+	if err := testSvc.Log(ctx, []*domain.LogEntry{incident, routine}); err != nil {
+		t.Fatalf("Log request failed: %v", err)
+	}
+
+	t.Run("Filter On A Label", func(t *testing.T) {
+		found, err := testSvc.List(ctx, domain.NewFilterBuilder().
+			AddFilterTerm("user_name", "label_user").
+			AddFilterTerm("labels.ticket", "INC-1042").
+			Build())
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if len(found) != 1 || found[0].EventID != incident.EventID {
+			t.Fatalf("Expected only the incident command, but got %s", testutils.LogEntriesToString(found))
+		}
+		if found[0].Labels["team"] != "payments" {
+			t.Errorf("Expected the labels to come back over grpc, but got %v", found[0].Labels)
+		}
+	})
+
+	t.Run("Search A Label", func(t *testing.T) {
+		found, err := testSvc.List(ctx, domain.NewFilterBuilder().AddSearchTerm("labels.ticket", "inc-").Build())
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if len(found) != 1 || found[0].EventID != incident.EventID {
+			t.Errorf("Expected only the incident command, but got %s", testutils.LogEntriesToString(found))
+		}
+	})
+
+	t.Run("Invalid Keys Are Rejected", func(t *testing.T) {
+		bad := &domain.LogEntry{EventID: uuid.New().String(), Command: "ls", User: "label_user", Labels: map[string]string{"team name": "x"}}
+		if err := testSvc.Log(ctx, []*domain.LogEntry{bad}); err == nil {
+			t.Errorf("Expected an error for the invalid label key")
+		}
+	})
+}
+
+func TestCommandPrefixOverGrpc(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var entries []*domain.LogEntry
+	for _, command := range []string{"echo 50%_off", "echo 50x off", "git commit -m 'echo 50%_off'"} {
+		entries = append(entries, &domain.LogEntry{EventID: uuid.New().String(), Command: command, User: "prefix_user"})
+	}
+	if err := testSvc.Log(ctx, entries); err != nil {
+		t.Fatalf("Log request failed: %v", err)
+	}
+	filter := func() *domain.LogFilter {
+		return domain.NewFilterBuilder().AddFilterTerm("user_name", "prefix_user").SetCommandPrefix("echo 50%_").Build()
+	}
+
+	found, err := testSvc.List(ctx, filter())
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(found) != 1 || found[0].EventID != entries[0].EventID {
+		t.Errorf("Expected only 'echo 50%%_off', but got %s", testutils.LogEntriesToString(found))
+	}
+
+	deleted, err := testSvc.DeleteMultiple(ctx, filter()) // a widened filter would delete more than was asked for
+	if err != nil {
+		t.Fatalf("DeleteMultiple failed: %v", err)
+	}
+	if len(deleted) != 1 {
+		t.Errorf("Expected 1 entry to be deleted, but got %d", len(deleted))
+	}
+	if left, _ := testSvc.List(ctx, domain.NewFilterBuilder().AddFilterTerm("user_name", "prefix_user").Build()); len(left) != 2 {
+		t.Errorf("Expected the other 2 entries to be kept, but got %s", testutils.LogEntriesToString(left))
+	}
+}
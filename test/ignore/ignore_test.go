@@ -0,0 +1,73 @@
+package ignore_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/WillRabalais04/terminalLog/cmd/utils"
+	"github.com/WillRabalais04/terminalLog/internal/core/domain"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	rules, err := domain.ParseIgnoreRules("# noisy\nglob ls*\nregex ^vault (read|write)\ndir ~/private\n", "/home/me")
+	if err != nil {
+		t.Fatalf("ParseIgnoreRules failed: %v", err)
+	}
+	rules.IgnoreSpace = true
+	if err := rules.AddHistIgnore(`exit:cd \:x`); err != nil {
+		t.Fatalf("AddHistIgnore failed: %v", err)
+	}
+
+	tests := []struct {
+		command, cwd string
+		want         bool
+	}{
+		{"ls -la", "/tmp", true},
+		{"git ls-files", "/tmp", false},
+		{"vault read secret/db", "/tmp", true},
+		{"make build", "/home/me/private/notes", true},
+		{"make build", "/home/me/privateer", false},
+		{" export TOKEN=x", "/tmp", true},
+		{"exit", "/tmp", true},
+		{"cd :x", "/tmp", true},
+		{"exit 1", "/tmp", false},
+	}
+	for _, test := range tests {
+		if got := rules.Ignores(test.command, test.cwd); got != test.want {
+			t.Errorf("Expected Ignores(%q, %q) to be %t, but got %t", test.command, test.cwd, test.want, got)
+		}
+	}
+
+	if _, err := domain.ParseIgnoreRules("prefix ls", "/home/me"); err == nil {
+		t.Error("Expected an unknown rule kind to fail.")
+	}
+	for _, invalid := range []string{"glob [!]", "glob ls [z-a]", "dir ~/[z-a]"} {
+		if _, err := domain.ParseIgnoreRules(invalid, "/home/me"); err == nil {
+			t.Errorf("Expected %q to fail instead of panicking.", invalid)
+		}
+	}
+
+	partial := &domain.IgnoreRules{}
+	if err := partial.AddHistIgnore("[z-a]:exit"); err == nil || !partial.Ignores("exit", "/tmp") {
+		t.Error("Expected the invalid HISTIGNORE pattern to be reported and the valid one kept.")
+	}
+}
+
+func TestTermlogIgnoreFile(t *testing.T) {
+	t.Setenv("IGNORE_RULES_FILE", filepath.Join(t.TempDir(), "missing"))
+	root := t.TempDir()
+	nested := filepath.Join(root, "client", "src")
+	os.MkdirAll(nested, 0755)
+
+	if utils.ShouldIgnore("make", nested, "", "") {
+		t.Error("Expected commands to be logged before .termlogignore exists.")
+	}
+	os.WriteFile(filepath.Join(root, "client", ".termlogignore"), nil, 0644)
+	if !utils.ShouldIgnore("make", nested, "", "") {
+		t.Error("Expected .termlogignore to disable logging for the tree below it.")
+	}
+	if utils.ShouldIgnore("make", root, "", "") {
+		t.Error("Expected directories above .termlogignore to still be logged.")
+	}
+}
@@ -0,0 +1,214 @@
+package importer_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WillRabalais04/terminalLog/internal/adapters/importer"
+	"github.com/WillRabalais04/terminalLog/internal/core/domain"
+
+	_ "modernc.org/sqlite"
+)
+
+var opts = importer.Options{User: "dev", Hostname: "laptop", ModTime: time.Unix(2000, 0)}
+
+type expected struct {
+	command  string
+	ts       int64
+	duration int64
+}
+
+func check(t *testing.T, entries []*domain.LogEntry, want []expected) {
+	t.Helper()
+	if len(entries) != len(want) {
+		t.Fatalf("Expected %d entries, but got %d", len(want), len(entries))
+	}
+	for i, w := range want {
+		entry := entries[i]
+		if entry.Command != w.command || entry.Timestamp != w.ts {
+			t.Errorf("Expected entry %d to be %q at %d, but got %q at %d", i, w.command, w.ts, entry.Command, entry.Timestamp)
+		}
+		if w.duration > 0 && entry.EndTimestamp != w.ts+w.duration {
+			t.Errorf("Expected %q to end at %d, but got %d", w.command, w.ts+w.duration, entry.EndTimestamp)
+		}
+		if entry.User != "dev" || entry.Hostname != "laptop" || entry.EventID == "" {
+			t.Errorf("Expected the user, host and an id to be set, but got %+v", entry)
+		}
+		if !entry.IsDisabled("exit_code") || !entry.IsDisabled("cwd") {
+			t.Errorf("Expected the fields history files don't have to be marked unknown, but got %v", entry.DisabledFields)
+		}
+	}
+}
+
+func TestParseBash(t *testing.T) {
+	t.Run("timestamps", func(t *testing.T) {
+		entries, err := importer.ParseBash(strings.NewReader("#100\nmake test\n#200\nfor i in 1 2; do\n  echo $i\ndone\n"), opts)
+		if err != nil {
+			t.Fatalf("ParseBash failed: %v", err)
+		}
+		check(t, entries, []expected{{"make test", 100, 0}, {"for i in 1 2; do\n  echo $i\ndone", 200, 0}})
+	})
+
+	t.Run("undated", func(t *testing.T) {
+		entries, err := importer.ParseBash(strings.NewReader("ls\ngit status\n\nls\n"), opts)
+		if err != nil {
+			t.Fatalf("ParseBash failed: %v", err)
+		}
+		// one entry per command at the position of its last run, counting back from the file's modification time
+		check(t, entries, []expected{{"git status", 1999, 0}, {"ls", 2000, 0}})
+		if entries[0].Labels["ts"] != "estimated" {
+			t.Errorf("Expected undated entries to be labelled, but got %v", entries[0].Labels)
+		}
+	})
+}
+
+func TestParseZsh(t *testing.T) {
+	history := ": 100:3;git push\n: 200:0;echo one\\\ntwo\n: 200:0;echo one\\\ntwo\nplain\n: 300:0;echo \xc4\x83\xa3\n"
+	entries, err := importer.ParseZsh(strings.NewReader(history), opts)
+	if err != nil {
+		t.Fatalf("ParseZsh failed: %v", err)
+	}
+	check(t, entries, []expected{
+		{"git push", 100, 3},
+		{"echo one\ntwo", 200, 0},
+		{"echo one\ntwo", 200, 0}, // run twice in the same second
+		{"echo ă", 300, 0},        // zsh writes the 0x83 in ă as 0x83 0xa3
+		{"plain", 2000, 0},
+	})
+	if entries[1].EventID == entries[2].EventID {
+		t.Error("Expected runs in the same second to get their own ids")
+	}
+}
+
+func TestParseFish(t *testing.T) {
+	history := "- cmd: echo a\\nb\\\\c\n  when: 100\n  paths:\n    - x\n- cmd: ls\n  when: 200\n"
+	entries, err := importer.ParseFish(strings.NewReader(history), opts)
+	if err != nil {
+		t.Fatalf("ParseFish failed: %v", err)
+	}
+	check(t, entries, []expected{{"echo a\nb\\c", 100, 0}, {"ls", 200, 0}})
+}
+
+func TestImportIsDeterministic(t *testing.T) {
+	history := "ls\n#100\nmake\n" // ls was run before HISTTIMEFORMAT was set
+	first, _ := importer.ParseBash(strings.NewReader(history), opts)
+	later := opts
+	later.ModTime = time.Unix(5000, 0) // written to since, undated times move but their ids mustn't
+	second, _ := importer.ParseBash(strings.NewReader("pwd\n"+history), later)
+	if len(first) != 2 || first[1].Labels["ts"] != "estimated" { // sorted by time, the estimate is the file's mtime
+		t.Fatalf("Expected an undated ls and a dated make, but got %d entries", len(first))
+	}
+
+	ids := map[string]bool{}
+	for _, entry := range second {
+		ids[entry.EventID] = true
+	}
+	for _, entry := range first {
+		if !ids[entry.EventID] {
+			t.Errorf("Expected %q to keep its id when imported again", entry.Command)
+		}
+	}
+
+	other := opts
+	other.Hostname = "server"
+	elsewhere, _ := importer.ParseBash(strings.NewReader(history), other)
+	if elsewhere[0].EventID == first[0].EventID {
+		t.Error("Expected the same history on another host to get different ids")
+	}
+}
+
+func fixture(t *testing.T, statements ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "history.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to create fixture: %v", err)
+	}
+	defer db.Close()
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("Failed to create fixture: %v", err)
+		}
+	}
+	return path
+}
+
+func TestReadAtuin(t *testing.T) {
+	path := fixture(t,
+		`CREATE TABLE history (id TEXT PRIMARY KEY, timestamp INTEGER NOT NULL, duration INTEGER NOT NULL,
+			exit INTEGER NOT NULL, command TEXT NOT NULL, cwd TEXT NOT NULL, session TEXT NOT NULL,
+			hostname TEXT NOT NULL, deleted_at INTEGER)`,
+		`INSERT INTO history VALUES
+			('0189a6b2c3d47e5f8a9b0c1d2e3f4a5b', 100000000000, 2500000000, 1, 'make test', '/src/app', 's1', 'box:alice', NULL),
+			('0189a6b2c3d47e5f8a9b0c1d2e3f4a5c', 200000000000, -1, -1, 'vim', 'unknown', 's1', 'box:alice', NULL),
+			('0189a6b2c3d47e5f8a9b0c1d2e3f4a5d', 300000000000, 0, 0, 'rm secrets', '/', 's1', 'box:alice', 300)`,
+	)
+	atuin, err := importer.GetFormat("atuin")
+	if err != nil {
+		t.Fatalf("GetFormat failed: %v", err)
+	}
+	entries, err := importer.ReadFile(atuin, path, opts)
+	if err != nil {
+		t.Fatalf("ReadAtuin failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected deleted history to be skipped, but got %d entries", len(entries))
+	}
+
+	first := entries[0]
+	if first.EventID != "0189a6b2-c3d4-7e5f-8a9b-0c1d2e3f4a5b" {
+		t.Errorf("Expected atuin's id to be kept, but got %s", first.EventID)
+	}
+	if first.Timestamp != 100 || first.EndTimestamp != 102 || first.ExitCode != 1 || first.WorkingDirectory != "/src/app" {
+		t.Errorf("Expected the time, duration, exit code and cwd to be read, but got %+v", first)
+	}
+	if first.SessionID != "s1" || first.Hostname != "box" || first.User != "dev" || first.Labels["atuin_host"] != "box:alice" {
+		t.Errorf("Expected the session and host to be read and the user to be the importer's, but got %+v", first)
+	}
+	if first.IsDisabled("exit_code") || first.IsDisabled("cwd") {
+		t.Errorf("Expected the fields atuin has to be captured, but got %v", first.DisabledFields)
+	}
+	if second := entries[1]; !second.IsDisabled("exit_code") || !second.IsDisabled("cwd") {
+		t.Errorf("Expected the fields atuin didn't know to be marked unknown, but got %v", second.DisabledFields)
+	}
+}
+
+func TestReadMcfly(t *testing.T) {
+	path := fixture(t,
+		`CREATE TABLE commands (id INTEGER PRIMARY KEY AUTOINCREMENT, cmd TEXT NOT NULL, cmd_tpl TEXT,
+			session_id TEXT NOT NULL, when_run INTEGER NOT NULL, exit_code INTEGER NOT NULL, selected INTEGER NOT NULL,
+			dir TEXT, old_dir TEXT)`,
+		`INSERT INTO commands (cmd, session_id, when_run, exit_code, selected, dir, old_dir) VALUES
+			('ls', 'abc', 200, 0, 0, '/tmp', '/home'),
+			('ls', 'abc', 100, 2, 0, '/', NULL)`,
+	)
+	format, err := importer.GetFormat("mcfly")
+	if err != nil {
+		t.Fatalf("GetFormat failed: %v", err)
+	}
+	entries, err := importer.ReadFile(format, path, opts)
+	if err != nil {
+		t.Fatalf("ReadMcfly failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Timestamp != 100 || entries[1].Timestamp != 200 {
+		t.Fatalf("Expected both runs oldest first, but got %v", entries)
+	}
+	last := entries[1]
+	if last.ExitCode != 0 || last.WorkingDirectory != "/tmp" || last.PrevWorkingDirectory != "/home" || last.SessionID != "abc" {
+		t.Errorf("Expected the exit code, directories and session to be read, but got %+v", last)
+	}
+	if last.User != "dev" || last.Hostname != "laptop" {
+		t.Errorf("Expected the user and host to default, but got %s@%s", last.User, last.Hostname)
+	}
+	if entries[0].ExitCode != 2 || !entries[0].IsDisabled("prev_cwd") {
+		t.Errorf("Expected the first run's exit code and no previous directory, but got %+v", entries[0])
+	}
+
+	again, _ := importer.ReadFile(format, path, opts)
+	if again[0].EventID != entries[0].EventID || entries[0].EventID == entries[1].EventID {
+		t.Error("Expected ids to be stable across imports and differ between runs")
+	}
+}
@@ -0,0 +1,418 @@
+package installer_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WillRabalais04/terminalLog/internal/adapters/installer"
+)
+
+func TestBlocks(t *testing.T) {
+	block := installer.StartMarker + "\necho hooked\n" + installer.EndMarker + "\n"
+
+	t.Run("upsert is idempotent", func(t *testing.T) {
+		once := installer.UpsertBlock("export A=1", block)
+		if want := "export A=1\n\n" + block; once != want {
+			t.Errorf("Expected %q, but got %q", want, once)
+		}
+		if twice := installer.UpsertBlock(once, block); twice != once {
+			t.Errorf("Expected a second upsert to change nothing, but got %q", twice)
+		}
+	})
+
+	t.Run("upsert replaces an old block in place of appending", func(t *testing.T) {
+		old := "export A=1\n\n" + installer.StartMarker + "\necho old\n" + installer.EndMarker + "\nexport B=2\n"
+		got := installer.UpsertBlock(old, block)
+		if strings.Contains(got, "echo old") || strings.Count(got, installer.StartMarker) != 1 {
+			t.Errorf("Expected the old block to be replaced, but got %q", got)
+		}
+		if !strings.Contains(got, "export B=2") {
+			t.Errorf("Expected the lines after the old block to be kept, but got %q", got)
+		}
+		if want := "export A=1\n\n" + block + "export B=2\n"; got != want {
+			t.Errorf("Expected %q, but got %q", want, got)
+		}
+	})
+
+	t.Run("unterminated blocks are left alone", func(t *testing.T) {
+		unterminated := "export A=1\n" + installer.StartMarker + "\nexport B=2\n"
+		if got, found := installer.RemoveBlock(unterminated); found || got != unterminated {
+			t.Errorf("Expected %q to be unchanged, but got %q (found %t)", unterminated, got, found)
+		}
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		got, found := installer.RemoveBlock(installer.UpsertBlock("export A=1\n", block))
+		if !found || got != "export A=1\n" {
+			t.Errorf("Expected %q, but got %q (found %t)", "export A=1\n", got, found)
+		}
+		if _, found := installer.RemoveBlock("export A=1\n"); found {
+			t.Error("Expected no block to be found, but one was")
+		}
+	})
+}
+
+func TestSetupAndUninstall(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("ZDOTDIR", "")
+	dir := t.TempDir()
+	home, prefix := filepath.Join(dir, "home"), filepath.Join(dir, "my prefix") // eg. under a home dir with a space
+	executable := filepath.Join(dir, "build", "termlogger")
+	binary := "#!/bin/sh\necho \"$@\" >> \"$HOME/calls\"\n" // notes what the hook runs
+	for path, contents := range map[string]string{executable: binary, filepath.Join(home, ".bashrc"): "export A=1\n"} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := installer.Options{Home: home, Prefix: prefix, Shells: []string{"bash", "fish"}, Executable: executable, Now: time.Unix(100, 0)}
+
+	plan, err := installer.PlanSetup(opts)
+	if err != nil {
+		t.Fatalf("PlanSetup failed: %v", err)
+	}
+	for _, change := range plan.Changes {
+		if change.Path == filepath.Join(home, ".bashrc") && !strings.Contains(change.Diff(), "+"+installer.StartMarker) {
+			t.Errorf("Expected the diff to add the hook, but got %q", change.Diff())
+		}
+		if err := change.Apply(); err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+	}
+
+	rc, _ := os.ReadFile(filepath.Join(home, ".bashrc"))
+	if !strings.Contains(string(rc), "_termlogger_bin='"+prefix+"/bin'") || strings.Contains(string(rc), "/usr/local/bin") {
+		t.Errorf("Expected the hook to run the binary from the prefix, but got %q", rc)
+	}
+	cmd := exec.Command("bash", "--norc", "--noprofile", "-c", `source "$HOME/.bashrc"`)
+	cmd.Env = append(os.Environ(), "HOME="+home)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Sourcing the hook failed: %v\n%s", err, out)
+	}
+	if calls, _ := os.ReadFile(filepath.Join(home, "calls")); !strings.Contains(string(calls), "fields") {
+		t.Errorf("Expected the hook to run %s/bin/termlogger, but got calls %q", prefix, calls)
+	}
+	if backup, _ := os.ReadFile(filepath.Join(home, ".bashrc.backup.100")); string(backup) != "export A=1\n" {
+		t.Errorf("Expected the original rc file to be backed up, but got %q", backup)
+	}
+	for _, path := range []string{filepath.Join(prefix, "bin", "termlogger"), filepath.Join(home, ".config", "fish", "conf.d", "termlogger.fish"), filepath.Join(home, ".termlogger")} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s to be installed, but got %v", path, err)
+		}
+	}
+
+	t.Run("setup twice changes nothing", func(t *testing.T) {
+		plan, err := installer.PlanSetup(opts)
+		if err != nil {
+			t.Fatalf("PlanSetup failed: %v", err)
+		}
+		if len(plan.Changes) != 0 {
+			t.Errorf("Expected no changes, but got %v", plan.Changes)
+		}
+	})
+
+	t.Run("detects hooked shells", func(t *testing.T) {
+		t.Setenv("SHELL", "/bin/zsh")
+		shells := installer.DetectShells(home)
+		for _, want := range []string{"zsh", "bash", "fish"} {
+			if !strings.Contains(strings.Join(shells, " "), want) {
+				t.Errorf("Expected %s to be detected, but got %v", want, shells)
+			}
+		}
+	})
+
+	t.Run("uninstall", func(t *testing.T) {
+		plan, err := installer.PlanUninstall(installer.Options{Home: home, Prefix: prefix, Now: time.Unix(200, 0)})
+		if err != nil {
+			t.Fatalf("PlanUninstall failed: %v", err)
+		}
+		for _, change := range plan.Changes {
+			if err := change.Apply(); err != nil {
+				t.Fatalf("Apply failed: %v", err)
+			}
+		}
+		if rc, _ := os.ReadFile(filepath.Join(home, ".bashrc")); string(rc) != "export A=1\n" {
+			t.Errorf("Expected the rc file to be restored, but got %q", rc)
+		}
+		if _, err := os.Stat(filepath.Join(prefix, "bin", "termlogger")); !os.IsNotExist(err) {
+			t.Errorf("Expected the binary to be removed, but got %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(home, ".termlogger")); err != nil {
+			t.Errorf("Expected the config dir to be kept without purge, but got %v", err)
+		}
+	})
+}
+
+func TestSetupFollowsSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	home, dotfiles := filepath.Join(dir, "home"), filepath.Join(dir, "dotfiles")
+	for _, d := range []string{home, dotfiles} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dotfiles, "bashrc"), []byte("export A=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rc := filepath.Join(home, ".bashrc")
+	if err := os.Symlink(filepath.Join(dotfiles, "bashrc"), rc); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := installer.PlanSetup(installer.Options{Home: home, Prefix: filepath.Join(dir, "prefix"), Shells: []string{"bash"}, Executable: "/bin/true", GOOS: "linux", Now: time.Unix(100, 0)})
+	if err != nil {
+		t.Fatalf("PlanSetup failed: %v", err)
+	}
+	for _, change := range plan.Changes {
+		if change.Source == "" {
+			if err := change.Apply(); err != nil {
+				t.Fatalf("Apply failed: %v", err)
+			}
+		}
+	}
+
+	if info, err := os.Lstat(rc); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Expected %s to still be a symlink, but got %v (%v)", rc, info, err)
+	}
+	if contents, _ := os.ReadFile(filepath.Join(dotfiles, "bashrc")); !strings.Contains(string(contents), installer.StartMarker) {
+		t.Errorf("Expected the symlink's target to be hooked, but got %q", contents)
+	}
+}
+
+func TestSetupHooksMacLoginShells(t *testing.T) {
+	for _, test := range []struct {
+		name, profile string
+		hooked        bool
+	}{
+		{"creates .bash_profile", "", true},
+		{"sources .bashrc from it", "export PATH=/opt/bin:$PATH\n", true},
+		{"leaves it if it already sources .bashrc", "[ -f ~/.bashrc ] && . ~/.bashrc\n", false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			home := t.TempDir()
+			profile := filepath.Join(home, ".bash_profile")
+			if test.profile != "" {
+				if err := os.WriteFile(profile, []byte(test.profile), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			opts := installer.Options{Home: home, Prefix: filepath.Join(home, "prefix"), Shells: []string{"bash"}, Executable: "/bin/true", GOOS: "darwin", Now: time.Unix(100, 0)}
+			plan, err := installer.PlanSetup(opts)
+			if err != nil {
+				t.Fatalf("PlanSetup failed: %v", err)
+			}
+			for _, change := range plan.Changes {
+				if change.Source == "" {
+					if err := change.Apply(); err != nil {
+						t.Fatalf("Apply failed: %v", err)
+					}
+				}
+			}
+
+			contents, _ := os.ReadFile(profile)
+			if hooked := strings.Contains(string(contents), installer.StartMarker); hooked != test.hooked {
+				t.Errorf("Expected hooked to be %t, but got %q", test.hooked, contents)
+			}
+			if !strings.HasPrefix(string(contents), test.profile) {
+				t.Errorf("Expected %q to be kept, but got %q", test.profile, contents)
+			}
+
+			plan, err = installer.PlanUninstall(opts)
+			if err != nil {
+				t.Fatalf("PlanUninstall failed: %v", err)
+			}
+			for _, change := range plan.Changes {
+				if change.Path == profile {
+					if err := change.Apply(); err != nil {
+						t.Fatalf("Apply failed: %v", err)
+					}
+				}
+			}
+			if contents, _ := os.ReadFile(profile); strings.Contains(string(contents), installer.StartMarker) {
+				t.Errorf("Expected uninstall to remove the block, but got %q", contents)
+			}
+		})
+	}
+}
+
+func TestBashHookKeepsExistingDebugTrap(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash isn't installed")
+	}
+	dir := t.TempDir()
+	home, executable := filepath.Join(dir, "home"), filepath.Join(dir, "build", "termlogger")
+	rc := "trap 'echo theirs >> \"$HOME/theirs\"' DEBUG\n" // eg. bash-preexec, starship or atuin
+	for path, contents := range map[string]string{executable: "#!/bin/sh\necho \"$@\" >> \"$HOME/calls\"\n", filepath.Join(home, ".bashrc"): rc} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	plan, err := installer.PlanSetup(installer.Options{Home: home, Prefix: filepath.Join(dir, "prefix"), Shells: []string{"bash"}, Executable: executable, GOOS: "linux", Now: time.Unix(100, 0)})
+	if err != nil {
+		t.Fatalf("PlanSetup failed: %v", err)
+	}
+	for _, change := range plan.Changes {
+		if err := change.Apply(); err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+	}
+
+	// an interactive shell reading its input from a pipe still runs PROMPT_COMMAND and the DEBUG trap
+	cmd := exec.Command("bash", "--noprofile", "--rcfile", filepath.Join(home, ".bashrc"), "-i")
+	cmd.Env = []string{"HOME=" + home, "PATH=" + os.Getenv("PATH"), "HISTFILE=/dev/null", "TERM=dumb"}
+	cmd.Stdin = strings.NewReader("echo one\n" +
+		"trap 'echo replaced >> \"$HOME/theirs\"' DEBUG\n" + // replaces the chained trap, history takes over
+		"echo two\n")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("bash failed: %v\n%s", err, out)
+	}
+
+	var calls string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		contents, _ := os.ReadFile(filepath.Join(home, "calls")) // the hook records in the background
+		if calls = string(contents); strings.Contains(calls, "--cmd=echo two") {
+			break
+		}
+	}
+	for _, want := range []string{"record --start --seq=1 --cmd=echo one", "record --cmd=echo two"} {
+		if !strings.Contains(calls, want) {
+			t.Errorf("Expected the hook to run %q, but got calls %q", want, calls)
+		}
+	}
+	if theirs, _ := os.ReadFile(filepath.Join(home, "theirs")); !strings.Contains(string(theirs), "theirs") {
+		t.Errorf("Expected the existing DEBUG trap to keep running, but got %q", theirs)
+	}
+}
+
+func TestSetupFishAndNu(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "")
+	dir := t.TempDir()
+	home, prefix := filepath.Join(dir, "home"), filepath.Join(dir, "it's mine") // a quote and a space, both need escaping
+	executable := filepath.Join(dir, "build", "termlogger")
+	for path, contents := range map[string]string{executable: "#!/bin/sh\n", filepath.Join(home, ".config", "nushell", "config.nu"): "$env.A = 1\n"} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	plan, err := installer.PlanSetup(installer.Options{Home: home, Prefix: prefix, Shells: []string{"fish", "nu"}, Executable: executable, GOOS: "linux", Now: time.Unix(100, 0)})
+	if err != nil {
+		t.Fatalf("PlanSetup failed: %v", err)
+	}
+	for _, change := range plan.Changes {
+		if err := change.Apply(); err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+	}
+
+	fishHook := filepath.Join(home, ".config", "fish", "conf.d", "termlogger.fish")
+	nuHook := filepath.Join(home, ".config", "nushell", "termlogger.nu")
+	binDir := filepath.Join(prefix, "bin")
+	for path, want := range map[string]string{
+		fishHook: "set -g _termlogger_bin '" + strings.ReplaceAll(binDir, "'", `\'`) + "'\n",
+		nuHook:   `const _termlogger_bin = "` + binDir + `"` + "\n",
+	} {
+		hook, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Expected %s to be installed, but got %v", path, err)
+		}
+		if !strings.Contains(string(hook), want) || strings.Contains(string(hook), "/usr/local/bin") {
+			t.Errorf("Expected %s to set %q, but got %q", path, want, hook)
+		}
+	}
+	config, _ := os.ReadFile(filepath.Join(home, ".config", "nushell", "config.nu"))
+	if want := "$env.A = 1\n\n" + installer.StartMarker + "\nsource '" + nuHook + "'\n" + installer.EndMarker + "\n"; string(config) != want {
+		t.Errorf("Expected config.nu to source the hook, but got %q", config)
+	}
+
+	t.Run("fish parses the hook", func(t *testing.T) {
+		hook, _ := os.ReadFile(fishHook)
+		if opened, ended := fishBlocks(string(hook)); opened != ended {
+			t.Errorf("Expected every fish block to be ended, but got %d blocks and %d ends", opened, ended)
+		}
+		if _, err := exec.LookPath("fish"); err != nil {
+			t.Skip("fish isn't installed")
+		}
+		if out, err := exec.Command("fish", "--no-execute", fishHook).CombinedOutput(); err != nil {
+			t.Errorf("Expected the fish hook to parse, but got %v\n%s", err, out)
+		}
+	})
+
+	t.Run("nu parses the hook", func(t *testing.T) {
+		hook, _ := os.ReadFile(nuHook)
+		if unbalanced := nuBrackets(string(hook)); unbalanced != "" {
+			t.Errorf("Expected the nu hook's brackets to balance, but got %s", unbalanced)
+		}
+		if _, err := exec.LookPath("nu"); err != nil {
+			t.Skip("nu isn't installed")
+		}
+		cmd := exec.Command("nu", "--no-config-file", "-c", "nu-check --debug $env.TERMLOGGER_HOOK")
+		cmd.Env = append(os.Environ(), "TERMLOGGER_HOOK="+nuHook)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("Expected the nu hook to parse, but got %v\n%s", err, out)
+		}
+	})
+}
+
+// fishBlocks counts the blocks a fish script opens and the ends closing them, for when fish itself isn't around
+func fishBlocks(script string) (opened, ended int) {
+	for _, line := range strings.Split(script, "\n") {
+		switch strings.Fields(line + " #")[0] {
+		case "function", "if", "for", "while", "switch", "begin":
+			opened++
+		case "end":
+			ended++
+		}
+	}
+	return opened, ended
+}
+
+// nuBrackets returns the first bracket in a nu script that isn't matched (skipping strings and comments), or "" when
+// they all balance
+func nuBrackets(script string) string {
+	var open []rune
+	pairs := map[rune]rune{')': '(', ']': '[', '}': '{'}
+	var quote rune
+	escaped, comment := false, false
+	for _, c := range script {
+		switch {
+		case comment:
+			comment = c != '\n'
+		case quote != 0:
+			if quote == '"' && c == '\\' && !escaped {
+				escaped = true
+				continue
+			}
+			if c == quote && !escaped {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '#':
+			comment = true
+		case c == '(' || c == '[' || c == '{':
+			open = append(open, c)
+		case pairs[c] != 0:
+			if len(open) == 0 || open[len(open)-1] != pairs[c] {
+				return "an unopened " + string(c)
+			}
+			open = open[:len(open)-1]
+		}
+		escaped = false
+	}
+	if len(open) > 0 {
+		return "an unclosed " + string(open[len(open)-1])
+	}
+	return ""
+}
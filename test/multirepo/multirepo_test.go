@@ -0,0 +1,711 @@
+package multirepo_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/WillRabalais04/terminalLog/internal/adapters/archive"
+	"github.com/WillRabalais04/terminalLog/internal/adapters/database"
+	"github.com/WillRabalais04/terminalLog/internal/core/domain"
+	"github.com/google/uuid"
+)
+
+// downRepo simulates an unreachable backend
+type downRepo struct{}
+
+var errDown = errors.New("backend is down")
+
+func (downRepo) Log(ctx context.Context, entries []*domain.LogEntry) error { return errDown }
+func (downRepo) Complete(ctx context.Context, entries []*domain.LogEntry) error {
+	return errDown
+}
+func (downRepo) Get(ctx context.Context, id string) (*domain.LogEntry, error) {
+	return nil, errDown
+}
+func (downRepo) List(ctx context.Context, filters *domain.LogFilter) ([]*domain.LogEntry, error) {
+	return nil, errDown
+}
+func (downRepo) Delete(ctx context.Context, id string) (*domain.LogEntry, error) {
+	return nil, errDown
+}
+func (downRepo) DeleteMultiple(ctx context.Context, filters *domain.LogFilter) ([]*domain.LogEntry, error) {
+	return nil, errDown
+}
+
+func newLocalRepo(t *testing.T, name string) *database.LogRepo {
+	t.Helper()
+	repo, err := database.GetLocalRepo(filepath.Join(t.TempDir(), name+".db"))
+	if err != nil {
+		t.Fatalf("could not init %s repo: %v", name, err)
+	}
+	return repo
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("could not open archive: %v", err)
+	}
+	defer file.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+	}
+	return lines
+}
+
+func TestWritePolicies(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("All Must Succeed", func(t *testing.T) {
+		primary, mirror := newLocalRepo(t, "primary"), newLocalRepo(t, "mirror")
+		repo := database.NewMultiRepoWithPolicies([]database.Target{
+			{Name: "primary", Repo: primary, Role: database.RolePrimary},
+			{Name: "mirror", Repo: mirror, Role: database.RoleMirror},
+		}, database.WriteAllMustSucceed, database.ReadFirstHealthy)
+
+		entry := &domain.LogEntry{Command: "make deploy"}
+		if err := repo.Log(ctx, []*domain.LogEntry{entry}); err != nil {
+			t.Fatalf("Log failed: %v", err)
+		}
+		for name, target := range map[string]*database.LogRepo{"primary": primary, "mirror": mirror} {
+			if _, err := target.Get(ctx, entry.EventID); err != nil {
+				t.Errorf("Expected %s to have entry %s: %v", name, entry.EventID, err)
+			}
+		}
+	})
+
+	t.Run("All Must Succeed Falls Back", func(t *testing.T) {
+		primary, cache := newLocalRepo(t, "primary"), newLocalRepo(t, "cache")
+		repo := database.NewMultiRepoWithPolicies([]database.Target{
+			{Name: "primary", Repo: primary, Role: database.RolePrimary},
+			{Name: "mirror", Repo: downRepo{}, Role: database.RoleMirror},
+			{Name: "cache", Repo: cache, Role: database.RoleFallback},
+		}, database.WriteAllMustSucceed, database.ReadFirstHealthy)
+
+		entry := &domain.LogEntry{Command: "make deploy"}
+		if err := repo.Log(ctx, []*domain.LogEntry{entry}); err != nil {
+			t.Fatalf("Log failed: %v", err)
+		}
+		if _, err := cache.Get(ctx, entry.EventID); err != nil {
+			t.Errorf("Expected failed replica write to land in the fallback: %v", err)
+		}
+	})
+
+	t.Run("Primary Then Async Mirrors", func(t *testing.T) {
+		primary := newLocalRepo(t, "primary")
+		archivePath := filepath.Join(t.TempDir(), "archive.ndjson")
+		ndjson, err := archive.NewNDJSONArchive(archivePath)
+		if err != nil {
+			t.Fatalf("could not init archive: %v", err)
+		}
+		repo := database.NewMultiRepoWithPolicies([]database.Target{
+			{Name: "archive", Repo: ndjson, Role: database.RoleMirror},
+			{Name: "primary", Repo: primary, Role: database.RolePrimary},
+		}, database.WritePrimaryThenAsync, database.ReadFirstHealthy)
+
+		entries := []*domain.LogEntry{{Command: "ls"}, {Command: "pwd"}}
+		if err := repo.Log(ctx, entries); err != nil {
+			t.Fatalf("Log failed: %v", err)
+		}
+		repo.Wait()
+
+		if lines := countLines(t, archivePath); lines != len(entries) {
+			t.Errorf("Expected %d archived entries, but found %d", len(entries), lines)
+		}
+		found, err := repo.Get(ctx, entries[0].EventID) // archive can't be read so this has to come from the primary
+		if err != nil || found.Command != "ls" {
+			t.Errorf("Expected to read entry back from primary: %v", err)
+		}
+	})
+
+	t.Run("First Success Still Mirrors", func(t *testing.T) {
+		primary := newLocalRepo(t, "primary")
+		archivePath := filepath.Join(t.TempDir(), "archive.ndjson")
+		ndjson, err := archive.NewNDJSONArchive(archivePath)
+		if err != nil {
+			t.Fatalf("could not init archive: %v", err)
+		}
+		repo := database.NewMultiRepoWithPolicies([]database.Target{
+			{Name: "primary", Repo: primary, Role: database.RolePrimary},
+			{Name: "archive", Repo: ndjson, Role: database.RoleMirror},
+		}, database.WriteFirstSuccess, database.ReadFirstHealthy)
+
+		entries := []*domain.LogEntry{{Command: "ls"}, {Command: "pwd"}}
+		if err := repo.Log(ctx, entries); err != nil {
+			t.Fatalf("Log failed: %v", err)
+		}
+		repo.Wait()
+
+		if lines := countLines(t, archivePath); lines != len(entries) {
+			t.Errorf("Expected %d archived entries, but found %d", len(entries), lines)
+		}
+	})
+
+	t.Run("First Success Skips Down Primary", func(t *testing.T) {
+		mirror := newLocalRepo(t, "mirror")
+		repo := database.NewMultiRepoWithPolicies([]database.Target{
+			{Name: "primary", Repo: downRepo{}, Role: database.RolePrimary},
+			{Name: "mirror", Repo: mirror, Role: database.RoleMirror},
+		}, database.WriteFirstSuccess, database.ReadFirstHealthy)
+
+		entry := &domain.LogEntry{Command: "whoami"}
+		if err := repo.Log(ctx, []*domain.LogEntry{entry}); err != nil {
+			t.Fatalf("Log failed: %v", err)
+		}
+		if _, err := repo.Get(ctx, entry.EventID); err != nil {
+			t.Errorf("Expected to read entry from the first healthy target: %v", err)
+		}
+	})
+}
+
+func TestMergedReads(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	work, personal := newLocalRepo(t, "work"), newLocalRepo(t, "personal")
+	shared := &domain.LogEntry{EventID: "11111111-1111-1111-1111-111111111111", Command: "git pull", Timestamp: 2}
+	if err := work.Log(ctx, []*domain.LogEntry{shared, {Command: "kubectl get pods", Timestamp: 3}}); err != nil {
+		t.Fatalf("failed to seed work repo: %v", err)
+	}
+	if err := personal.Log(ctx, []*domain.LogEntry{shared, {Command: "brew upgrade", Timestamp: 1}}); err != nil {
+		t.Fatalf("failed to seed personal repo: %v", err)
+	}
+
+	repo := database.NewMultiRepoWithPolicies([]database.Target{
+		{Name: "work", Repo: work, Role: database.RolePrimary},
+		{Name: "personal", Repo: personal, Role: database.RoleReadOnly},
+	}, database.WriteFirstSuccess, database.ReadMerged)
+
+	entries, err := repo.List(ctx, &domain.LogFilter{})
+	if err != nil {
+		t.Fatalf("merged List failed: %v", err)
+	}
+	expected := []string{"kubectl get pods", "git pull", "brew upgrade"}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d merged entries, but got %d", len(expected), len(entries))
+	}
+	for i, command := range expected {
+		if entries[i].Command != command {
+			t.Errorf("Expected entry %d to be '%s', but got '%s'", i, command, entries[i].Command)
+		}
+	}
+
+	page, err := repo.List(ctx, domain.NewFilterBuilder().SetLimit(1).SetOffset(1).Build())
+	if err != nil {
+		t.Fatalf("merged paged List failed: %v", err)
+	}
+	if len(page) != 1 || page[0].Command != "git pull" {
+		t.Errorf("Expected page to contain only 'git pull', but got %d entries", len(page))
+	}
+}
+
+func TestBatchRepo(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	local := newLocalRepo(t, "local")
+	batcher := database.NewBatchRepo(local, 2)
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		batcher.Run(ctx, time.Hour, quit)
+	}()
+
+	entry := &domain.LogEntry{Command: "terraform plan"}
+	if err := batcher.Log(ctx, []*domain.LogEntry{entry}); err != nil {
+		t.Fatalf("Log failed: %v", err)
+	}
+	if _, err := local.Get(ctx, entry.EventID); err == nil {
+		t.Error("Expected entry to be held until the batch fills, but it was already written.")
+	}
+	if _, err := batcher.Get(ctx, entry.EventID); err != nil { // reads flush pending entries first
+		t.Errorf("Expected read through the batcher to see the pending entry: %v", err)
+	}
+
+	if err := batcher.Log(ctx, []*domain.LogEntry{{Command: "ls"}}); err != nil {
+		t.Fatalf("Log failed: %v", err)
+	}
+	last := &domain.LogEntry{Command: "exit"}
+	if err := batcher.Log(ctx, []*domain.LogEntry{last}); err != nil {
+		t.Fatalf("Log failed: %v", err)
+	}
+	close(quit)
+	<-done
+
+	if _, err := local.Get(ctx, last.EventID); err != nil {
+		t.Errorf("Expected pending entries to be written on shutdown: %v", err)
+	}
+}
+
+func TestDisabledFieldsStoredAsNull(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	fields, err := domain.ResolveFields("standard", nil, []string{"ssh_client", "git_status"})
+	if err != nil {
+		t.Fatalf("ResolveFields failed: %v", err)
+	}
+	entry := &domain.LogEntry{Command: "ssh prod", SSHClient: "10.0.0.1 5000 22", GitStatus: "M main.go", GitBranch: "main"}
+	fields.Apply(entry)
+
+	local := newLocalRepo(t, "local")
+	if err := local.Log(ctx, []*domain.LogEntry{entry}); err != nil {
+		t.Fatalf("Log failed: %v", err)
+	}
+	stored, err := local.Get(ctx, entry.EventID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	if stored.SSHClient != "" || stored.GitStatus != "" {
+		t.Errorf("Expected disabled fields to be dropped, but got ssh_client=%q git_status=%q", stored.SSHClient, stored.GitStatus)
+	}
+	if !stored.IsDisabled("ssh_client") || !stored.IsDisabled("git_status") {
+		t.Errorf("Expected ssh_client and git_status to read back as NULL, but got disabled fields %v", stored.DisabledFields)
+	}
+	if stored.IsDisabled("git_branch") || stored.GitBranch != "main" {
+		t.Errorf("Expected git_branch to be kept, but got %q (disabled fields %v)", stored.GitBranch, stored.DisabledFields)
+	}
+
+	forensic, err := domain.ResolveFields("forensic", nil, nil)
+	if err != nil {
+		t.Fatalf("ResolveFields failed: %v", err)
+	}
+	empty := &domain.LogEntry{Command: "true"} // captured, just nothing to store
+	forensic.Apply(empty)
+	if err := local.Log(ctx, []*domain.LogEntry{empty}); err != nil {
+		t.Fatalf("Log failed: %v", err)
+	}
+	if stored, err = local.Get(ctx, empty.EventID); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if stored.IsDisabled("pipestatus") || stored.IsDisabled("context") {
+		t.Errorf("Expected empty pipestatus and context not to read back as disabled, but got disabled fields %v", stored.DisabledFields)
+	}
+
+	if _, err := domain.ResolveFields("standard", nil, []string{"command"}); err == nil {
+		t.Error("Expected disabling a required field to fail.")
+	}
+	if _, err := domain.ResolveFields("standard", nil, []string{"ssh_clinet"}); err == nil {
+		t.Error("Expected disabling an unknown field to fail.")
+	}
+}
+
+func TestCaptureProfiles(t *testing.T) {
+	standard, err := domain.ResolveFields("standard", nil, nil)
+	if err != nil {
+		t.Fatalf("ResolveFields failed: %v", err)
+	}
+	forensic, err := domain.ResolveFields("forensic", nil, nil)
+	if err != nil {
+		t.Fatalf("ResolveFields failed: %v", err)
+	}
+	for field := range standard {
+		if !forensic.Enabled(field) {
+			t.Errorf("Expected forensic to capture standard's %s, but it doesn't", field)
+		}
+	}
+	for _, field := range domain.ForensicFields {
+		if standard.Enabled(field) || !forensic.Enabled(field) {
+			t.Errorf("Expected %s to only be captured by forensic", field)
+		}
+	}
+
+	t.Run("Pipestatus Follows Exit Code", func(t *testing.T) {
+		fields, err := domain.ResolveFields("standard", nil, []string{"exit_code"})
+		if err != nil {
+			t.Fatalf("ResolveFields failed: %v", err)
+		}
+		entry := &domain.LogEntry{Command: "make | tee out.log", ExitCode: 2, PipeStatus: []int32{2, 0}}
+		fields.Apply(entry)
+		if entry.PipeStatus != nil || !entry.IsDisabled("pipestatus") {
+			t.Errorf("Expected pipestatus to be dropped with exit_code, but got %v (disabled fields %v)", entry.PipeStatus, entry.DisabledFields)
+		}
+	})
+}
+
+func TestInFlightEntries(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	local := newLocalRepo(t, "local")
+	running := domain.NewFilterBuilder().AddFilterTerm("status", domain.StatusRunning).Build()
+	started := func(command string) *domain.LogEntry {
+		return &domain.LogEntry{EventID: uuid.New().String(), SessionID: "bastion-1", Command: command, Timestamp: 100, Status: domain.StatusRunning}
+	}
+
+	t.Run("Start Then Complete", func(t *testing.T) {
+		entry := started("terraform apply")
+		if err := local.Log(ctx, []*domain.LogEntry{entry}); err != nil {
+			t.Fatalf("Log failed: %v", err)
+		}
+		if found, err := local.List(ctx, running); err != nil || len(found) != 1 {
+			t.Fatalf("Expected 1 running entry, but got %d (err: %v)", len(found), err)
+		}
+
+		done := *entry
+		done.ExitCode, done.Status, done.EndTimestamp = 1, domain.StatusCompleted, 160
+		if err := local.Complete(ctx, []*domain.LogEntry{&done}); err != nil {
+			t.Fatalf("Complete failed: %v", err)
+		}
+		if err := local.Complete(ctx, []*domain.LogEntry{entry}); err != nil { // a replayed start can't reopen it
+			t.Fatalf("Complete failed: %v", err)
+		}
+		stored, err := local.Get(ctx, entry.EventID)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if stored.Status != domain.StatusCompleted || stored.ExitCode != 1 || stored.EndTimestamp != 160 || stored.Timestamp != 100 {
+			t.Errorf("Expected completed entry (exit 1, 100-160), but got %s (exit %d, %d-%d)", stored.Status, stored.ExitCode, stored.Timestamp, stored.EndTimestamp)
+		}
+	})
+
+	t.Run("Completion Without Start", func(t *testing.T) {
+		entry := &domain.LogEntry{EventID: uuid.New().String(), Command: "ls", Timestamp: 100, Status: domain.StatusCompleted, EndTimestamp: 101}
+		if err := local.Complete(ctx, []*domain.LogEntry{entry}); err != nil {
+			t.Fatalf("Complete failed: %v", err)
+		}
+		if stored, err := local.Get(ctx, entry.EventID); err != nil || stored.Command != "ls" {
+			t.Errorf("Expected the completion to be inserted whole: %v", err)
+		}
+	})
+
+	t.Run("Exec Owns Usage And The Prompt Owns Exit Details", func(t *testing.T) {
+		usage := &domain.ResourceUsage{UserTimeMicros: 4200000, SysTimeMicros: 300000, MaxRSSKB: 512000, VoluntaryCtxSwitches: 90}
+		for _, promptFirst := range []bool{false, true} {
+			entry := started("make -j8 | tee build.log")
+			fromExec, fromPrompt := *entry, *entry
+			fromExec.ExitCode, fromExec.PipeStatus, fromExec.Status, fromExec.EndTimestamp, fromExec.Usage = 2, []int32{2}, domain.StatusCompleted, 130, usage
+			fromPrompt.ExitCode, fromPrompt.PipeStatus, fromPrompt.Status, fromPrompt.EndTimestamp = 0, []int32{2, 0}, domain.StatusCompleted, 131
+			completions := []*domain.LogEntry{entry, &fromExec, &fromPrompt, &fromExec} // exec's replayed last
+			if promptFirst {
+				completions = []*domain.LogEntry{entry, &fromPrompt, &fromExec}
+			}
+
+			// the start and both completions in one batch, like the daemon would flush them
+			if err := local.Complete(ctx, completions); err != nil {
+				t.Fatalf("Complete failed: %v", err)
+			}
+			stored, err := local.Get(ctx, entry.EventID)
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			if stored.Usage == nil || *stored.Usage != *usage {
+				t.Errorf("Expected usage %+v, but got %+v", usage, stored.Usage)
+			}
+			if stored.ExitCode != 0 || !slices.Equal(stored.PipeStatus, []int32{2, 0}) {
+				t.Errorf("Expected the prompt's exit 0 and pipestatus [2 0], but got exit %d and %v (prompt first: %t)", stored.ExitCode, stored.PipeStatus, promptFirst)
+			}
+			if want := completions[1].EndTimestamp; stored.EndTimestamp != want {
+				t.Errorf("Expected the first completion's end %d, but got %d", want, stored.EndTimestamp)
+			}
+		}
+	})
+
+	t.Run("Ended Sessions Reap Running Entries", func(t *testing.T) {
+		entry := started("tail -f app.log")
+		if err := local.Log(ctx, []*domain.LogEntry{entry}); err != nil {
+			t.Fatalf("Log failed: %v", err)
+		}
+		if err := local.UpsertSessions(ctx, []*domain.Session{{ID: "bastion-1", LastSeen: 200, EndedAt: 200}}); err != nil {
+			t.Fatalf("UpsertSessions failed: %v", err)
+		}
+		stored, err := local.Get(ctx, entry.EventID)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if stored.Status != domain.StatusAbandoned || stored.EndTimestamp != 200 {
+			t.Errorf("Expected entry to be abandoned at 200, but got %s at %d", stored.Status, stored.EndTimestamp)
+		}
+		if found, _ := local.List(ctx, running); len(found) != 0 {
+			t.Errorf("Expected no running entries, but got %d", len(found))
+		}
+	})
+
+	t.Run("Session Ids Carry The Shell PID", func(t *testing.T) { // what termloggerd checks to end dead shells' sessions
+		tests := map[string]int{
+			"laptop-4242-1700000000-123":    4242,
+			"build-box-01-77-1700000000-9":  77,
+			"bastion-1":                     0,
+			"laptop-notapid-1700000000-123": 0,
+			"-0-1700000000-123":             0,
+		}
+		for id, expected := range tests {
+			if pid := (&domain.Session{ID: id}).ShellPID(); pid != expected {
+				t.Errorf("Expected pid %d from '%s', but got %d", expected, id, pid)
+			}
+		}
+	})
+
+	t.Run("Idle Shells Aren't Reaped", func(t *testing.T) {
+		now := int64(10_000)
+		alive := func(pid int) bool { return pid == 4242 }
+		tests := []struct {
+			name     string
+			session  domain.Session
+			expected bool
+		}{
+			{"idle but alive", domain.Session{ID: "laptop-4242-1700000000-1", LastSeen: now - 3600}, false},
+			{"dead, seen within the heartbeat interval", domain.Session{ID: "laptop-77-1700000000-1", LastSeen: now - domain.HeartbeatInterval}, false},
+			{"dead and overdue", domain.Session{ID: "laptop-77-1700000000-1", LastSeen: now - 3*domain.HeartbeatInterval}, true},
+			{"already ended", domain.Session{ID: "laptop-77-1700000000-1", LastSeen: now - 3600, EndedAt: now - 3600}, false},
+			{"no pid in its id", domain.Session{ID: "bastion-1", LastSeen: now - 3600}, false},
+		}
+		for _, tt := range tests {
+			if reapable := tt.session.Reapable(now, alive); reapable != tt.expected {
+				t.Errorf("Expected %s to be reapable=%t, but got %t", tt.name, tt.expected, reapable)
+			}
+		}
+	})
+
+	t.Run("Batched Start And Completion", func(t *testing.T) {
+		batcher := database.NewBatchRepo(local, 10)
+		entry := started("make build")
+		done := *entry
+		done.Status, done.EndTimestamp = domain.StatusCompleted, 130
+		if err := batcher.Complete(ctx, []*domain.LogEntry{&done}); err != nil {
+			t.Fatalf("Complete failed: %v", err)
+		}
+		if err := batcher.Log(ctx, []*domain.LogEntry{entry}); err != nil {
+			t.Fatalf("Log failed: %v", err)
+		}
+		stored, err := batcher.Get(ctx, entry.EventID)
+		if err != nil || stored.Status != domain.StatusCompleted {
+			t.Errorf("Expected the batched entry to end up completed: %v", err)
+		}
+	})
+}
+
+func TestContextFilters(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	local := newLocalRepo(t, "local")
+	inPod := &domain.LogEntry{Command: "go test ./...", Timestamp: 1, KubeContext: "prod-eu", KubeNamespace: "ci", Context: &domain.RuntimeContext{
+		Container: "kubernetes", Pod: "ci-runner-7f9c", Toolchains: map[string]string{"go": "1.24.1"},
+	}}
+	inVenv := &domain.LogEntry{Command: "pytest", Timestamp: 2, Labels: map[string]string{"team": "payments", "ticket": "OPS-42"}, Context: &domain.RuntimeContext{
+		Venv: "/home/dev/api/.venv", Toolchains: map[string]string{"python": "3.12.1", "go": "1.22.0"},
+	}}
+	bare := &domain.LogEntry{Command: "ls", Timestamp: 3}
+	if err := local.Log(ctx, []*domain.LogEntry{inPod, inVenv, bare}); err != nil {
+		t.Fatalf("Log failed: %v", err)
+	}
+
+	stored, err := local.Get(ctx, inPod.EventID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if stored.Context == nil || stored.Context.Pod != "ci-runner-7f9c" || stored.Context.Toolchains["go"] != "1.24.1" {
+		t.Errorf("Expected the context to round trip, but got %+v", stored.Context)
+	}
+
+	tests := []struct {
+		name     string
+		filter   *domain.LogFilter
+		expected []string
+	}{
+		{"Top Level Key", domain.NewFilterBuilder().AddFilterTerm("context.container", "kubernetes").Build(), []string{"go test ./..."}},
+		{"Nested Key", domain.NewFilterBuilder().AddFilterTerm("context.toolchains.go", "1.22.0").Build(), []string{"pytest"}},
+		{"Search", domain.NewFilterBuilder().AddSearchTerm("context.venv", "api").Build(), []string{"pytest"}},
+		{"Label", domain.NewFilterBuilder().AddFilterTerm("labels.ticket", "OPS-42").Build(), []string{"pytest"}},
+		{"Kube Context", domain.NewFilterBuilder().AddFilterTerm("kube_context", "prod-eu").AddSearchTerm("command", "go test").Build(), []string{"go test ./..."}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := local.List(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			if len(entries) != len(tt.expected) {
+				t.Fatalf("Expected %d entries, but got %d", len(tt.expected), len(entries))
+			}
+			for i, command := range tt.expected {
+				if entries[i].Command != command {
+					t.Errorf("Expected entry %d to be '%s', but got '%s'", i, command, entries[i].Command)
+				}
+			}
+		})
+	}
+
+	t.Run("Unsafe Key Is Rejected", func(t *testing.T) {
+		if _, err := local.List(ctx, domain.NewFilterBuilder().AddFilterTerm("context.venv'--", "x").Build()); err == nil {
+			t.Error("Expected a key that can't be quoted into the query to be rejected")
+		}
+	})
+}
+
+func TestCommandPrefix(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	path := filepath.Join(t.TempDir(), "local.db")
+	local, err := database.GetLocalRepo(path)
+	if err != nil {
+		t.Fatalf("could not init local repo: %v", err)
+	}
+	var entries []*domain.LogEntry
+	for _, command := range []string{"git_sync", "gitxsync", "echo 100% done", "echo 100x done", `dir C:\tmp`, "sudo git_sync"} {
+		entries = append(entries, &domain.LogEntry{Command: command})
+	}
+	if err := local.Log(ctx, entries); err != nil {
+		t.Fatalf("Log failed: %v", err)
+	}
+	readOnly, err := database.OpenLocalRepo(path, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("OpenLocalRepo failed: %v", err)
+	}
+
+	for prefix, expected := range map[string]string{"git_": "git_sync", "echo 100%": "echo 100% done", `dir C:\`: `dir C:\tmp`} {
+		found, err := readOnly.List(ctx, domain.NewFilterBuilder().SetCommandPrefix(prefix).Build())
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if len(found) != 1 || found[0].Command != expected {
+			t.Errorf("Expected only %q to start with %q, but got %v", expected, prefix, found)
+		}
+	}
+	if err := readOnly.Log(ctx, entries[:1]); err == nil {
+		t.Error("Expected the repo opened for reading to refuse writes")
+	}
+}
+
+func TestRankDirectories(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	local := newLocalRepo(t, "local")
+	now := time.Now().Unix()
+	var entries []*domain.LogEntry
+	add := func(cwd, host string, count int, ts int64) {
+		for range count {
+			entries = append(entries, &domain.LogEntry{Command: "ls", User: "dev", WorkingDirectory: cwd, Hostname: host, Timestamp: ts})
+		}
+	}
+	add("/src/api", "laptop", 2, now)                  // 2 visits within the hour
+	add("/src/web", "laptop", 6, now-3*24*60*60)       // 6 visits three days ago
+	add("/src/api/internal", "server", 5, now)         // busiest but on another host
+	add("/home/dev/API-notes", "", 1, now-24*60*60*30) // no hostname, counts for every host
+	if err := local.Log(ctx, entries); err != nil {
+		t.Fatalf("Log failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		filter   *domain.DirectoryFilter
+		expected []string
+	}{
+		{"Frecency", &domain.DirectoryFilter{User: "dev", Host: "laptop"}, []string{"/src/api", "/src/web", "/home/dev/API-notes"}},
+		{"Every Host", &domain.DirectoryFilter{User: "dev", Limit: 2}, []string{"/src/api/internal", "/src/api"}},
+		{"Fragment In Last Component", &domain.DirectoryFilter{User: "dev", Host: "laptop", Fragments: []string{"api"}}, []string{"/src/api", "/home/dev/API-notes"}},
+		{"Fragments In Order", &domain.DirectoryFilter{User: "dev", Fragments: []string{"src", "int"}}, []string{"/src/api/internal"}},
+		{"Out Of Order", &domain.DirectoryFilter{User: "dev", Fragments: []string{"api", "src"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			directories, err := local.RankDirectories(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("RankDirectories failed: %v", err)
+			}
+			if len(directories) != len(tt.expected) {
+				t.Fatalf("Expected %d directories, but got %d", len(tt.expected), len(directories))
+			}
+			for i, path := range tt.expected {
+				if directories[i].Path != path {
+					t.Errorf("Expected directory %d to be '%s', but got '%s'", i, path, directories[i].Path)
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteRejectsUnknownFields(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	repo := newLocalRepo(t, "local")
+	entries := []*domain.LogEntry{{Command: "make", User: "dev", ExitCode: 1}, {Command: "ls", User: "dev"}}
+	if err := repo.Log(ctx, entries); err != nil {
+		t.Fatalf("Log failed: %v", err)
+	}
+
+	filters := map[string]*domain.LogFilter{
+		"unknown key":    domain.NewFilterBuilder().SetFilterMode(domain.AND).AddFilterTerm("user_name", "dev").AddFilterTerm("exti_code", "1").Build(),
+		"invalid value":  domain.NewFilterBuilder().SetFilterMode(domain.AND).AddFilterTerm("user_name", "dev").AddFilterTerm("exit_code", "one").Build(),
+		"exact only key": domain.NewFilterBuilder().AddFilterTerm("user_name", "dev").AddSearchTerm("exit_code", "1").Build(),
+		"json column":    domain.NewFilterBuilder().AddFilterTerm("user_name", "dev").AddFilterTerm("labels", "x").Build(),
+	}
+	for name, filter := range filters {
+		t.Run(name, func(t *testing.T) {
+			if _, err := repo.DeleteMultiple(ctx, filter); err == nil {
+				t.Error("Expected the delete to fail, but it succeeded")
+			}
+			if err := database.ValidateFilter(filter); err == nil {
+				t.Error("Expected the filter to be rejected before it's sent")
+			}
+		})
+	}
+
+	remaining, err := repo.List(ctx, domain.NewFilterBuilder().AddFilterTerm("user_name", "dev").Build())
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(remaining) != len(entries) {
+		t.Errorf("Expected %d entries to be left, but got %d", len(entries), len(remaining))
+	}
+}
+
+func TestMergedReadOrdering(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	work, personal := newLocalRepo(t, "work"), newLocalRepo(t, "personal")
+	if err := work.Log(ctx, []*domain.LogEntry{{Command: "b", ExitCode: 2, Timestamp: 1}, {Command: "d", ExitCode: 0, Timestamp: 4}}); err != nil {
+		t.Fatalf("failed to seed work repo: %v", err)
+	}
+	if err := personal.Log(ctx, []*domain.LogEntry{{Command: "a", ExitCode: 1, Timestamp: 3}, {Command: "c", ExitCode: 3, Timestamp: 2}}); err != nil {
+		t.Fatalf("failed to seed personal repo: %v", err)
+	}
+	repo := database.NewMultiRepoWithPolicies([]database.Target{
+		{Name: "work", Repo: work, Role: database.RolePrimary},
+		{Name: "personal", Repo: personal, Role: database.RoleReadOnly},
+	}, database.WriteFirstSuccess, database.ReadMerged)
+
+	tests := []struct {
+		name     string
+		filter   *domain.LogFilter
+		expected []string
+	}{
+		{"Nil Filter", nil, []string{"d", "a", "c", "b"}},
+		{"Command Descending", domain.NewFilterBuilder().SetOrderBy("command").Build(), []string{"d", "c", "b", "a"}},
+		{"Command Ascending", domain.NewFilterBuilder().SetOrderBy("-command").Build(), []string{"a", "b", "c", "d"}},
+		{"Exit Code Ascending", domain.NewFilterBuilder().SetOrderBy("-exit_code").Build(), []string{"d", "a", "b", "c"}},
+		{"Exit Code Paged", domain.NewFilterBuilder().SetOrderBy("exit_code").SetLimit(2).SetOffset(1).Build(), []string{"b", "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := repo.List(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("merged List failed: %v", err)
+			}
+			var commands []string
+			for _, entry := range entries {
+				commands = append(commands, entry.Command)
+			}
+			if !slices.Equal(commands, tt.expected) {
+				t.Errorf("Expected %v, but got %v", tt.expected, commands)
+			}
+		})
+	}
+}
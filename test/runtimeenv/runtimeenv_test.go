@@ -0,0 +1,125 @@
+package runtimeenv_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/WillRabalais04/terminalLog/internal/adapters/runtimeenv"
+)
+
+// fakeToolchain puts a node on PATH that reports version and counts how often it ran
+func fakeToolchain(t *testing.T, version string) (counter string) {
+	t.Helper()
+	bin := t.TempDir()
+	counter = filepath.Join(bin, "runs")
+	script := "#!/bin/sh\necho x >> " + counter + "\necho v" + version + "\n"
+	if err := os.WriteFile(filepath.Join(bin, "node"), []byte(script), 0755); err != nil {
+		t.Fatalf("could not write fake node: %v", err)
+	}
+	t.Setenv("PATH", bin)
+	return counter
+}
+
+func TestDetect(t *testing.T) {
+	for _, key := range []string{"VIRTUAL_ENV", "CONDA_DEFAULT_ENV", "IN_NIX_SHELL", "DIRENV_DIR", "KUBERNETES_SERVICE_HOST"} {
+		t.Setenv(key, "")
+	}
+
+	t.Run("Environments", func(t *testing.T) {
+		t.Setenv("VIRTUAL_ENV", "/home/dev/api/.venv")
+		t.Setenv("IN_NIX_SHELL", "impure")
+		t.Setenv("DIRENV_DIR", "-/home/dev/api")
+		runtime := runtimeenv.Detect(t.TempDir(), filepath.Join(t.TempDir(), "toolchains.json"), time.Second)
+		if runtime.Venv != "/home/dev/api/.venv" || runtime.Nix != "impure" || runtime.Direnv != "/home/dev/api" {
+			t.Errorf("Expected venv, nix and direnv to be detected, but got %+v", runtime)
+		}
+		if runtime.Container != "" {
+			t.Errorf("Expected no container, but got %s", runtime.Container)
+		}
+	})
+
+	t.Run("Containers", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			files    map[string]string
+			expected string
+		}{
+			{"Docker Marker", map[string]string{".dockerenv": ""}, "docker"},
+			{"Podman Marker", map[string]string{"run/.containerenv": ""}, "podman"},
+			{"Kubernetes Cgroup", map[string]string{".dockerenv": "", "proc/1/cgroup": "11:memory:/kubepods/besteffort/pod1234\n"}, "kubernetes"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				root := t.TempDir()
+				for name, contents := range tt.files {
+					os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0755)
+					os.WriteFile(filepath.Join(root, name), []byte(contents), 0644)
+				}
+				if runtime := runtimeenv.Detect(root, filepath.Join(root, "toolchains.json"), time.Second); runtime.Container != tt.expected {
+					t.Errorf("Expected %s, but got '%s'", tt.expected, runtime.Container)
+				}
+			})
+		}
+	})
+
+	t.Run("Toolchain Versions Are Cached", func(t *testing.T) {
+		counter := fakeToolchain(t, "20.11.0")
+		cachePath := filepath.Join(t.TempDir(), "toolchains.json")
+		for range 3 {
+			runtime := runtimeenv.Detect(t.TempDir(), cachePath, time.Second)
+			if runtime.Toolchains["node"] != "20.11.0" {
+				t.Fatalf("Expected node 20.11.0, but got %v", runtime.Toolchains)
+			}
+		}
+		runs, _ := os.ReadFile(counter)
+		if len(runs) != len("x\n") {
+			t.Errorf("Expected node to run once, but it ran %d times", len(runs)/2)
+		}
+	})
+
+	t.Run("Failures Are Cached", func(t *testing.T) {
+		bin := t.TempDir()
+		counter := filepath.Join(bin, "runs")
+		if err := os.WriteFile(filepath.Join(bin, "node"), []byte("#!/bin/sh\necho x >> "+counter+"\nexit 1\n"), 0755); err != nil {
+			t.Fatalf("could not write fake node: %v", err)
+		}
+		t.Setenv("PATH", bin)
+		cachePath := filepath.Join(t.TempDir(), "toolchains.json")
+		for range 3 {
+			if runtime := runtimeenv.Detect(t.TempDir(), cachePath, time.Second); runtime.Toolchains != nil {
+				t.Fatalf("Expected no versions, but got %v", runtime.Toolchains)
+			}
+		}
+		runs, _ := os.ReadFile(counter)
+		if len(runs) != len("x\n") {
+			t.Errorf("Expected node to run once, but it ran %d times", len(runs)/2)
+		}
+	})
+
+	t.Run("Shims Follow The Selected Version", func(t *testing.T) {
+		root := filepath.Join(t.TempDir(), ".pyenv")
+		selected := filepath.Join(root, "version")
+		files := map[string]string{
+			"shims/python3":               "#!/bin/sh\nexit 1\n",
+			"bin/pyenv":                   "#!/bin/sh\nread version < " + selected + "\necho " + root + "/versions/$version/bin/$2\n",
+			"versions/3.11.9/bin/python3": "#!/bin/sh\necho Python 3.11.9\n",
+			"versions/3.12.4/bin/python3": "#!/bin/sh\necho Python 3.12.4\n",
+		}
+		for name, contents := range files {
+			os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0755)
+			if err := os.WriteFile(filepath.Join(root, name), []byte(contents), 0755); err != nil {
+				t.Fatalf("could not write %s: %v", name, err)
+			}
+		}
+		t.Setenv("PATH", filepath.Join(root, "shims"))
+		cachePath := filepath.Join(t.TempDir(), "toolchains.json")
+		for _, version := range []string{"3.11.9", "3.12.4", "3.11.9"} {
+			os.WriteFile(selected, []byte(version), 0644)
+			if runtime := runtimeenv.Detect(t.TempDir(), cachePath, time.Second); runtime.Toolchains["python"] != version {
+				t.Errorf("Expected python %s, but got %v", version, runtime.Toolchains)
+			}
+		}
+	})
+}
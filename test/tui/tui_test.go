@@ -0,0 +1,158 @@
+package tui_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/WillRabalais04/terminalLog/internal/adapters/tui"
+	"github.com/WillRabalais04/terminalLog/internal/core/domain"
+)
+
+func TestFilter(t *testing.T) {
+	scope := tui.Scope{User: "will", SessionID: "s1", Cwd: "/repo/api", RepoRoot: "/repo"}
+
+	t.Run("modes", func(t *testing.T) {
+		tests := []struct {
+			mode  tui.Mode
+			key   string
+			value string
+		}{
+			{tui.ModeSession, "session_id", "s1"},
+			{tui.ModeDirectory, "cwd", "/repo/api"},
+			{tui.ModeRepo, "git_repo_root", "/repo"},
+		}
+		for _, test := range tests {
+			filter := tui.Filter("", test.mode, scope, 10)
+			if got := filter.FilterTerms[test.key].Values; len(got) != 1 || got[0] != test.value {
+				t.Errorf("Expected %s to filter %s=%s, but got %v", test.mode, test.key, test.value, filter.FilterTerms)
+			}
+		}
+		if filter := tui.Filter("", tui.ModeAll, scope, 10); len(filter.FilterTerms) != 1 || filter.FilterMode != domain.AND {
+			t.Errorf("Expected all hosts to only filter by user, but got %v", filter.FilterTerms)
+		}
+	})
+
+	t.Run("query", func(t *testing.T) {
+		filter := tui.Filter("git checkout main", tui.ModeAll, scope, 10)
+		if got := filter.SearchTerms["command"].Values; len(got) != 1 || got[0] != "checkout" {
+			t.Errorf("Expected the longest word to be searched for, but got %v", got)
+		}
+		if filter.OrderBy == nil || *filter.OrderBy != "ts" || filter.Limit != 10 {
+			t.Errorf("Expected the newest 10 first, but got order %v and limit %d", filter.OrderBy, filter.Limit)
+		}
+	})
+
+	t.Run("no repo mode outside a repo", func(t *testing.T) {
+		modes := tui.Scope{Cwd: "/tmp"}.Modes()
+		if !slices.Equal(modes, []tui.Mode{tui.ModeAll, tui.ModeDirectory}) {
+			t.Errorf("Expected all hosts and directory, but got %v", modes)
+		}
+	})
+}
+
+func TestPicker(t *testing.T) {
+	entries := []*domain.LogEntry{
+		{Command: "git push origin main", Timestamp: 300},
+		{Command: "make test", Timestamp: 200, ExitCode: 2},
+		{Command: "git push origin main", Timestamp: 100},
+		{Command: "GIT log --oneline", Timestamp: 50},
+	}
+	picker := tui.NewPicker("", tui.Scope{SessionID: "s1"})
+
+	for _, r := range "push git" {
+		picker.Update(tui.Key{Code: tui.KeyRune, Rune: r})
+	}
+	picker.SetResults(entries)
+	if len(picker.Results) != 1 || picker.Chosen() != "git push origin main" {
+		t.Errorf("Expected one deduped match for every word, but got %v", picker.Results)
+	}
+
+	picker.Update(tui.Key{Code: tui.KeyClear})
+	for _, r := range "git" {
+		picker.Update(tui.Key{Code: tui.KeyRune, Rune: r})
+	}
+	picker.SetResults(entries)
+	if len(picker.Results) != 2 {
+		t.Fatalf("Expected matching to ignore case, but got %v", picker.Results)
+	}
+	picker.Update(tui.Key{Code: tui.KeyUp})
+	if picker.Chosen() != "GIT log --oneline" {
+		t.Errorf("Expected up to select the older command, but got %q", picker.Chosen())
+	}
+
+	if action := picker.Update(tui.Key{Code: tui.KeyMode}); action != tui.ActionRequery || picker.Mode != tui.ModeSession {
+		t.Errorf("Expected ctrl-r to switch to the session mode, but got %s", picker.Mode)
+	}
+	if picker.Update(tui.Key{Code: tui.KeyMode}); picker.Mode != tui.ModeAll {
+		t.Errorf("Expected the modes to wrap around, but got %s", picker.Mode)
+	}
+	if action := picker.Update(tui.Key{Code: tui.KeyEnter}); action != tui.ActionAccept {
+		t.Errorf("Expected enter to accept, but got %v", action)
+	}
+}
+
+// pagedHistory is a history where one command was run far more often than the rest
+type pagedHistory struct {
+	entries []*domain.LogEntry
+	lists   int
+}
+
+func (h *pagedHistory) List(ctx context.Context, filter *domain.LogFilter) ([]*domain.LogEntry, error) {
+	h.lists++
+	start := min(int(filter.Offset), len(h.entries))
+	return h.entries[start:min(start+int(filter.Limit), len(h.entries))], nil
+}
+
+func TestSearch(t *testing.T) {
+	history := &pagedHistory{}
+	for range 700 {
+		history.entries = append(history.entries, &domain.LogEntry{Command: "git status"})
+	}
+	history.entries = append(history.entries, &domain.LogEntry{Command: "git stash pop"}, &domain.LogEntry{Command: "git stash"})
+
+	entries, err := tui.Search(context.Background(), history, tui.Filter("git", tui.ModeAll, tui.Scope{}, 500), "git")
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	picker := tui.NewPicker("git", tui.Scope{})
+	picker.SetResults(entries)
+	if len(picker.Results) != 3 {
+		t.Errorf("Expected the commands past the repeated one to be found, but got %d results", len(picker.Results))
+	}
+	if history.lists != 2 {
+		t.Errorf("Expected to stop at the short page, but listed %d pages", history.lists)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	keys := tui.ParseKeys([]byte("a\x1b[A\x1b[5~é\x7f\x12\r"))
+	want := []tui.Key{
+		{Code: tui.KeyRune, Rune: 'a'}, {Code: tui.KeyUp}, {Code: tui.KeyUnknown}, {Code: tui.KeyRune, Rune: 'é'},
+		{Code: tui.KeyBackspace}, {Code: tui.KeyMode}, {Code: tui.KeyEnter},
+	}
+	if !slices.Equal(keys, want) {
+		t.Errorf("Expected %v, but got %v", want, keys)
+	}
+	if keys := tui.ParseKeys([]byte{0x1b}); len(keys) != 1 || keys[0].Code != tui.KeyCancel {
+		t.Errorf("Expected a lone escape to cancel, but got %v", keys)
+	}
+}
+
+func TestRelativeTime(t *testing.T) {
+	now := time.Unix(1_000_000_000, 0)
+	tests := map[time.Duration]string{
+		30 * time.Second:     "30s",
+		5 * time.Minute:      "5m",
+		3 * time.Hour:        "3h",
+		2 * 24 * time.Hour:   "2d",
+		90 * 24 * time.Hour:  "3mo",
+		400 * 24 * time.Hour: "1y",
+	}
+	for ago, want := range tests {
+		if got := tui.RelativeTime(now, now.Add(-ago).Unix()); got != want {
+			t.Errorf("Expected %s ago to be %q, but got %q", ago, want, got)
+		}
+	}
+}